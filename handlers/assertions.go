@@ -0,0 +1,87 @@
+package handlers
+
+import (
+        "database/sql"
+        "net/http"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+        "web-crawler/models"
+        "web-crawler/services"
+)
+
+type CreateAssertionRequest struct {
+        Type  string `json:"type" binding:"required"`
+        Value string `json:"value"`
+}
+
+func (h *URLHandler) GetAssertions(c *gin.Context) {
+        id := c.Param("id")
+
+        assertions, err := models.GetAssertions(h.db, id)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assertions"})
+                return
+        }
+
+        c.JSON(http.StatusOK, assertions)
+}
+
+func (h *URLHandler) CreateAssertion(c *gin.Context) {
+        id := c.Param("id")
+
+        var req CreateAssertionRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+                return
+        }
+        if err := services.ValidateAssertion(req.Type, req.Value); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                return
+        }
+
+        if _, err := models.GetURLByID(h.db, id); err == sql.ErrNoRows {
+                c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+                return
+        }
+
+        assertion, err := models.CreateAssertion(h.db, id, req.Type, req.Value)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create assertion"})
+                return
+        }
+
+        c.JSON(http.StatusCreated, assertion)
+}
+
+func (h *URLHandler) DeleteAssertion(c *gin.Context) {
+        id := c.Param("id")
+
+        err := models.DeleteAssertion(h.db, id, c.Param("assertionId"))
+        if err == sql.ErrNoRows {
+                c.JSON(http.StatusNotFound, gin.H{"error": "Assertion not found"})
+                return
+        }
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete assertion"})
+                return
+        }
+
+        c.JSON(http.StatusOK, gin.H{"message": "Assertion deleted"})
+}
+
+func (h *URLHandler) GetAssertionHistory(c *gin.Context) {
+        id := c.Param("id")
+        limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+        if limit <= 0 {
+                limit = 50
+        }
+
+        results, err := models.GetAssertionHistory(h.db, id, limit)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assertion history"})
+                return
+        }
+
+        c.JSON(http.StatusOK, results)
+}
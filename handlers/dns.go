@@ -0,0 +1,60 @@
+package handlers
+
+import (
+        "database/sql"
+        "net/http"
+
+        "github.com/gin-gonic/gin"
+        "web-crawler/models"
+        "web-crawler/services"
+)
+
+type DNSOverridesRequest struct {
+        Overrides []models.DNSOverride `json:"overrides"`
+}
+
+// SetDNSOverrides pins hosts to IP addresses for the URL's crawls. The
+// overrides apply to the page fetch, subresources and link checks.
+func (h *URLHandler) SetDNSOverrides(c *gin.Context) {
+        id := c.Param("id")
+
+        var req DNSOverridesRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+                return
+        }
+
+        for i := range req.Overrides {
+                if err := services.ValidateDNSOverride(&req.Overrides[i]); err != nil {
+                        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                        return
+                }
+        }
+
+        if _, err := models.GetURLByID(h.db, id); err == sql.ErrNoRows {
+                c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+                return
+        }
+
+        if err := models.SetDNSOverrides(h.db, id, req.Overrides); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update DNS overrides"})
+                return
+        }
+
+        url, err := models.GetURLByID(h.db, id)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch URL"})
+                return
+        }
+
+        c.JSON(http.StatusOK, url)
+}
+
+func (h *URLHandler) DeleteDNSOverrides(c *gin.Context) {
+        if err := models.SetDNSOverrides(h.db, c.Param("id"), nil); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove DNS overrides"})
+                return
+        }
+
+        c.JSON(http.StatusOK, gin.H{"message": "DNS overrides removed"})
+}
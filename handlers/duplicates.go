@@ -0,0 +1,38 @@
+package handlers
+
+import (
+        "net/http"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+        "web-crawler/services"
+)
+
+// GetDuplicates reports clusters of tracked pages with duplicate titles,
+// meta descriptions or body content. ?type= narrows the report to one kind
+// and ?threshold= sets the near-duplicate content similarity in percent.
+func (h *URLHandler) GetDuplicates(c *gin.Context) {
+        kind := c.Query("type")
+        if err := services.ValidateDuplicateType(kind); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                return
+        }
+
+        threshold := services.DefaultDuplicateThreshold
+        if value := c.Query("threshold"); value != "" {
+                parsed, err := strconv.ParseFloat(value, 64)
+                if err != nil || parsed < 50 || parsed > 100 {
+                        c.JSON(http.StatusBadRequest, gin.H{"error": "threshold must be a number between 50 and 100"})
+                        return
+                }
+                threshold = parsed
+        }
+
+        clusters, err := services.FindDuplicates(h.db, kind, threshold)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find duplicates"})
+                return
+        }
+
+        c.JSON(http.StatusOK, gin.H{"clusters": clusters, "threshold": threshold})
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+        "log"
+        "net/http"
+        "time"
+
+        "github.com/gin-gonic/gin"
+
+        "web-crawler/models"
+        "web-crawler/services"
+)
+
+// ExportURLs streams the URL list, filtered and sorted like GetURLs, as
+// CSV, NDJSON or XLSX.
+func (h *URLHandler) ExportURLs(c *gin.Context) {
+        format := c.DefaultQuery("format", services.ExportFormatCSV)
+        if services.ExportContentType(format) == "" {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv, ndjson or xlsx"})
+                return
+        }
+
+        ruleNames, err := models.GetExtractionRuleNames(h.db)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch extraction rules"})
+                return
+        }
+
+        writer := startExport(c, "urls", format, services.URLExportColumns(ruleNames))
+        if writer == nil {
+                return
+        }
+
+        err = models.StreamURLs(h.db, urlFilter(c), c.DefaultQuery("sort", "created_at"), c.DefaultQuery("order", "desc"),
+                func(url *models.URL) error {
+                        return writer.WriteRow(services.URLExportRow(url, ruleNames))
+                })
+        finishExport(c, writer, err)
+}
+
+// ExportBrokenLinks streams the broken links found by the latest crawl of
+// one URL.
+func (h *URLHandler) ExportBrokenLinks(c *gin.Context) {
+        id := c.Param("id")
+        if _, err := models.GetURLByID(h.db, id); err != nil {
+                c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+                return
+        }
+        h.writeBrokenLinks(c, id)
+}
+
+// ExportAllBrokenLinks streams the broken links found by the latest crawl
+// of every URL.
+func (h *URLHandler) ExportAllBrokenLinks(c *gin.Context) {
+        h.writeBrokenLinks(c, "")
+}
+
+func (h *URLHandler) writeBrokenLinks(c *gin.Context, urlID string) {
+        format := c.DefaultQuery("format", services.ExportFormatCSV)
+        if services.ExportContentType(format) == "" {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv, ndjson or xlsx"})
+                return
+        }
+
+        writer := startExport(c, "broken-links", format, services.BrokenLinkExportColumns)
+        if writer == nil {
+                return
+        }
+
+        err := models.StreamBrokenLinks(h.db, urlID, func(link models.BrokenLink, pageURL string) error {
+                return writer.WriteRow(services.BrokenLinkExportRow(link, pageURL))
+        })
+        finishExport(c, writer, err)
+}
+
+// startExport sends the download headers and the header row. Once it
+// returns, errors can no longer be reported as JSON.
+func startExport(c *gin.Context, name, format string, columns []string) services.ExportWriter {
+        filename := name + "-" + time.Now().UTC().Format("20060102150405") + "." + format
+        c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+        c.Header("Content-Type", services.ExportContentType(format))
+        c.Status(http.StatusOK)
+
+        writer, err := services.NewExportWriter(format, c.Writer, columns)
+        if err != nil {
+                failExport(c, err)
+                return nil
+        }
+        return writer
+}
+
+func finishExport(c *gin.Context, writer services.ExportWriter, err error) {
+        if err == nil {
+                err = writer.Close()
+        }
+        if err != nil {
+                failExport(c, err)
+        }
+}
+
+// failExport ends a download that broke off midway. The status line has
+// already gone out, so the connection is closed without finishing the
+// response to make the client see a failed download rather than a file that
+// looks complete.
+func failExport(c *gin.Context, err error) {
+        log.Printf("Export %s failed: %v", c.Request.URL.Path, err)
+        c.Error(err)
+
+        conn, _, hijackErr := c.Writer.Hijack()
+        if hijackErr != nil {
+                log.Printf("Export %s: failed to abort the response: %v", c.Request.URL.Path, hijackErr)
+                return
+        }
+        conn.Close()
+}
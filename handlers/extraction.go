@@ -0,0 +1,107 @@
+package handlers
+
+import (
+        "database/sql"
+        "net/http"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+        "web-crawler/models"
+        "web-crawler/services"
+)
+
+type CreateExtractionRuleRequest struct {
+        Name       string  `json:"name" binding:"required"`
+        URLID      *string `json:"url_id"`
+        URLPattern *string `json:"url_pattern"`
+        Method     string  `json:"method" binding:"required"`
+        Expression string  `json:"expression" binding:"required"`
+        Attribute  string  `json:"attribute"`
+        ValueType  string  `json:"value_type"`
+        Multiple   bool    `json:"multiple"`
+}
+
+func (h *URLHandler) GetExtractionRules(c *gin.Context) {
+        rules, err := models.GetExtractionRules(h.db, c.Query("url_id"))
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch extraction rules"})
+                return
+        }
+
+        c.JSON(http.StatusOK, rules)
+}
+
+func (h *URLHandler) CreateExtractionRule(c *gin.Context) {
+        var req CreateExtractionRuleRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+                return
+        }
+
+        rule := models.ExtractionRule{
+                Name:       req.Name,
+                URLID:      req.URLID,
+                URLPattern: req.URLPattern,
+                Method:     req.Method,
+                Expression: req.Expression,
+                Attribute:  req.Attribute,
+                ValueType:  req.ValueType,
+                Multiple:   req.Multiple,
+        }
+        existing, err := models.GetExtractionRules(h.db, "")
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch extraction rules"})
+                return
+        }
+        if err := services.ValidateExtractionRule(&rule, existing); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                return
+        }
+
+        if rule.URLID != nil {
+                if _, err := models.GetURLByID(h.db, *rule.URLID); err == sql.ErrNoRows {
+                        c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+                        return
+                }
+        }
+
+        created, err := models.CreateExtractionRule(h.db, rule)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create extraction rule"})
+                return
+        }
+
+        c.JSON(http.StatusCreated, created)
+}
+
+func (h *URLHandler) DeleteExtractionRule(c *gin.Context) {
+        err := models.DeleteExtractionRule(h.db, c.Param("ruleId"))
+        if err == sql.ErrNoRows {
+                c.JSON(http.StatusNotFound, gin.H{"error": "Extraction rule not found"})
+                return
+        }
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete extraction rule"})
+                return
+        }
+
+        c.JSON(http.StatusOK, gin.H{"message": "Extraction rule deleted"})
+}
+
+// GetExtractedValues returns the history of values extracted for a URL,
+// optionally for a single field name.
+func (h *URLHandler) GetExtractedValues(c *gin.Context) {
+        id := c.Param("id")
+        limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+        if limit <= 0 {
+                limit = 50
+        }
+
+        values, err := models.GetExtractedValueHistory(h.db, id, c.Query("name"), limit)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch extracted values"})
+                return
+        }
+
+        c.JSON(http.StatusOK, values)
+}
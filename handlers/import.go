@@ -0,0 +1,81 @@
+package handlers
+
+import (
+        "bytes"
+        "io"
+        "net/http"
+        "sort"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+        "web-crawler/services"
+)
+
+// maxImportSize is the largest import file accepted, in bytes.
+const maxImportSize = 10 << 20
+
+// ImportURLs creates URLs from an uploaded CSV, text or JSON file given as
+// the "file" form field. The format is taken from the "format" field or
+// the file extension, and crawls of the created URLs are queued when
+// "crawl" is true. The response reports every entry row by row.
+func (h *URLHandler) ImportURLs(c *gin.Context) {
+        header, err := c.FormFile("file")
+        if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "A file upload named \"file\" is required"})
+                return
+        }
+        if header.Size > maxImportSize {
+                c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Import file is larger than 10 MB"})
+                return
+        }
+
+        file, err := header.Open()
+        if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read import file"})
+                return
+        }
+        defer file.Close()
+
+        content, err := io.ReadAll(io.LimitReader(file, maxImportSize))
+        if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read import file"})
+                return
+        }
+        content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+
+        format := c.PostForm("format")
+        if format == "" {
+                format = services.DetectImportFormat(header.Filename)
+        }
+
+        entries, invalid, err := services.ParseImport(format, bytes.NewReader(content))
+        if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                return
+        }
+
+        results := append(services.ImportURLs(h.db, entries), invalid...)
+        sort.SliceStable(results, func(i, j int) bool { return results[i].Row < results[j].Row })
+
+        summary := map[string]int{services.ImportCreated: 0, services.ImportSkipped: 0, services.ImportInvalid: 0}
+        var created []string
+        for _, result := range results {
+                summary[result.Status]++
+                if result.Status == services.ImportCreated {
+                        created = append(created, result.ID)
+                }
+        }
+
+        queued := 0
+        if crawl, _ := strconv.ParseBool(c.PostForm("crawl")); crawl || queryTrue(c, "crawl") {
+                h.crawler.QueueCrawls(created)
+                queued = len(created)
+        }
+
+        c.JSON(http.StatusOK, gin.H{
+                "format":  format,
+                "summary": summary,
+                "queued":  queued,
+                "results": results,
+        })
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+        "database/sql"
+        "net/http"
+        "net/url"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+        "web-crawler/models"
+)
+
+func (h *URLHandler) GetOutlinks(c *gin.Context) {
+        id := c.Param("id")
+        page, limit := linkPagination(c)
+
+        links, total, err := models.GetOutlinks(h.db, id, linkFilter(c), page, limit)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch outlinks"})
+                return
+        }
+
+        c.JSON(http.StatusOK, gin.H{"links": links, "total": total, "page": page, "limit": limit})
+}
+
+// GetInlinks returns the crawled pages linking to a tracked URL.
+func (h *URLHandler) GetInlinks(c *gin.Context) {
+        urlRecord, err := models.GetURLByID(h.db, c.Param("id"))
+        if err == sql.ErrNoRows {
+                c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+                return
+        }
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch URL"})
+                return
+        }
+
+        h.writeInlinks(c, urlRecord.URL)
+}
+
+// GetLinks returns the crawled pages linking to any URL given as target,
+// whether it is tracked or not.
+func (h *URLHandler) GetLinks(c *gin.Context) {
+        target := c.Query("target")
+        if target == "" {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "target is required"})
+                return
+        }
+
+        h.writeInlinks(c, target)
+}
+
+func (h *URLHandler) writeInlinks(c *gin.Context, target string) {
+        page, limit := linkPagination(c)
+
+        links, total, err := models.GetInlinks(h.db, linkTargets(target), linkFilter(c), page, limit)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch inlinks"})
+                return
+        }
+
+        c.JSON(http.StatusOK, gin.H{"links": links, "total": total, "page": page, "limit": limit})
+}
+
+// linkTargets returns the forms a URL takes as a stored link target: links
+// are stored resolved, without fragment, and a bare host gets a "/" path.
+func linkTargets(raw string) []string {
+        targets := []string{raw}
+
+        parsed, err := url.Parse(raw)
+        if err != nil {
+                return targets
+        }
+        parsed.Fragment = ""
+        targets = append(targets, parsed.String())
+        if parsed.Path == "" {
+                parsed.Path = "/"
+                targets = append(targets, parsed.String())
+        }
+        return targets
+}
+
+func linkFilter(c *gin.Context) models.LinkFilter {
+        return models.LinkFilter{
+                Internal: queryBool(c, "internal"),
+                Rel:      c.Query("rel"),
+        }
+}
+
+func linkPagination(c *gin.Context) (int, int) {
+        page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+        limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+        if page <= 0 {
+                page = 1
+        }
+        if limit <= 0 {
+                limit = 100
+        }
+        return page, limit
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+        "database/sql"
+        "net/http"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+        "web-crawler/models"
+        "web-crawler/services"
+)
+
+func (h *URLHandler) GetCrawlRuns(c *gin.Context) {
+        id := c.Param("id")
+        limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+        if limit <= 0 {
+                limit = 20
+        }
+
+        runs, err := models.GetCrawlRuns(h.db, id, limit)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch crawl runs"})
+                return
+        }
+
+        c.JSON(http.StatusOK, runs)
+}
+
+func (h *URLHandler) GetCrawlRun(c *gin.Context) {
+        id := c.Param("id")
+
+        run, err := models.GetCrawlRun(h.db, c.Param("runId"))
+        if err == sql.ErrNoRows || (err == nil && run.URLID != id) {
+                c.JSON(http.StatusNotFound, gin.H{"error": "Crawl run not found"})
+                return
+        }
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch crawl run"})
+                return
+        }
+
+        c.JSON(http.StatusOK, run)
+}
+
+func (h *URLHandler) GetChanges(c *gin.Context) {
+        id := c.Param("id")
+        limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+        if limit <= 0 {
+                limit = 20
+        }
+
+        changes, err := models.GetContentChanges(h.db, id, c.Query("significant") == "true", limit)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch changes"})
+                return
+        }
+
+        c.JSON(http.StatusOK, changes)
+}
+
+// GetDiff compares two crawl runs of a URL. Without from and to it compares
+// the latest run with the one before it.
+func (h *URLHandler) GetDiff(c *gin.Context) {
+        id := c.Param("id")
+        to := c.Query("to")
+        from := c.Query("from")
+
+        var err error
+        if to == "" {
+                to, err = models.GetPreviousRunID(h.db, id, "")
+                if err == sql.ErrNoRows {
+                        c.JSON(http.StatusNotFound, gin.H{"error": "No completed crawl runs for this URL"})
+                        return
+                }
+                if err != nil {
+                        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch crawl runs"})
+                        return
+                }
+        }
+        if from == "" {
+                from, err = models.GetPreviousRunID(h.db, id, to)
+                if err == sql.ErrNoRows {
+                        c.JSON(http.StatusNotFound, gin.H{"error": "No earlier crawl run to compare with"})
+                        return
+                }
+                if err != nil {
+                        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch crawl runs"})
+                        return
+                }
+        }
+
+        states := make([]*models.PageState, 2)
+        for i, runID := range []string{from, to} {
+                run, err := models.GetCrawlRun(h.db, runID)
+                if err == sql.ErrNoRows || (err == nil && run.URLID != id) {
+                        c.JSON(http.StatusNotFound, gin.H{"error": "Crawl run not found"})
+                        return
+                }
+                if err != nil {
+                        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch crawl run"})
+                        return
+                }
+
+                states[i], err = models.GetRunPageState(h.db, runID)
+                if err == sql.ErrNoRows {
+                        c.JSON(http.StatusNotFound, gin.H{"error": "Crawl run has no page state to compare"})
+                        return
+                }
+                if err != nil {
+                        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch page state"})
+                        return
+                }
+        }
+
+        c.JSON(http.StatusOK, gin.H{
+                "from": from,
+                "to":   to,
+                "diff": services.DiffPageStates(*states[0], *states[1]),
+        })
+}
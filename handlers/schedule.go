@@ -0,0 +1,68 @@
+package handlers
+
+import (
+        "database/sql"
+        "net/http"
+        "time"
+
+        "github.com/gin-gonic/gin"
+        "web-crawler/models"
+        "web-crawler/services"
+)
+
+// SetSchedule attaches a recrawl schedule to a URL and returns the URL with
+// its next run time.
+func (h *URLHandler) SetSchedule(c *gin.Context) {
+        id := c.Param("id")
+
+        var req models.URLSchedule
+        if err := c.ShouldBindJSON(&req); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+                return
+        }
+
+        schedule, err := services.ParseSchedule(req)
+        if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                return
+        }
+        next := schedule.Next(time.Now())
+        if next.IsZero() {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Schedule never runs"})
+                return
+        }
+
+        if _, err := models.GetURLByID(h.db, id); err == sql.ErrNoRows {
+                c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+                return
+        }
+
+        if err := models.SetURLSchedule(h.db, id, req, next); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update schedule"})
+                return
+        }
+
+        url, err := models.GetURLByID(h.db, id)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch URL"})
+                return
+        }
+
+        c.JSON(http.StatusOK, url)
+}
+
+func (h *URLHandler) DeleteSchedule(c *gin.Context) {
+        id := c.Param("id")
+
+        if _, err := models.GetURLByID(h.db, id); err == sql.ErrNoRows {
+                c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+                return
+        }
+
+        if err := models.ClearURLSchedule(h.db, id); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove schedule"})
+                return
+        }
+
+        c.JSON(http.StatusOK, gin.H{"message": "Schedule removed"})
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+        "database/sql"
+        "net/http"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+        "web-crawler/models"
+)
+
+// AnalyzeSite starts an internal link analysis with the URL as seed. The
+// analysis runs in the background; its status shows in GetSiteAnalysis.
+func (h *URLHandler) AnalyzeSite(c *gin.Context) {
+        analysis, err := h.crawler.AnalyzeSite(c.Param("id"))
+        if err == sql.ErrNoRows {
+                c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+                return
+        }
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start site analysis: " + err.Error()})
+                return
+        }
+
+        c.JSON(http.StatusAccepted, analysis)
+}
+
+func (h *URLHandler) GetSiteAnalyses(c *gin.Context) {
+        limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+        if limit <= 0 {
+                limit = 20
+        }
+
+        analyses, err := models.GetSiteAnalyses(h.db, c.Param("id"), limit)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch site analyses"})
+                return
+        }
+
+        c.JSON(http.StatusOK, analyses)
+}
+
+// GetSiteAnalysis returns an analysis with its pages, the latest one when
+// no analysis id is given. Pages can be narrowed with ?orphan=true,
+// ?deep=true or ?unreachable=true.
+func (h *URLHandler) GetSiteAnalysis(c *gin.Context) {
+        id := c.Param("id")
+
+        analysisID := c.Param("analysisId")
+        if analysisID == "" {
+                analyses, err := models.GetSiteAnalyses(h.db, id, 1)
+                if err != nil {
+                        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch site analysis"})
+                        return
+                }
+                if len(analyses) == 0 {
+                        c.JSON(http.StatusNotFound, gin.H{"error": "Site analysis not found"})
+                        return
+                }
+                analysisID = analyses[0].ID
+        }
+
+        analysis, err := models.GetSiteAnalysis(h.db, analysisID)
+        if err == sql.ErrNoRows || (err == nil && analysis.SeedURLID != id) {
+                c.JSON(http.StatusNotFound, gin.H{"error": "Site analysis not found"})
+                return
+        }
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch site analysis"})
+                return
+        }
+
+        orphan, deep, unreachable := queryTrue(c, "orphan"), queryTrue(c, "deep"), queryTrue(c, "unreachable")
+        if orphan || deep || unreachable {
+                pages := []models.SiteAnalysisPage{}
+                for _, p := range analysis.Pages {
+                        if (orphan && !p.Orphan) || (deep && !p.Deep) || (unreachable && p.Depth != nil) {
+                                continue
+                        }
+                        pages = append(pages, p)
+                }
+                analysis.Pages = pages
+        }
+
+        c.JSON(http.StatusOK, analysis)
+}
+
+// queryTrue reports whether a boolean query parameter is set to true.
+func queryTrue(c *gin.Context, name string) bool {
+        value := queryBool(c, name)
+        return value != nil && *value
+}
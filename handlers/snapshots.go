@@ -0,0 +1,148 @@
+package handlers
+
+import (
+        "database/sql"
+        "fmt"
+        "net/http"
+        "time"
+
+        "github.com/gin-gonic/gin"
+        "web-crawler/models"
+        "web-crawler/services"
+)
+
+type WARCExportRequest struct {
+        IDs  []string `json:"ids" binding:"required"`
+        Gzip bool     `json:"gzip"`
+}
+
+type SnapshotRetentionRequest struct {
+        Retention *int `json:"retention"`
+}
+
+func (h *URLHandler) GetSnapshots(c *gin.Context) {
+        id := c.Param("id")
+
+        snapshots, err := models.GetSnapshots(h.db, id)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch snapshots"})
+                return
+        }
+
+        c.JSON(http.StatusOK, snapshots)
+}
+
+// DownloadSnapshot returns the archived body with its original content type,
+// or the full HTTP response message when include_headers=true.
+func (h *URLHandler) DownloadSnapshot(c *gin.Context) {
+        id := c.Param("id")
+
+        snapshot, err := models.GetSnapshot(h.db, c.Param("snapshotId"))
+        if err == sql.ErrNoRows || (err == nil && snapshot.URLID != id) {
+                c.JSON(http.StatusNotFound, gin.H{"error": "Snapshot not found"})
+                return
+        }
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch snapshot"})
+                return
+        }
+
+        body, err := models.GetSnapshotBody(h.db, snapshot.ContentHash)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read snapshot body"})
+                return
+        }
+
+        if c.Query("include_headers") == "true" {
+                message := fmt.Sprintf("%s %s\r\n%s\r\n", snapshot.Protocol, snapshot.Status, snapshot.Headers)
+                c.Header("Content-Disposition", `attachment; filename="`+snapshot.ID+`.http"`)
+                c.Data(http.StatusOK, "message/http", append([]byte(message), body...))
+                return
+        }
+
+        contentType := services.SnapshotContentType(*snapshot)
+        c.Header("Content-Disposition", `attachment; filename="`+snapshot.ID+`"`)
+        c.Data(http.StatusOK, contentType, body)
+}
+
+// ExportURLWARC streams every retained snapshot of a URL as a WARC file.
+func (h *URLHandler) ExportURLWARC(c *gin.Context) {
+        h.writeWARC(c, []string{c.Param("id")}, c.Query("gzip") == "true")
+}
+
+// ExportWARC streams the snapshots of several URLs as one WARC file.
+func (h *URLHandler) ExportWARC(c *gin.Context) {
+        var req WARCExportRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+                return
+        }
+
+        h.writeWARC(c, req.IDs, req.Gzip)
+}
+
+func (h *URLHandler) writeWARC(c *gin.Context, urlIDs []string, gzip bool) {
+        // Load the snapshot list up front so errors can still be reported as JSON
+        var snapshots []models.Snapshot
+        for _, id := range urlIDs {
+                list, err := models.GetSnapshots(h.db, id)
+                if err != nil {
+                        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch snapshots"})
+                        return
+                }
+                snapshots = append(snapshots, list...)
+        }
+        if len(snapshots) == 0 {
+                c.JSON(http.StatusNotFound, gin.H{"error": "No snapshots found"})
+                return
+        }
+
+        filename := "crawl-" + time.Now().UTC().Format("20060102150405") + ".warc"
+        contentType := "application/warc"
+        if gzip {
+                filename += ".gz"
+                contentType = "application/gzip"
+        }
+        c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+        c.Header("Content-Type", contentType)
+        c.Status(http.StatusOK)
+
+        writer := services.NewWARCWriter(c.Writer, gzip)
+        if err := writer.WriteWarcinfo(filename); err != nil {
+                c.Error(err)
+                return
+        }
+        for _, snapshot := range snapshots {
+                body, err := models.GetSnapshotBody(h.db, snapshot.ContentHash)
+                if err != nil {
+                        c.Error(err)
+                        return
+                }
+                if err := writer.WriteResponse(snapshot, body); err != nil {
+                        c.Error(err)
+                        return
+                }
+                c.Writer.Flush()
+        }
+}
+
+func (h *URLHandler) SetSnapshotRetention(c *gin.Context) {
+        id := c.Param("id")
+        var req SnapshotRetentionRequest
+        if err := c.ShouldBindJSON(&req); err != nil || (req.Retention != nil && *req.Retention <= 0) {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Retention must be a positive number or null"})
+                return
+        }
+
+        if _, err := models.GetURLByID(h.db, id); err == sql.ErrNoRows {
+                c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+                return
+        }
+
+        if err := models.SetSnapshotRetention(h.db, id, req.Retention); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update snapshot retention"})
+                return
+        }
+
+        c.JSON(http.StatusOK, gin.H{"message": "Snapshot retention updated"})
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+        "database/sql"
+        "net/http"
+
+        "github.com/gin-gonic/gin"
+        "web-crawler/models"
+        "web-crawler/services"
+)
+
+type TagsRequest struct {
+        Tags []string `json:"tags"`
+}
+
+// SetTags replaces the tags of a URL. URLs can be listed by tag with
+// GET /urls?tag=.
+func (h *URLHandler) SetTags(c *gin.Context) {
+        id := c.Param("id")
+
+        var req TagsRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+                return
+        }
+
+        tags, err := services.NormalizeTags(req.Tags)
+        if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                return
+        }
+
+        if _, err := models.GetURLByID(h.db, id); err == sql.ErrNoRows {
+                c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+                return
+        }
+
+        if err := models.SetURLTags(h.db, id, tags); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tags"})
+                return
+        }
+
+        url, err := models.GetURLByID(h.db, id)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch URL"})
+                return
+        }
+
+        c.JSON(http.StatusOK, url)
+}
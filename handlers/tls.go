@@ -0,0 +1,43 @@
+package handlers
+
+import (
+        "database/sql"
+        "net/http"
+
+        "github.com/gin-gonic/gin"
+        "web-crawler/models"
+)
+
+type TLSOptionsRequest struct {
+        Insecure *bool `json:"insecure" binding:"required"`
+}
+
+// SetTLSOptions sets whether crawls of a URL accept invalid server
+// certificates. Runs crawled that way are flagged with tls_insecure.
+func (h *URLHandler) SetTLSOptions(c *gin.Context) {
+        id := c.Param("id")
+
+        var req TLSOptionsRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+                return
+        }
+
+        if _, err := models.GetURLByID(h.db, id); err == sql.ErrNoRows {
+                c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+                return
+        }
+
+        if err := models.SetTLSInsecure(h.db, id, *req.Insecure); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update TLS options"})
+                return
+        }
+
+        url, err := models.GetURLByID(h.db, id)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch URL"})
+                return
+        }
+
+        c.JSON(http.StatusOK, url)
+}
@@ -34,11 +34,12 @@ func NewURLHandler(db *sql.DB, crawler *services.Crawler) *URLHandler {
 func (h *URLHandler) GetURLs(c *gin.Context) {
         page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
         limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-        search := c.Query("search")
         sortBy := c.DefaultQuery("sort", "created_at")
         sortOrder := c.DefaultQuery("order", "desc")
 
-        urls, total, err := models.GetURLs(h.db, page, limit, search, sortBy, sortOrder)
+        filter := urlFilter(c)
+
+        urls, total, err := models.GetURLs(h.db, page, limit, filter, sortBy, sortOrder)
         if err != nil {
                 c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch URLs"})
                 return
@@ -52,6 +53,21 @@ func (h *URLHandler) GetURLs(c *gin.Context) {
         })
 }
 
+// urlFilter reads the URL list filters shared by listing and export.
+func urlFilter(c *gin.Context) models.URLFilter {
+        return models.URLFilter{
+                Search:             c.Query("search"),
+                MinSecurityScore:   queryInt(c, "min_security_score"),
+                MaxSecurityScore:   queryInt(c, "max_security_score"),
+                Technology:         c.Query("technology"),
+                TechnologyCategory: c.Query("technology_category"),
+                Content:            c.Query("content"),
+                Changed:            queryBool(c, "changed"),
+                AssertionStatus:    c.Query("assertion_status"),
+                Tag:                c.Query("tag"),
+        }
+}
+
 func (h *URLHandler) CreateURL(c *gin.Context) {
         var req CreateURLRequest
         if err := c.ShouldBindJSON(&req); err != nil {
@@ -59,14 +75,29 @@ func (h *URLHandler) CreateURL(c *gin.Context) {
                 return
         }
 
-        // Check if URL already exists
-        existingURL, err := models.GetURLByURL(h.db, req.URL)
+        // Reject unsupported schemes, malformed hosts and blocked targets
+        if err := services.ValidateURL(req.URL); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL: " + err.Error()})
+                return
+        }
+        normalizedURL, err := services.NormalizeURL(req.URL)
+        if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL: " + err.Error()})
+                return
+        }
+
+        // Check if the URL is already tracked under the same normalized form
+        existingURL, err := models.GetURLByNormalizedURL(h.db, normalizedURL)
         if err == nil && existingURL != nil {
-                c.JSON(http.StatusConflict, gin.H{"error": "URL already exists"})
+                c.JSON(http.StatusConflict, gin.H{"error": "URL already exists", "existing_id": existingURL.ID})
                 return
         }
 
-        url, err := models.CreateURL(h.db, req.URL)
+        url, err := models.CreateURL(h.db, req.URL, normalizedURL)
+        if err == models.ErrDuplicateURL {
+                c.JSON(http.StatusConflict, gin.H{"error": "URL already exists"})
+                return
+        }
         if err != nil {
                 c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create URL"})
                 return
@@ -86,7 +117,21 @@ func (h *URLHandler) UpdateURL(c *gin.Context) {
                 return
         }
 
-        url, err := models.UpdateURL(h.db, id, req.URL)
+        if err := services.ValidateURL(req.URL); err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL: " + err.Error()})
+                return
+        }
+        normalizedURL, err := services.NormalizeURL(req.URL)
+        if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL: " + err.Error()})
+                return
+        }
+
+        url, err := models.UpdateURL(h.db, id, req.URL, normalizedURL)
+        if err == models.ErrDuplicateURL {
+                c.JSON(http.StatusConflict, gin.H{"error": "URL already exists"})
+                return
+        }
         if err != nil {
                 c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update URL"})
                 return
@@ -108,17 +153,21 @@ func (h *URLHandler) DeleteURL(c *gin.Context) {
 
 func (h *URLHandler) StartCrawl(c *gin.Context) {
         id := c.Param("id")
+
+        if _, err := models.GetURLByID(h.db, id); err == sql.ErrNoRows {
+                c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+                return
+        }
         
-        // Update status to running
-        err := models.UpdateURLStatus(h.db, id, "running")
-        if err != nil {
-                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update status"})
+        // Start crawling in background; the crawler sets the status
+        options := services.CrawlOptions{
+                Subresources: c.Query("subresources") == "true",
+        }
+        if !h.crawler.TryStartCrawl(id, options) {
+                c.JSON(http.StatusConflict, gin.H{"error": "Crawl already running"})
                 return
         }
 
-        // Start crawling in background
-        go h.crawler.CrawlURL(id)
-
         c.JSON(http.StatusOK, gin.H{"message": "Crawl started"})
 }
 
@@ -160,8 +209,7 @@ func (h *URLHandler) BulkAction(c *gin.Context) {
                 }
         case "start":
                 for _, id := range req.IDs {
-                        models.UpdateURLStatus(h.db, id, "pending")
-                        go h.crawler.CrawlURL(id)
+                        h.crawler.TryStartCrawl(id, services.CrawlOptions{})
                 }
         case "stop":
                 for _, id := range req.IDs {
@@ -170,8 +218,7 @@ func (h *URLHandler) BulkAction(c *gin.Context) {
                 }
         case "recrawl":
                 for _, id := range req.IDs {
-                        models.UpdateURLStatus(h.db, id, "pending")
-                        go h.crawler.CrawlURL(id)
+                        h.crawler.TryStartCrawl(id, services.CrawlOptions{})
                 }
         default:
                 c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid action"})
@@ -192,3 +239,200 @@ func (h *URLHandler) GetBrokenLinks(c *gin.Context) {
 
         c.JSON(http.StatusOK, brokenLinks)
 }
+
+func (h *URLHandler) GetStructuredData(c *gin.Context) {
+        id := c.Param("id")
+
+        items, err := models.GetStructuredData(h.db, id)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch structured data"})
+                return
+        }
+
+        c.JSON(http.StatusOK, items)
+}
+
+func (h *URLHandler) GetAccessibility(c *gin.Context) {
+        id := c.Param("id")
+
+        findings, err := models.GetAccessibilityFindings(h.db, id)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch accessibility findings"})
+                return
+        }
+
+        c.JSON(http.StatusOK, findings)
+}
+
+// GetHeadings returns the heading outline of the latest crawl run, or of the
+// run given by ?run=.
+func (h *URLHandler) GetHeadings(c *gin.Context) {
+        id := c.Param("id")
+
+        var outline []models.Heading
+        var err error
+        if runID := c.Query("run"); runID != "" {
+                run, runErr := models.GetCrawlRun(h.db, runID)
+                if runErr == sql.ErrNoRows || (runErr == nil && run.URLID != id) {
+                        c.JSON(http.StatusNotFound, gin.H{"error": "Crawl run not found"})
+                        return
+                }
+                if runErr != nil {
+                        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch crawl run"})
+                        return
+                }
+                outline, err = models.GetRunHeadings(h.db, runID)
+        } else {
+                outline, err = models.GetHeadings(h.db, id)
+        }
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch headings"})
+                return
+        }
+
+        issues := 0
+        for _, heading := range outline {
+                issues += len(heading.Issues)
+        }
+
+        c.JSON(http.StatusOK, gin.H{
+                "outline": outline,
+                "issues":  issues,
+        })
+}
+
+func (h *URLHandler) GetForms(c *gin.Context) {
+        id := c.Param("id")
+
+        forms, err := models.GetForms(h.db, id)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch forms"})
+                return
+        }
+
+        c.JSON(http.StatusOK, forms)
+}
+
+func (h *URLHandler) GetSecurity(c *gin.Context) {
+        id := c.Param("id")
+
+        audit, err := models.GetSecurityAudit(h.db, id)
+        if err == sql.ErrNoRows {
+                c.JSON(http.StatusNotFound, gin.H{"error": "No security audit for this URL yet"})
+                return
+        }
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch security audit"})
+                return
+        }
+
+        c.JSON(http.StatusOK, audit)
+}
+
+// queryBool returns the boolean value of a query parameter, or nil when it
+// is absent or not a bool.
+func queryBool(c *gin.Context, name string) *bool {
+        value, err := strconv.ParseBool(c.Query(name))
+        if err != nil {
+                return nil
+        }
+        return &value
+}
+
+// queryInt returns the integer value of a query parameter, or nil when it is
+// absent or not a number.
+func queryInt(c *gin.Context, name string) *int {
+        value, err := strconv.Atoi(c.Query(name))
+        if err != nil {
+                return nil
+        }
+        return &value
+}
+
+func (h *URLHandler) GetMixedContent(c *gin.Context) {
+        id := c.Param("id")
+
+        items, err := models.GetMixedContent(h.db, id)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch mixed content"})
+                return
+        }
+
+        // Downgrading anchors are listed apart from subresources
+        active := []models.MixedContent{}
+        passive := []models.MixedContent{}
+        insecureLinks := []models.MixedContent{}
+        for _, item := range items {
+                switch item.Kind {
+                case "active":
+                        active = append(active, item)
+                case "passive":
+                        passive = append(passive, item)
+                default:
+                        insecureLinks = append(insecureLinks, item)
+                }
+        }
+
+        c.JSON(http.StatusOK, gin.H{
+                "active":         active,
+                "passive":        passive,
+                "insecure_links": insecureLinks,
+        })
+}
+
+func (h *URLHandler) GetTechnologies(c *gin.Context) {
+        id := c.Param("id")
+
+        technologies, err := models.GetTechnologies(h.db, id)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch technologies"})
+                return
+        }
+
+        c.JSON(http.StatusOK, technologies)
+}
+
+func (h *URLHandler) GetMetrics(c *gin.Context) {
+        id := c.Param("id")
+        limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+        if limit <= 0 {
+                limit = 20
+        }
+
+        metrics, err := models.GetFetchMetrics(h.db, id, limit)
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch metrics"})
+                return
+        }
+
+        c.JSON(http.StatusOK, metrics)
+}
+
+// GetContent returns the main page content as JSON, or as a plain text or
+// Markdown download when format=text or format=markdown is given.
+func (h *URLHandler) GetContent(c *gin.Context) {
+        id := c.Param("id")
+
+        content, err := models.GetPageContent(h.db, id)
+        if err == sql.ErrNoRows {
+                c.JSON(http.StatusNotFound, gin.H{"error": "No content for this URL yet"})
+                return
+        }
+        if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch content"})
+                return
+        }
+
+        switch c.DefaultQuery("format", "json") {
+        case "json":
+                c.JSON(http.StatusOK, content)
+        case "text":
+                c.Header("Content-Disposition", `attachment; filename="`+id+`.txt"`)
+                c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(content.Text))
+        case "markdown":
+                c.Header("Content-Disposition", `attachment; filename="`+id+`.md"`)
+                c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(content.Markdown))
+        default:
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format"})
+        }
+}
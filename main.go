@@ -20,9 +20,24 @@ func main() {
         }
         defer db.Close()
 
+        // Give URLs created before normalization their normalized key
+        if err := services.BackfillNormalizedURLs(db); err != nil {
+                log.Fatal("Failed to normalize URLs:", err)
+        }
+
+        // Analyses cut short by a restart will never finish
+        if err := models.FailRunningSiteAnalyses(db, "Interrupted by a server restart"); err != nil {
+                log.Fatal("Failed to update site analyses:", err)
+        }
+
         // Initialize crawler service
         crawler := services.NewCrawler(db)
 
+        // Start scheduled recrawls
+        scheduler := services.NewScheduler(db, crawler)
+        scheduler.Start()
+        defer scheduler.Stop()
+
         // Initialize handlers
         authHandler := handlers.NewAuthHandler()
         urlHandler := handlers.NewURLHandler(db, crawler)
@@ -59,8 +74,52 @@ func main() {
                         protected.POST("/urls/:id/crawl", urlHandler.StartCrawl)
                         protected.POST("/urls/:id/stop", urlHandler.StopCrawl)
                         protected.GET("/urls/:id/status", urlHandler.GetStatus)
+                        protected.PUT("/urls/:id/schedule", urlHandler.SetSchedule)
+                        protected.DELETE("/urls/:id/schedule", urlHandler.DeleteSchedule)
+                        protected.PUT("/urls/:id/dns-overrides", urlHandler.SetDNSOverrides)
+                        protected.DELETE("/urls/:id/dns-overrides", urlHandler.DeleteDNSOverrides)
+                        protected.PUT("/urls/:id/tls", urlHandler.SetTLSOptions)
+                        protected.PUT("/urls/:id/tags", urlHandler.SetTags)
                         protected.GET("/urls/:id/broken-links", urlHandler.GetBrokenLinks)
+                        protected.GET("/urls/:id/broken-links/export", urlHandler.ExportBrokenLinks)
+                        protected.GET("/urls/:id/outlinks", urlHandler.GetOutlinks)
+                        protected.GET("/urls/:id/inlinks", urlHandler.GetInlinks)
+                        protected.POST("/urls/:id/site-analysis", urlHandler.AnalyzeSite)
+                        protected.GET("/urls/:id/site-analysis", urlHandler.GetSiteAnalysis)
+                        protected.GET("/urls/:id/site-analyses", urlHandler.GetSiteAnalyses)
+                        protected.GET("/urls/:id/site-analyses/:analysisId", urlHandler.GetSiteAnalysis)
+                        protected.GET("/urls/:id/structured-data", urlHandler.GetStructuredData)
+                        protected.GET("/urls/:id/accessibility", urlHandler.GetAccessibility)
+                        protected.GET("/urls/:id/headings", urlHandler.GetHeadings)
+                        protected.GET("/urls/:id/forms", urlHandler.GetForms)
+                        protected.GET("/urls/:id/security", urlHandler.GetSecurity)
+                        protected.GET("/urls/:id/mixed-content", urlHandler.GetMixedContent)
+                        protected.GET("/urls/:id/technologies", urlHandler.GetTechnologies)
+                        protected.GET("/urls/:id/metrics", urlHandler.GetMetrics)
+                        protected.GET("/urls/:id/content", urlHandler.GetContent)
+                        protected.GET("/urls/:id/assertions", urlHandler.GetAssertions)
+                        protected.POST("/urls/:id/assertions", urlHandler.CreateAssertion)
+                        protected.GET("/urls/:id/assertions/history", urlHandler.GetAssertionHistory)
+                        protected.DELETE("/urls/:id/assertions/:assertionId", urlHandler.DeleteAssertion)
+                        protected.GET("/urls/:id/extracted", urlHandler.GetExtractedValues)
+                        protected.GET("/urls/:id/runs", urlHandler.GetCrawlRuns)
+                        protected.GET("/urls/:id/runs/:runId", urlHandler.GetCrawlRun)
+                        protected.GET("/urls/:id/changes", urlHandler.GetChanges)
+                        protected.GET("/urls/:id/diff", urlHandler.GetDiff)
+                        protected.GET("/urls/:id/snapshots", urlHandler.GetSnapshots)
+                        protected.GET("/urls/:id/snapshots/:snapshotId", urlHandler.DownloadSnapshot)
+                        protected.PUT("/urls/:id/snapshots/retention", urlHandler.SetSnapshotRetention)
+                        protected.GET("/urls/:id/warc", urlHandler.ExportURLWARC)
+                        protected.POST("/urls/warc", urlHandler.ExportWARC)
                         protected.POST("/urls/bulk", urlHandler.BulkAction)
+                        protected.POST("/urls/import", urlHandler.ImportURLs)
+                        protected.GET("/urls/export", urlHandler.ExportURLs)
+                        protected.GET("/broken-links/export", urlHandler.ExportAllBrokenLinks)
+                        protected.GET("/links", urlHandler.GetLinks)
+                        protected.GET("/duplicates", urlHandler.GetDuplicates)
+                        protected.GET("/extraction-rules", urlHandler.GetExtractionRules)
+                        protected.POST("/extraction-rules", urlHandler.CreateExtractionRule)
+                        protected.DELETE("/extraction-rules/:ruleId", urlHandler.DeleteExtractionRule)
                 }
         }
 
@@ -0,0 +1,69 @@
+package models
+
+import (
+        "database/sql"
+        "time"
+
+        "github.com/google/uuid"
+)
+
+// AccessibilityFinding is a WCAG problem detected in the static HTML of a
+// crawled page.
+type AccessibilityFinding struct {
+        ID        string    `json:"id"`
+        URLID     string    `json:"url_id"`
+        RuleID    string    `json:"rule_id"`
+        Severity  string    `json:"severity"`
+        Selector  string    `json:"selector"`
+        Message   string    `json:"message"`
+        CreatedAt time.Time `json:"created_at"`
+}
+
+func GetAccessibilityFindings(db *sql.DB, urlID string) ([]AccessibilityFinding, error) {
+        query := `SELECT id, url_id, rule_id, severity, selector, message, created_at
+                          FROM accessibility_findings WHERE url_id = ? ORDER BY created_at, rowid`
+
+        rows, err := db.Query(query, urlID)
+        if err != nil {
+                return nil, err
+        }
+        defer rows.Close()
+
+        findings := []AccessibilityFinding{}
+        for rows.Next() {
+                var f AccessibilityFinding
+                err := rows.Scan(&f.ID, &f.URLID, &f.RuleID, &f.Severity, &f.Selector, &f.Message, &f.CreatedAt)
+                if err != nil {
+                        return nil, err
+                }
+                findings = append(findings, f)
+        }
+
+        return findings, rows.Err()
+}
+
+// ReplaceAccessibilityFindings swaps the stored findings for a URL with the
+// ones from the latest crawl.
+func ReplaceAccessibilityFindings(db *sql.DB, urlID string, findings []AccessibilityFinding) error {
+        tx, err := db.Begin()
+        if err != nil {
+                return err
+        }
+        defer tx.Rollback()
+
+        if _, err := tx.Exec(`DELETE FROM accessibility_findings WHERE url_id = ?`, urlID); err != nil {
+                return err
+        }
+
+        query := `INSERT INTO accessibility_findings (id, url_id, rule_id, severity, selector, message, created_at)
+                          VALUES (?, ?, ?, ?, ?, ?, ?)`
+        now := time.Now()
+        for _, f := range findings {
+                _, err := tx.Exec(query, uuid.New().String(), urlID, f.RuleID, f.Severity, f.Selector, f.Message, now)
+                if err != nil {
+                        return err
+                }
+        }
+
+        return tx.Commit()
+}
@@ -0,0 +1,166 @@
+package models
+
+import (
+        "database/sql"
+        "time"
+
+        "github.com/google/uuid"
+)
+
+// Assertion is a check evaluated against a URL on every crawl, e.g. that
+// the page contains some text or answers with a given status code.
+type Assertion struct {
+        ID         string           `json:"id"`
+        URLID      string           `json:"url_id"`
+        Type       string           `json:"type"`
+        Value      string           `json:"value"`
+        CreatedAt  time.Time        `json:"created_at"`
+        LastResult *AssertionResult `json:"last_result"`
+}
+
+// AssertionResult is the outcome of one assertion in one crawl run.
+type AssertionResult struct {
+        ID          string    `json:"id"`
+        AssertionID string    `json:"assertion_id"`
+        URLID       string    `json:"url_id"`
+        RunID       string    `json:"run_id"`
+        Passed      bool      `json:"passed"`
+        Message     string    `json:"message"`
+        CreatedAt   time.Time `json:"created_at"`
+}
+
+// GetAssertions returns the assertions of a URL with their latest result.
+func GetAssertions(db *sql.DB, urlID string) ([]Assertion, error) {
+        query := `SELECT a.id, a.url_id, a.type, a.value, a.created_at,
+                          r.id, r.run_id, r.passed, r.message, r.created_at
+                          FROM assertions a
+                          LEFT JOIN assertion_results r ON r.id = (
+                                  SELECT id FROM assertion_results WHERE assertion_id = a.id ORDER BY created_at DESC LIMIT 1)
+                          WHERE a.url_id = ? ORDER BY a.created_at`
+
+        rows, err := db.Query(query, urlID)
+        if err != nil {
+                return nil, err
+        }
+        defer rows.Close()
+
+        assertions := []Assertion{}
+        for rows.Next() {
+                var a Assertion
+                var (
+                        resultID, runID, message sql.NullString
+                        passed                   sql.NullBool
+                        resultAt                 sql.NullTime
+                )
+                err := rows.Scan(&a.ID, &a.URLID, &a.Type, &a.Value, &a.CreatedAt,
+                        &resultID, &runID, &passed, &message, &resultAt)
+                if err != nil {
+                        return nil, err
+                }
+                if resultID.Valid {
+                        a.LastResult = &AssertionResult{
+                                ID:          resultID.String,
+                                AssertionID: a.ID,
+                                URLID:       a.URLID,
+                                RunID:       runID.String,
+                                Passed:      passed.Bool,
+                                Message:     message.String,
+                                CreatedAt:   resultAt.Time,
+                        }
+                }
+                assertions = append(assertions, a)
+        }
+
+        return assertions, rows.Err()
+}
+
+func CreateAssertion(db *sql.DB, urlID, assertionType, value string) (*Assertion, error) {
+        a := Assertion{
+                ID:        uuid.New().String(),
+                URLID:     urlID,
+                Type:      assertionType,
+                Value:     value,
+                CreatedAt: time.Now(),
+        }
+
+        query := `INSERT INTO assertions (id, url_id, type, value, created_at) VALUES (?, ?, ?, ?, ?)`
+        if _, err := db.Exec(query, a.ID, a.URLID, a.Type, a.Value, a.CreatedAt); err != nil {
+                return nil, err
+        }
+
+        return &a, nil
+}
+
+// DeleteAssertion removes an assertion and its history. When it was the
+// URL's last assertion, the URL's assertion state is cleared.
+func DeleteAssertion(db *sql.DB, urlID, id string) error {
+        tx, err := db.Begin()
+        if err != nil {
+                return err
+        }
+        defer tx.Rollback()
+
+        result, err := tx.Exec(`DELETE FROM assertions WHERE id = ? AND url_id = ?`, id, urlID)
+        if err != nil {
+                return err
+        }
+        if n, _ := result.RowsAffected(); n == 0 {
+                return sql.ErrNoRows
+        }
+        if _, err := tx.Exec(`DELETE FROM assertion_results WHERE assertion_id = ?`, id); err != nil {
+                return err
+        }
+
+        query := `UPDATE urls SET assertion_status = NULL, assertions_failed = 0
+                          WHERE id = ? AND NOT EXISTS (SELECT 1 FROM assertions WHERE url_id = ?)`
+        if _, err := tx.Exec(query, urlID, urlID); err != nil {
+                return err
+        }
+
+        return tx.Commit()
+}
+
+// CreateAssertionResults stores the outcome of a crawl's assertions.
+func CreateAssertionResults(db *sql.DB, urlID, runID string, results []AssertionResult) error {
+        tx, err := db.Begin()
+        if err != nil {
+                return err
+        }
+        defer tx.Rollback()
+
+        now := time.Now()
+        query := `INSERT INTO assertion_results (id, assertion_id, url_id, run_id, passed, message, created_at)
+                          VALUES (?, ?, ?, ?, ?, ?, ?)`
+        for _, r := range results {
+                if _, err := tx.Exec(query, uuid.New().String(), r.AssertionID, urlID, runID, r.Passed, r.Message, now); err != nil {
+                        return err
+                }
+        }
+
+        return tx.Commit()
+}
+
+// GetAssertionHistory returns the most recent assertion results of a URL,
+// newest first.
+func GetAssertionHistory(db *sql.DB, urlID string, limit int) ([]AssertionResult, error) {
+        query := `SELECT id, assertion_id, url_id, run_id, passed, message, created_at
+                          FROM assertion_results WHERE url_id = ? ORDER BY created_at DESC LIMIT ?`
+
+        rows, err := db.Query(query, urlID, limit)
+        if err != nil {
+                return nil, err
+        }
+        defer rows.Close()
+
+        results := []AssertionResult{}
+        for rows.Next() {
+                var r AssertionResult
+                err := rows.Scan(&r.ID, &r.AssertionID, &r.URLID, &r.RunID, &r.Passed, &r.Message, &r.CreatedAt)
+                if err != nil {
+                        return nil, err
+                }
+                results = append(results, r)
+        }
+
+        return results, rows.Err()
+}
@@ -0,0 +1,55 @@
+package models
+
+import "testing"
+
+func TestAssertionResultsAreDeleted(t *testing.T) {
+        db := openTestDB(t)
+        u := createTestURL(t, db, "https://example.com/")
+
+        create := func() *Assertion {
+                t.Helper()
+                a, err := CreateAssertion(db, u.ID, "status_equals", "200")
+                if err != nil {
+                        t.Fatal(err)
+                }
+                results := []AssertionResult{{AssertionID: a.ID, Passed: true, Message: "ok"}}
+                if err := CreateAssertionResults(db, u.ID, "run", results); err != nil {
+                        t.Fatal(err)
+                }
+                return a
+        }
+        results := func(query string, args ...interface{}) int {
+                t.Helper()
+                var n int
+                if err := db.QueryRow(`SELECT COUNT(*) FROM assertion_results WHERE `+query, args...).Scan(&n); err != nil {
+                        t.Fatal(err)
+                }
+                return n
+        }
+
+        deleted := create()
+        kept := create()
+        if err := DeleteAssertion(db, u.ID, deleted.ID); err != nil {
+                t.Fatal(err)
+        }
+        if n := results(`assertion_id = ?`, deleted.ID); n != 0 {
+                t.Errorf("deleted assertion still has %d results", n)
+        }
+        if n := results(`assertion_id = ?`, kept.ID); n != 1 {
+                t.Errorf("other assertion has %d results, want 1", n)
+        }
+
+        if err := DeleteURL(db, u.ID); err != nil {
+                t.Fatal(err)
+        }
+        if n := results(`url_id = ?`, u.ID); n != 0 {
+                t.Errorf("deleted URL still has %d assertion results", n)
+        }
+        var n int
+        if err := db.QueryRow(`SELECT COUNT(*) FROM assertions WHERE url_id = ?`, u.ID).Scan(&n); err != nil {
+                t.Fatal(err)
+        }
+        if n != 0 {
+                t.Errorf("deleted URL still has %d assertions", n)
+        }
+}
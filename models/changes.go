@@ -0,0 +1,164 @@
+package models
+
+import (
+        "database/sql"
+        "encoding/json"
+        "time"
+
+        "github.com/google/uuid"
+)
+
+// PageState is what a crawl saw of a page, kept with the run so consecutive
+// crawls can be compared.
+type PageState struct {
+        Title    string            `json:"title"`
+        Headings []string          `json:"headings"`
+        Links    []string          `json:"links"`
+        Meta     map[string]string `json:"meta"`
+        Text     string            `json:"text"`
+}
+
+// ContentDiff is the structured difference between two page states.
+type ContentDiff struct {
+        TitleChanged    bool         `json:"title_changed"`
+        OldTitle        string       `json:"old_title,omitempty"`
+        NewTitle        string       `json:"new_title,omitempty"`
+        HeadingsAdded   []string     `json:"headings_added"`
+        HeadingsRemoved []string     `json:"headings_removed"`
+        LinksAdded      []string     `json:"links_added"`
+        LinksRemoved    []string     `json:"links_removed"`
+        MetaChanges     []MetaChange `json:"meta_changes"`
+        TextChanges     []TextChange `json:"text_changes"`
+        Similarity      float64      `json:"similarity"`
+        Significant     bool         `json:"significant"`
+}
+
+type MetaChange struct {
+        Name string `json:"name"`
+        Old  string `json:"old"`
+        New  string `json:"new"`
+}
+
+// TextChange is a paragraph of body text that was added or removed.
+type TextChange struct {
+        Op   string `json:"op"`
+        Text string `json:"text"`
+}
+
+// ContentChange is the stored comparison of a run with the run before it.
+type ContentChange struct {
+        ID            string      `json:"id"`
+        URLID         string      `json:"url_id"`
+        RunID         string      `json:"run_id"`
+        PreviousRunID string      `json:"previous_run_id"`
+        Similarity    float64     `json:"similarity"`
+        Significant   bool        `json:"significant"`
+        Diff          ContentDiff `json:"diff"`
+        CreatedAt     time.Time   `json:"created_at"`
+}
+
+// SaveRunPageState stores the page state seen by a crawl run.
+func SaveRunPageState(db *sql.DB, runID string, state PageState) error {
+        encoded, err := json.Marshal(state)
+        if err != nil {
+                return err
+        }
+
+        _, err = db.Exec(`UPDATE crawl_runs SET page_state = ? WHERE id = ?`, string(encoded), runID)
+        return err
+}
+
+// GetRunPageState returns the page state stored with a run.
+func GetRunPageState(db *sql.DB, runID string) (*PageState, error) {
+        var encoded sql.NullString
+        err := db.QueryRow(`SELECT page_state FROM crawl_runs WHERE id = ?`, runID).Scan(&encoded)
+        if err != nil {
+                return nil, err
+        }
+        if !encoded.Valid {
+                return nil, sql.ErrNoRows
+        }
+
+        var state PageState
+        if err := json.Unmarshal([]byte(encoded.String), &state); err != nil {
+                return nil, err
+        }
+
+        return &state, nil
+}
+
+// GetPreviousRunID returns the most recent completed run of a URL that has a
+// page state, other than the given run.
+func GetPreviousRunID(db *sql.DB, urlID, runID string) (string, error) {
+        query := `SELECT id FROM crawl_runs
+                          WHERE url_id = ? AND id != ? AND status = 'completed' AND page_state IS NOT NULL
+                          ORDER BY started_at DESC LIMIT 1`
+
+        var id string
+        err := db.QueryRow(query, urlID, runID).Scan(&id)
+        return id, err
+}
+
+// CreateContentChange stores the comparison of a run with the previous one
+// and, when the change is significant, marks when the URL last changed.
+func CreateContentChange(db *sql.DB, urlID, runID, previousRunID string, diff ContentDiff) error {
+        encoded, err := json.Marshal(diff)
+        if err != nil {
+                return err
+        }
+
+        tx, err := db.Begin()
+        if err != nil {
+                return err
+        }
+        defer tx.Rollback()
+
+        now := time.Now()
+        query := `INSERT INTO content_changes (id, url_id, run_id, previous_run_id, similarity, significant, diff, created_at)
+                          VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+        if _, err := tx.Exec(query, uuid.New().String(), urlID, runID, previousRunID, diff.Similarity,
+                diff.Significant, string(encoded), now); err != nil {
+                return err
+        }
+        if diff.Significant {
+                if _, err := tx.Exec(`UPDATE urls SET last_changed_at = ? WHERE id = ?`, now, urlID); err != nil {
+                        return err
+                }
+        }
+
+        return tx.Commit()
+}
+
+// GetContentChanges returns the most recent comparisons of a URL, newest
+// first. With significantOnly, unchanged and minor crawls are skipped.
+func GetContentChanges(db *sql.DB, urlID string, significantOnly bool, limit int) ([]ContentChange, error) {
+        query := `SELECT id, url_id, run_id, previous_run_id, similarity, significant, diff, created_at
+                          FROM content_changes WHERE url_id = ?`
+        if significantOnly {
+                query += ` AND significant = 1`
+        }
+        query += ` ORDER BY created_at DESC LIMIT ?`
+
+        rows, err := db.Query(query, urlID, limit)
+        if err != nil {
+                return nil, err
+        }
+        defer rows.Close()
+
+        changes := []ContentChange{}
+        for rows.Next() {
+                var change ContentChange
+                var diff string
+                err := rows.Scan(&change.ID, &change.URLID, &change.RunID, &change.PreviousRunID,
+                        &change.Similarity, &change.Significant, &diff, &change.CreatedAt)
+                if err != nil {
+                        return nil, err
+                }
+                if err := json.Unmarshal([]byte(diff), &change.Diff); err != nil {
+                        return nil, err
+                }
+                changes = append(changes, change)
+        }
+
+        return changes, rows.Err()
+}
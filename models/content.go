@@ -0,0 +1,48 @@
+package models
+
+import (
+        "database/sql"
+        "time"
+)
+
+// PageContent is the main body text of a crawled page with boilerplate
+// removed, along with statistics computed from it.
+type PageContent struct {
+        URLID              string    `json:"url_id"`
+        Text               string    `json:"text"`
+        Markdown           string    `json:"markdown"`
+        WordCount          int       `json:"word_count"`
+        ReadingTimeSeconds int       `json:"reading_time_seconds"`
+        Language           *string   `json:"language"`
+        FleschReadingEase  *float64  `json:"flesch_reading_ease"`
+        FleschKincaidGrade *float64  `json:"flesch_kincaid_grade"`
+        UpdatedAt          time.Time `json:"updated_at"`
+}
+
+func GetPageContent(db *sql.DB, urlID string) (*PageContent, error) {
+        query := `SELECT url_id, text, markdown, word_count, reading_time_seconds, language,
+                          flesch_reading_ease, flesch_kincaid_grade, updated_at
+                          FROM page_content WHERE url_id = ?`
+
+        var content PageContent
+        err := db.QueryRow(query, urlID).Scan(&content.URLID, &content.Text, &content.Markdown,
+                &content.WordCount, &content.ReadingTimeSeconds, &content.Language,
+                &content.FleschReadingEase, &content.FleschKincaidGrade, &content.UpdatedAt)
+        if err != nil {
+                return nil, err
+        }
+
+        return &content, nil
+}
+
+// SavePageContent stores the main content of the latest crawl, replacing
+// the previous one.
+func SavePageContent(db *sql.DB, urlID string, content PageContent) error {
+        query := `INSERT OR REPLACE INTO page_content (url_id, text, markdown, word_count, reading_time_seconds,
+                          language, flesch_reading_ease, flesch_kincaid_grade, updated_at)
+                          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+        _, err := db.Exec(query, urlID, content.Text, content.Markdown, content.WordCount,
+                content.ReadingTimeSeconds, content.Language, content.FleschReadingEase,
+                content.FleschKincaidGrade, time.Now())
+        return err
+}
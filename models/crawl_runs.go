@@ -0,0 +1,164 @@
+package models
+
+import (
+        "database/sql"
+        "encoding/json"
+        "time"
+
+        "github.com/google/uuid"
+)
+
+// CrawlRun is the record of a single crawl of a URL. Metrics holds the
+// values extracted by that crawl, in the same shape as the urls row.
+type CrawlRun struct {
+        ID           string          `json:"id"`
+        URLID        string          `json:"url_id"`
+        Status       string          `json:"status"`
+        StartedAt    time.Time       `json:"started_at"`
+        FinishedAt   *time.Time      `json:"finished_at"`
+        DurationMs   *int64          `json:"duration_ms"`
+        ErrorMessage *string         `json:"error_message"`
+        TLSInsecure  bool            `json:"tls_insecure"`
+        Metrics      json.RawMessage `json:"metrics"`
+        FetchMetrics *FetchMetrics   `json:"fetch_metrics,omitempty"`
+        BrokenLinks  []BrokenLink    `json:"broken_links,omitempty"`
+}
+
+const crawlRunColumns = `id, url_id, status, started_at, finished_at, duration_ms, error_message, tls_insecure, metrics`
+
+func scanCrawlRun(row rowScanner) (*CrawlRun, error) {
+        var run CrawlRun
+        var metrics sql.NullString
+        err := row.Scan(&run.ID, &run.URLID, &run.Status, &run.StartedAt, &run.FinishedAt,
+                &run.DurationMs, &run.ErrorMessage, &run.TLSInsecure, &metrics)
+        if err != nil {
+                return nil, err
+        }
+        if metrics.Valid {
+                run.Metrics = json.RawMessage(metrics.String)
+        }
+
+        return &run, nil
+}
+
+// StartCrawlRun records the start of a crawl. The URL keeps pointing at its
+// previous run until this one completes.
+func StartCrawlRun(db *sql.DB, urlID string) (string, error) {
+        id := uuid.New().String()
+
+        query := `INSERT INTO crawl_runs (id, url_id, status, started_at) VALUES (?, ?, 'crawling', ?)`
+        if _, err := db.Exec(query, id, urlID, time.Now()); err != nil {
+                return "", err
+        }
+
+        return id, nil
+}
+
+// CompleteCrawlRun marks a run as completed, stores its extracted data and
+// makes it the latest run of its URL unless a newer run already is.
+func CompleteCrawlRun(db *sql.DB, runID string, data map[string]interface{}) error {
+        metrics, err := json.Marshal(data)
+        if err != nil {
+                return err
+        }
+
+        tx, err := db.Begin()
+        if err != nil {
+                return err
+        }
+        defer tx.Rollback()
+
+        if err := finishCrawlRun(tx, runID, "completed", nil, string(metrics)); err != nil {
+                return err
+        }
+        // A run that started before the URL's latest one, e.g. one that kept
+        // going after being stopped, must not replace it
+        query := `UPDATE urls SET latest_run_id = ? WHERE id = (SELECT url_id FROM crawl_runs WHERE id = ?)
+                          AND COALESCE((SELECT started_at FROM crawl_runs WHERE id = urls.latest_run_id), '') <=
+                              (SELECT started_at FROM crawl_runs WHERE id = ?)`
+        if _, err := tx.Exec(query, runID, runID, runID); err != nil {
+                return err
+        }
+
+        return tx.Commit()
+}
+
+// MarkCrawlRunInsecure flags a run that accepted invalid TLS certificates.
+func MarkCrawlRunInsecure(db *sql.DB, runID string) error {
+        _, err := db.Exec(`UPDATE crawl_runs SET tls_insecure = TRUE WHERE id = ?`, runID)
+        return err
+}
+
+// FinishCrawlRun closes an unfinished run with the given status, e.g. when
+// the crawl fails or is stopped. The URL keeps its previous latest run.
+func FinishCrawlRun(db *sql.DB, runID, status string, errorMsg *string) error {
+        return finishCrawlRun(db, runID, status, errorMsg, nil)
+}
+
+// runFinisher is the part of *sql.DB and *sql.Tx used to close a run.
+type runFinisher interface {
+        Exec(query string, args ...interface{}) (sql.Result, error)
+        QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func finishCrawlRun(db runFinisher, runID, status string, errorMsg *string, metrics interface{}) error {
+        var startedAt time.Time
+        err := db.QueryRow(`SELECT started_at FROM crawl_runs WHERE id = ? AND finished_at IS NULL`, runID).Scan(&startedAt)
+        if err == sql.ErrNoRows {
+                return nil
+        }
+        if err != nil {
+                return err
+        }
+
+        now := time.Now()
+        query := `UPDATE crawl_runs SET status = ?, finished_at = ?, duration_ms = ?, error_message = ?,
+                          metrics = COALESCE(?, metrics) WHERE id = ?`
+        _, err = db.Exec(query, status, now, now.Sub(startedAt).Milliseconds(), errorMsg, metrics, runID)
+        return err
+}
+
+// GetCrawlRuns returns the most recent runs of a URL, newest first.
+func GetCrawlRuns(db *sql.DB, urlID string, limit int) ([]CrawlRun, error) {
+        query := `SELECT ` + crawlRunColumns + ` FROM crawl_runs WHERE url_id = ? ORDER BY started_at DESC LIMIT ?`
+
+        rows, err := db.Query(query, urlID, limit)
+        if err != nil {
+                return nil, err
+        }
+        defer rows.Close()
+
+        runs := []CrawlRun{}
+        for rows.Next() {
+                run, err := scanCrawlRun(rows)
+                if err != nil {
+                        return nil, err
+                }
+                runs = append(runs, *run)
+        }
+
+        return runs, rows.Err()
+}
+
+// GetCrawlRun returns a single run with its fetch metrics and broken links.
+func GetCrawlRun(db *sql.DB, id string) (*CrawlRun, error) {
+        query := `SELECT ` + crawlRunColumns + ` FROM crawl_runs WHERE id = ?`
+
+        run, err := scanCrawlRun(db.QueryRow(query, id))
+        if err != nil {
+                return nil, err
+        }
+
+        metrics, err := getRunFetchMetrics(db, id)
+        if err != nil && err != sql.ErrNoRows {
+                return nil, err
+        }
+        run.FetchMetrics = metrics
+
+        run.BrokenLinks, err = GetRunBrokenLinks(db, id)
+        if err != nil {
+                return nil, err
+        }
+
+        return run, nil
+}
@@ -0,0 +1,54 @@
+package models
+
+import "testing"
+
+func TestCompleteCrawlRunKeepsNewerLatestRun(t *testing.T) {
+        db := openTestDB(t)
+        u := createTestURL(t, db, "https://example.com/")
+
+        start := func() string {
+                t.Helper()
+                runID, err := StartCrawlRun(db, u.ID)
+                if err != nil {
+                        t.Fatal(err)
+                }
+                return runID
+        }
+        complete := func(runID string) {
+                t.Helper()
+                if err := CompleteCrawlRun(db, runID, map[string]interface{}{}); err != nil {
+                        t.Fatal(err)
+                }
+        }
+        latest := func() string {
+                t.Helper()
+                url, err := GetURLByID(db, u.ID)
+                if err != nil {
+                        t.Fatal(err)
+                }
+                if url.LatestRunID == nil {
+                        return ""
+                }
+                return *url.LatestRunID
+        }
+
+        older := start()
+        newer := start()
+
+        complete(newer)
+        if got := latest(); got != newer {
+                t.Fatalf("latest run = %q, want the newer run", got)
+        }
+
+        // The older run finishing last does not move the URL back to it
+        complete(older)
+        if got := latest(); got != newer {
+                t.Errorf("latest run = %q after the older run completed, want the newer run", got)
+        }
+
+        next := start()
+        complete(next)
+        if got := latest(); got != next {
+                t.Errorf("latest run = %q, want the next run", got)
+        }
+}
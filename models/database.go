@@ -3,6 +3,7 @@ package models
 import (
         "database/sql"
         "os"
+        "strings"
 
         _ "github.com/mattn/go-sqlite3"
 )
@@ -14,7 +15,7 @@ func InitDB() (*sql.DB, error) {
                 dbPath = "./web_crawler.db"
         }
 
-        db, err := sql.Open("sqlite3", dbPath)
+        db, err := sql.Open("sqlite3", dataSourceName(dbPath))
         if err != nil {
                 return nil, err
         }
@@ -28,9 +29,33 @@ func InitDB() (*sql.DB, error) {
                 return nil, err
         }
 
+        // Add columns introduced after a database file was first created
+        if err := migrateColumns(db); err != nil {
+                return nil, err
+        }
+
+        // Remove rows left behind by deletes from before foreign keys were
+        // enforced
+        if err := deleteOrphans(db); err != nil {
+                return nil, err
+        }
+
         return db, nil
 }
 
+// dataSourceName adds the connection options to the database path. Foreign
+// keys are enforced on every connection so ON DELETE CASCADE takes effect.
+// WAL mode lets long reads such as exports run alongside crawl writes, and
+// the busy timeout makes a writer wait for another one instead of failing
+// with "database is locked".
+func dataSourceName(dbPath string) string {
+        separator := "?"
+        if strings.Contains(dbPath, "?") {
+                separator = "&"
+        }
+        return dbPath + separator + "_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000"
+}
+
 func createTables(db *sql.DB) error {
         queries := []string{
                 `CREATE TABLE IF NOT EXISTS urls (
@@ -51,17 +76,310 @@ func createTables(db *sql.DB) error {
                         external_links INT DEFAULT 0,
                         broken_links INT DEFAULT 0,
                         has_login_form BOOLEAN DEFAULT FALSE,
-                        error_message TEXT
+                        error_message TEXT,
+                        structured_data_count INT DEFAULT 0,
+                        structured_data_errors INT DEFAULT 0,
+                        accessibility_errors INT DEFAULT 0,
+                        accessibility_warnings INT DEFAULT 0,
+                        heading_issues INT DEFAULT 0,
+                        login_form_confidence REAL DEFAULT 0,
+                        login_form_type VARCHAR(30),
+                        form_count INT DEFAULT 0,
+                        insecure_forms INT DEFAULT 0,
+                        security_score INT NULL,
+                        mixed_content_active INT DEFAULT 0,
+                        mixed_content_passive INT DEFAULT 0,
+                        insecure_links INT DEFAULT 0,
+                        word_count INT DEFAULT 0,
+                        reading_time_seconds INT DEFAULT 0,
+                        language VARCHAR(10),
+                        flesch_reading_ease REAL NULL,
+                        snapshot_retention INT NULL,
+                        latest_run_id VARCHAR(36) NULL,
+                        significant_change BOOLEAN DEFAULT FALSE,
+                        change_similarity REAL NULL,
+                        last_changed_at TIMESTAMP NULL,
+                        schedule_cron VARCHAR(100) NULL,
+                        schedule_interval_seconds INT NULL,
+                        schedule_timezone VARCHAR(64) NULL,
+                        schedule_jitter_seconds INT DEFAULT 0,
+                        next_scheduled_at TIMESTAMP NULL,
+                        last_scheduled_at TIMESTAMP NULL,
+                        assertion_status VARCHAR(20) NULL,
+                        assertions_failed INT DEFAULT 0,
+                        extracted TEXT,
+                        normalized_url TEXT NULL,
+                        dns_overrides TEXT NULL,
+                        tls_insecure BOOLEAN DEFAULT FALSE,
+                        tags TEXT NULL
                 )`,
                 `CREATE TABLE IF NOT EXISTS broken_links (
                         id VARCHAR(36) PRIMARY KEY,
                         url_id VARCHAR(36) NOT NULL,
+                        run_id VARCHAR(36) NULL,
                         link_url TEXT NOT NULL,
                         status_code INT NOT NULL,
                         error_message TEXT,
                         created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
                         FOREIGN KEY (url_id) REFERENCES urls(id) ON DELETE CASCADE
                 )`,
+                `CREATE TABLE IF NOT EXISTS structured_data (
+                        id VARCHAR(36) PRIMARY KEY,
+                        url_id VARCHAR(36) NOT NULL,
+                        format VARCHAR(20) NOT NULL,
+                        type TEXT,
+                        data TEXT NOT NULL,
+                        errors TEXT,
+                        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        FOREIGN KEY (url_id) REFERENCES urls(id) ON DELETE CASCADE
+                )`,
+                `CREATE TABLE IF NOT EXISTS accessibility_findings (
+                        id VARCHAR(36) PRIMARY KEY,
+                        url_id VARCHAR(36) NOT NULL,
+                        rule_id VARCHAR(50) NOT NULL,
+                        severity VARCHAR(20) NOT NULL,
+                        selector TEXT NOT NULL,
+                        message TEXT NOT NULL,
+                        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        FOREIGN KEY (url_id) REFERENCES urls(id) ON DELETE CASCADE
+                )`,
+                `CREATE TABLE IF NOT EXISTS headings (
+                        id VARCHAR(36) PRIMARY KEY,
+                        url_id VARCHAR(36) NOT NULL,
+                        run_id VARCHAR(36) NULL,
+                        position INT NOT NULL,
+                        level INT NOT NULL,
+                        text TEXT NOT NULL,
+                        issues TEXT,
+                        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        FOREIGN KEY (url_id) REFERENCES urls(id) ON DELETE CASCADE
+                )`,
+                `CREATE TABLE IF NOT EXISTS forms (
+                        id VARCHAR(36) PRIMARY KEY,
+                        url_id VARCHAR(36) NOT NULL,
+                        position INT NOT NULL,
+                        selector TEXT NOT NULL,
+                        method VARCHAR(10) NOT NULL,
+                        action TEXT NOT NULL,
+                        fields TEXT NOT NULL,
+                        has_csrf_token BOOLEAN DEFAULT FALSE,
+                        findings TEXT,
+                        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        FOREIGN KEY (url_id) REFERENCES urls(id) ON DELETE CASCADE
+                )`,
+                `CREATE TABLE IF NOT EXISTS security_audits (
+                        url_id VARCHAR(36) PRIMARY KEY,
+                        score INT NOT NULL,
+                        headers TEXT NOT NULL,
+                        csp TEXT NOT NULL,
+                        findings TEXT NOT NULL,
+                        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        FOREIGN KEY (url_id) REFERENCES urls(id) ON DELETE CASCADE
+                )`,
+                `CREATE TABLE IF NOT EXISTS mixed_content (
+                        id VARCHAR(36) PRIMARY KEY,
+                        url_id VARCHAR(36) NOT NULL,
+                        kind VARCHAR(20) NOT NULL,
+                        element VARCHAR(20) NOT NULL,
+                        attribute VARCHAR(20) NOT NULL,
+                        resource_url TEXT NOT NULL,
+                        selector TEXT NOT NULL,
+                        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        FOREIGN KEY (url_id) REFERENCES urls(id) ON DELETE CASCADE
+                )`,
+                `CREATE TABLE IF NOT EXISTS technologies (
+                        id VARCHAR(36) PRIMARY KEY,
+                        url_id VARCHAR(36) NOT NULL,
+                        name VARCHAR(100) NOT NULL,
+                        category VARCHAR(50) NOT NULL,
+                        version VARCHAR(50),
+                        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        FOREIGN KEY (url_id) REFERENCES urls(id) ON DELETE CASCADE
+                )`,
+                `CREATE INDEX IF NOT EXISTS idx_technologies_name ON technologies(name)`,
+                `CREATE TABLE IF NOT EXISTS fetch_metrics (
+                        id VARCHAR(36) PRIMARY KEY,
+                        url_id VARCHAR(36) NOT NULL,
+                        run_id VARCHAR(36) NULL,
+                        status_code INT NOT NULL,
+                        dns_ms REAL DEFAULT 0,
+                        connect_ms REAL DEFAULT 0,
+                        tls_ms REAL DEFAULT 0,
+                        ttfb_ms REAL DEFAULT 0,
+                        download_ms REAL DEFAULT 0,
+                        total_ms REAL DEFAULT 0,
+                        compressed_bytes INT DEFAULT 0,
+                        uncompressed_bytes INT DEFAULT 0,
+                        content_encoding VARCHAR(20),
+                        subresource_count INT NULL,
+                        subresource_bytes INT NULL,
+                        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        FOREIGN KEY (url_id) REFERENCES urls(id) ON DELETE CASCADE
+                )`,
+                `CREATE INDEX IF NOT EXISTS idx_fetch_metrics_url_id ON fetch_metrics(url_id, created_at)`,
+                `CREATE TABLE IF NOT EXISTS page_content (
+                        url_id VARCHAR(36) PRIMARY KEY,
+                        text TEXT NOT NULL,
+                        markdown TEXT NOT NULL,
+                        word_count INT DEFAULT 0,
+                        reading_time_seconds INT DEFAULT 0,
+                        language VARCHAR(10),
+                        flesch_reading_ease REAL NULL,
+                        flesch_kincaid_grade REAL NULL,
+                        updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        FOREIGN KEY (url_id) REFERENCES urls(id) ON DELETE CASCADE
+                )`,
+                `CREATE TABLE IF NOT EXISTS page_fingerprints (
+                        url_id VARCHAR(36) PRIMARY KEY,
+                        title TEXT NOT NULL,
+                        description TEXT NOT NULL,
+                        content_hash VARCHAR(64) NOT NULL,
+                        simhash VARCHAR(16) NOT NULL,
+                        word_count INT DEFAULT 0,
+                        updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        FOREIGN KEY (url_id) REFERENCES urls(id) ON DELETE CASCADE
+                )`,
+                `CREATE TABLE IF NOT EXISTS snapshot_blobs (
+                        hash VARCHAR(64) PRIMARY KEY,
+                        data BLOB NOT NULL,
+                        size INT NOT NULL,
+                        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+                )`,
+                `CREATE TABLE IF NOT EXISTS snapshots (
+                        id VARCHAR(36) PRIMARY KEY,
+                        url_id VARCHAR(36) NOT NULL,
+                        target_url TEXT NOT NULL,
+                        protocol VARCHAR(20) NOT NULL,
+                        status_code INT NOT NULL,
+                        status VARCHAR(100) NOT NULL,
+                        headers TEXT NOT NULL,
+                        content_hash VARCHAR(64) NOT NULL,
+                        body_size INT NOT NULL,
+                        fetched_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        FOREIGN KEY (url_id) REFERENCES urls(id) ON DELETE CASCADE,
+                        FOREIGN KEY (content_hash) REFERENCES snapshot_blobs(hash)
+                )`,
+                `CREATE INDEX IF NOT EXISTS idx_snapshots_url_id ON snapshots(url_id, fetched_at)`,
+                `CREATE TABLE IF NOT EXISTS crawl_runs (
+                        id VARCHAR(36) PRIMARY KEY,
+                        url_id VARCHAR(36) NOT NULL,
+                        status VARCHAR(20) NOT NULL,
+                        started_at TIMESTAMP NOT NULL,
+                        finished_at TIMESTAMP NULL,
+                        duration_ms INT NULL,
+                        error_message TEXT,
+                        metrics TEXT,
+                        page_state TEXT,
+                        tls_insecure BOOLEAN DEFAULT FALSE,
+                        FOREIGN KEY (url_id) REFERENCES urls(id) ON DELETE CASCADE
+                )`,
+                `CREATE INDEX IF NOT EXISTS idx_crawl_runs_url_id ON crawl_runs(url_id, started_at)`,
+                `CREATE TABLE IF NOT EXISTS content_changes (
+                        id VARCHAR(36) PRIMARY KEY,
+                        url_id VARCHAR(36) NOT NULL,
+                        run_id VARCHAR(36) NOT NULL,
+                        previous_run_id VARCHAR(36) NOT NULL,
+                        similarity REAL NOT NULL,
+                        significant BOOLEAN DEFAULT FALSE,
+                        diff TEXT NOT NULL,
+                        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        FOREIGN KEY (url_id) REFERENCES urls(id) ON DELETE CASCADE
+                )`,
+                `CREATE INDEX IF NOT EXISTS idx_content_changes_url_id ON content_changes(url_id, created_at)`,
+                `CREATE TABLE IF NOT EXISTS assertions (
+                        id VARCHAR(36) PRIMARY KEY,
+                        url_id VARCHAR(36) NOT NULL,
+                        type VARCHAR(30) NOT NULL,
+                        value TEXT NOT NULL,
+                        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        FOREIGN KEY (url_id) REFERENCES urls(id) ON DELETE CASCADE
+                )`,
+                `CREATE TABLE IF NOT EXISTS assertion_results (
+                        id VARCHAR(36) PRIMARY KEY,
+                        assertion_id VARCHAR(36) NOT NULL,
+                        url_id VARCHAR(36) NOT NULL,
+                        run_id VARCHAR(36) NOT NULL,
+                        passed BOOLEAN NOT NULL,
+                        message TEXT NOT NULL,
+                        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        FOREIGN KEY (assertion_id) REFERENCES assertions(id) ON DELETE CASCADE,
+                        FOREIGN KEY (url_id) REFERENCES urls(id) ON DELETE CASCADE
+                )`,
+                `CREATE INDEX IF NOT EXISTS idx_assertion_results_url_id ON assertion_results(url_id, created_at)`,
+                `CREATE INDEX IF NOT EXISTS idx_assertion_results_assertion_id ON assertion_results(assertion_id, created_at)`,
+                `CREATE TABLE IF NOT EXISTS extraction_rules (
+                        id VARCHAR(36) PRIMARY KEY,
+                        name VARCHAR(100) NOT NULL,
+                        url_id VARCHAR(36) NULL,
+                        url_pattern TEXT NULL,
+                        method VARCHAR(10) NOT NULL,
+                        expression TEXT NOT NULL,
+                        attribute VARCHAR(100) DEFAULT '',
+                        value_type VARCHAR(20) NOT NULL,
+                        multiple BOOLEAN DEFAULT FALSE,
+                        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        FOREIGN KEY (url_id) REFERENCES urls(id) ON DELETE CASCADE
+                )`,
+                `CREATE TABLE IF NOT EXISTS extracted_values (
+                        id VARCHAR(36) PRIMARY KEY,
+                        rule_id VARCHAR(36) NOT NULL,
+                        url_id VARCHAR(36) NOT NULL,
+                        run_id VARCHAR(36) NOT NULL,
+                        name VARCHAR(100) NOT NULL,
+                        value_type VARCHAR(20) NOT NULL,
+                        value TEXT NOT NULL,
+                        error TEXT,
+                        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        FOREIGN KEY (url_id) REFERENCES urls(id) ON DELETE CASCADE
+                )`,
+                `CREATE INDEX IF NOT EXISTS idx_extracted_values_url_id ON extracted_values(url_id, name, created_at)`,
+                `CREATE TABLE IF NOT EXISTS links (
+                        id VARCHAR(36) PRIMARY KEY,
+                        source_url_id VARCHAR(36) NOT NULL,
+                        source_url TEXT NOT NULL,
+                        target_url TEXT NOT NULL,
+                        position INT NOT NULL,
+                        anchor_text TEXT NOT NULL,
+                        rel TEXT NOT NULL,
+                        element VARCHAR(20) NOT NULL,
+                        internal BOOLEAN DEFAULT FALSE,
+                        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        FOREIGN KEY (source_url_id) REFERENCES urls(id) ON DELETE CASCADE
+                )`,
+                `CREATE INDEX IF NOT EXISTS idx_links_source_url_id ON links(source_url_id)`,
+                `CREATE INDEX IF NOT EXISTS idx_links_target_url ON links(target_url)`,
+                `CREATE TABLE IF NOT EXISTS site_analyses (
+                        id VARCHAR(36) PRIMARY KEY,
+                        seed_url_id VARCHAR(36) NOT NULL,
+                        host VARCHAR(255) NOT NULL,
+                        page_count INT DEFAULT 0,
+                        crawled_count INT DEFAULT 0,
+                        max_depth INT DEFAULT 0,
+                        unreachable INT DEFAULT 0,
+                        deep_pages INT DEFAULT 0,
+                        orphan_pages INT DEFAULT 0,
+                        sitemap_urls INT DEFAULT 0,
+                        status VARCHAR(20) DEFAULT 'completed',
+                        error_message TEXT,
+                        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                        FOREIGN KEY (seed_url_id) REFERENCES urls(id) ON DELETE CASCADE
+                )`,
+                `CREATE TABLE IF NOT EXISTS site_analysis_pages (
+                        analysis_id VARCHAR(36) NOT NULL,
+                        url TEXT NOT NULL,
+                        url_id VARCHAR(36) NULL,
+                        crawled BOOLEAN DEFAULT FALSE,
+                        depth INT NULL,
+                        inlinks INT DEFAULT 0,
+                        outlinks INT DEFAULT 0,
+                        pagerank REAL DEFAULT 0,
+                        score REAL DEFAULT 0,
+                        in_sitemap BOOLEAN DEFAULT FALSE,
+                        orphan BOOLEAN DEFAULT FALSE,
+                        deep BOOLEAN DEFAULT FALSE,
+                        FOREIGN KEY (analysis_id) REFERENCES site_analyses(id) ON DELETE CASCADE
+                )`,
+                `CREATE INDEX IF NOT EXISTS idx_site_analysis_pages_analysis_id ON site_analysis_pages(analysis_id)`,
         }
 
         for _, query := range queries {
@@ -72,3 +390,154 @@ func createTables(db *sql.DB) error {
 
         return nil
 }
+
+// columnMigrations lists columns added to existing tables after their
+// original CREATE TABLE statement. New columns must also be added to the
+// CREATE TABLE statement above so fresh databases get them directly.
+var columnMigrations = []struct {
+        table      string
+        column     string
+        definition string
+}{
+        {"urls", "structured_data_count", "INT DEFAULT 0"},
+        {"urls", "structured_data_errors", "INT DEFAULT 0"},
+        {"urls", "accessibility_errors", "INT DEFAULT 0"},
+        {"urls", "accessibility_warnings", "INT DEFAULT 0"},
+        {"urls", "heading_issues", "INT DEFAULT 0"},
+        {"urls", "login_form_confidence", "REAL DEFAULT 0"},
+        {"urls", "login_form_type", "VARCHAR(30)"},
+        {"urls", "form_count", "INT DEFAULT 0"},
+        {"urls", "insecure_forms", "INT DEFAULT 0"},
+        {"urls", "security_score", "INT NULL"},
+        {"urls", "mixed_content_active", "INT DEFAULT 0"},
+        {"urls", "mixed_content_passive", "INT DEFAULT 0"},
+        {"urls", "insecure_links", "INT DEFAULT 0"},
+        {"urls", "word_count", "INT DEFAULT 0"},
+        {"urls", "reading_time_seconds", "INT DEFAULT 0"},
+        {"urls", "language", "VARCHAR(10)"},
+        {"urls", "flesch_reading_ease", "REAL NULL"},
+        {"urls", "snapshot_retention", "INT NULL"},
+        {"urls", "latest_run_id", "VARCHAR(36) NULL"},
+        {"broken_links", "run_id", "VARCHAR(36) NULL"},
+        {"fetch_metrics", "run_id", "VARCHAR(36) NULL"},
+        {"crawl_runs", "page_state", "TEXT"},
+        {"urls", "significant_change", "BOOLEAN DEFAULT FALSE"},
+        {"urls", "change_similarity", "REAL NULL"},
+        {"urls", "last_changed_at", "TIMESTAMP NULL"},
+        {"urls", "schedule_cron", "VARCHAR(100) NULL"},
+        {"urls", "schedule_interval_seconds", "INT NULL"},
+        {"urls", "schedule_timezone", "VARCHAR(64) NULL"},
+        {"urls", "schedule_jitter_seconds", "INT DEFAULT 0"},
+        {"urls", "next_scheduled_at", "TIMESTAMP NULL"},
+        {"urls", "last_scheduled_at", "TIMESTAMP NULL"},
+        {"urls", "assertion_status", "VARCHAR(20) NULL"},
+        {"urls", "assertions_failed", "INT DEFAULT 0"},
+        {"urls", "extracted", "TEXT"},
+        {"urls", "normalized_url", "TEXT NULL"},
+        {"urls", "dns_overrides", "TEXT NULL"},
+        {"urls", "tls_insecure", "BOOLEAN DEFAULT FALSE"},
+        {"urls", "tags", "TEXT NULL"},
+        {"crawl_runs", "tls_insecure", "BOOLEAN DEFAULT FALSE"},
+        {"headings", "run_id", "VARCHAR(36) NULL"},
+        {"site_analyses", "status", "VARCHAR(20) DEFAULT 'completed'"},
+        {"site_analyses", "error_message", "TEXT"},
+}
+
+// migrationIndexes lists indexes on migrated columns. They are created after
+// migrateColumns since the columns may not exist before.
+var migrationIndexes = []string{
+        `CREATE UNIQUE INDEX IF NOT EXISTS idx_urls_normalized_url ON urls(normalized_url)`,
+        `CREATE INDEX IF NOT EXISTS idx_headings_url_id ON headings(url_id, run_id)`,
+}
+
+// cascadeReferences lists the columns that reference a parent row with ON
+// DELETE CASCADE, parents before children.
+var cascadeReferences = []struct {
+        table, column, parent string
+}{
+        {"broken_links", "url_id", "urls"},
+        {"structured_data", "url_id", "urls"},
+        {"accessibility_findings", "url_id", "urls"},
+        {"headings", "url_id", "urls"},
+        {"forms", "url_id", "urls"},
+        {"security_audits", "url_id", "urls"},
+        {"mixed_content", "url_id", "urls"},
+        {"technologies", "url_id", "urls"},
+        {"fetch_metrics", "url_id", "urls"},
+        {"page_content", "url_id", "urls"},
+        {"page_fingerprints", "url_id", "urls"},
+        {"snapshots", "url_id", "urls"},
+        {"crawl_runs", "url_id", "urls"},
+        {"content_changes", "url_id", "urls"},
+        {"assertions", "url_id", "urls"},
+        {"assertion_results", "url_id", "urls"},
+        {"assertion_results", "assertion_id", "assertions"},
+        {"extraction_rules", "url_id", "urls"},
+        {"extracted_values", "url_id", "urls"},
+        {"links", "source_url_id", "urls"},
+        {"site_analyses", "seed_url_id", "urls"},
+        {"site_analysis_pages", "analysis_id", "site_analyses"},
+}
+
+func deleteOrphans(db *sql.DB) error {
+        for _, ref := range cascadeReferences {
+                query := `DELETE FROM ` + ref.table + ` WHERE ` + ref.column + ` IS NOT NULL AND ` +
+                        ref.column + ` NOT IN (SELECT id FROM ` + ref.parent + `)`
+                if _, err := db.Exec(query); err != nil {
+                        return err
+                }
+        }
+        return nil
+}
+
+func migrateColumns(db *sql.DB) error {
+        for _, m := range columnMigrations {
+                exists, err := columnExists(db, m.table, m.column)
+                if err != nil {
+                        return err
+                }
+                if exists {
+                        continue
+                }
+
+                query := `ALTER TABLE ` + m.table + ` ADD COLUMN ` + m.column + ` ` + m.definition
+                if _, err := db.Exec(query); err != nil {
+                        return err
+                }
+        }
+
+        for _, query := range migrationIndexes {
+                if _, err := db.Exec(query); err != nil {
+                        return err
+                }
+        }
+
+        return nil
+}
+
+func columnExists(db *sql.DB, table, column string) (bool, error) {
+        rows, err := db.Query(`PRAGMA table_info(` + table + `)`)
+        if err != nil {
+                return false, err
+        }
+        defer rows.Close()
+
+        for rows.Next() {
+                var (
+                        cid        int
+                        name       string
+                        colType    string
+                        notNull    bool
+                        defaultVal sql.NullString
+                        pk         int
+                )
+                if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &pk); err != nil {
+                        return false, err
+                }
+                if name == column {
+                        return true, nil
+                }
+        }
+
+        return false, rows.Err()
+}
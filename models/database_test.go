@@ -0,0 +1,61 @@
+package models
+
+import (
+        "database/sql"
+        "os"
+        "path/filepath"
+        "testing"
+)
+
+// openTestDB returns a fresh database in a temporary directory.
+func openTestDB(t *testing.T) *sql.DB {
+        t.Helper()
+
+        old, had := os.LookupEnv("DB_PATH")
+        os.Setenv("DB_PATH", filepath.Join(t.TempDir(), "test.db")+"?_synchronous=OFF")
+        defer func() {
+                if had {
+                        os.Setenv("DB_PATH", old)
+                } else {
+                        os.Unsetenv("DB_PATH")
+                }
+        }()
+
+        db, err := InitDB()
+        if err != nil {
+                t.Fatal(err)
+        }
+        t.Cleanup(func() { db.Close() })
+        return db
+}
+
+func createTestURL(t *testing.T, db *sql.DB, address string) *URL {
+        t.Helper()
+
+        url, err := CreateURL(db, address, address)
+        if err != nil {
+                t.Fatal(err)
+        }
+        return url
+}
+
+func TestReadDoesNotBlockWrites(t *testing.T) {
+        db := openTestDB(t)
+        for _, address := range []string{"https://example.com/a", "https://example.com/b"} {
+                createTestURL(t, db, address)
+        }
+
+        // Hold a read cursor open like a slow export does
+        rows, err := db.Query(`SELECT id FROM urls`)
+        if err != nil {
+                t.Fatal(err)
+        }
+        defer rows.Close()
+        if !rows.Next() {
+                t.Fatal("no rows")
+        }
+
+        if _, err := CreateURL(db, "https://example.com/c", "https://example.com/c"); err != nil {
+                t.Fatalf("write during an open read failed: %v", err)
+        }
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+        "encoding/json"
+)
+
+// DNSOverride pins a host to an IP address for crawling, like curl's
+// --resolve. A zero Port applies the override to every port.
+type DNSOverride struct {
+        Host string `json:"host"`
+        Port int    `json:"port,omitempty"`
+        IP   string `json:"ip"`
+}
+
+// SetDNSOverrides replaces the DNS overrides of a URL. An empty list
+// removes them.
+func SetDNSOverrides(db Execer, urlID string, overrides []DNSOverride) error {
+        var value interface{}
+        if len(overrides) > 0 {
+                encoded, err := json.Marshal(overrides)
+                if err != nil {
+                        return err
+                }
+                value = string(encoded)
+        }
+
+        _, err := db.Exec(`UPDATE urls SET dns_overrides = ? WHERE id = ?`, value, urlID)
+        return err
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+        "database/sql"
+        "strconv"
+        "time"
+)
+
+// PageFingerprint identifies the content of a crawled page for duplicate
+// detection. Title and Description are normalized, ContentHash is a SHA-256
+// of the normalized main text and SimHash a 64-bit locality-sensitive hash
+// of its word shingles.
+type PageFingerprint struct {
+        URLID       string    `json:"url_id"`
+        URL         string    `json:"url"`
+        Title       string    `json:"title"`
+        Description string    `json:"description"`
+        ContentHash string    `json:"content_hash"`
+        SimHash     uint64    `json:"simhash"`
+        WordCount   int       `json:"word_count"`
+        UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// DuplicateCluster is a group of pages sharing a title, a meta description
+// or (near-)identical body content. Similarity is the lowest similarity
+// between two linked pages of the cluster, in percent.
+type DuplicateCluster struct {
+        Type       string          `json:"type"`
+        Value      string          `json:"value,omitempty"`
+        Exact      bool            `json:"exact"`
+        Similarity float64         `json:"similarity"`
+        Pages      []DuplicatePage `json:"pages"`
+}
+
+type DuplicatePage struct {
+        URLID string `json:"url_id"`
+        URL   string `json:"url"`
+}
+
+// SavePageFingerprint stores the fingerprint of the latest crawl, replacing
+// the previous one.
+func SavePageFingerprint(db *sql.DB, fp PageFingerprint) error {
+        query := `INSERT OR REPLACE INTO page_fingerprints (url_id, title, description, content_hash, simhash, word_count, updated_at)
+                          VALUES (?, ?, ?, ?, ?, ?, ?)`
+        _, err := db.Exec(query, fp.URLID, fp.Title, fp.Description, fp.ContentHash,
+                strconv.FormatUint(fp.SimHash, 16), fp.WordCount, time.Now())
+        return err
+}
+
+// GetPageFingerprints returns the fingerprints of every tracked URL,
+// ordered by URL.
+func GetPageFingerprints(db *sql.DB) ([]PageFingerprint, error) {
+        query := `SELECT f.url_id, u.url, f.title, f.description, f.content_hash, f.simhash, f.word_count, f.updated_at
+                          FROM page_fingerprints f JOIN urls u ON u.id = f.url_id ORDER BY u.url`
+
+        rows, err := db.Query(query)
+        if err != nil {
+                return nil, err
+        }
+        defer rows.Close()
+
+        var fingerprints []PageFingerprint
+        for rows.Next() {
+                var fp PageFingerprint
+                var simhash string
+                err := rows.Scan(&fp.URLID, &fp.URL, &fp.Title, &fp.Description, &fp.ContentHash,
+                        &simhash, &fp.WordCount, &fp.UpdatedAt)
+                if err != nil {
+                        return nil, err
+                }
+                if fp.SimHash, err = strconv.ParseUint(simhash, 16, 64); err != nil {
+                        return nil, err
+                }
+                fingerprints = append(fingerprints, fp)
+        }
+
+        return fingerprints, rows.Err()
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+        "database/sql"
+        "encoding/json"
+        "time"
+
+        "github.com/google/uuid"
+)
+
+// ExtractionRule defines a named field scraped from a URL, or from every URL
+// matching URLPattern, with a CSS selector, an XPath expression or a regular
+// expression.
+type ExtractionRule struct {
+        ID         string    `json:"id"`
+        Name       string    `json:"name"`
+        URLID      *string   `json:"url_id"`
+        URLPattern *string   `json:"url_pattern"`
+        Method     string    `json:"method"`
+        Expression string    `json:"expression"`
+        Attribute  string    `json:"attribute"`
+        ValueType  string    `json:"value_type"`
+        Multiple   bool      `json:"multiple"`
+        CreatedAt  time.Time `json:"created_at"`
+}
+
+// ExtractedValue is the typed value a rule produced in one crawl run. Value
+// is null when nothing matched or the match could not be converted.
+type ExtractedValue struct {
+        ID        string          `json:"id"`
+        RuleID    string          `json:"rule_id"`
+        URLID     string          `json:"url_id"`
+        RunID     string          `json:"run_id"`
+        Name      string          `json:"name"`
+        ValueType string          `json:"value_type"`
+        Value     json.RawMessage `json:"value"`
+        Error     *string         `json:"error"`
+        CreatedAt time.Time       `json:"created_at"`
+}
+
+const extractionRuleColumns = `id, name, url_id, url_pattern, method, expression, attribute, value_type, multiple, created_at`
+
+func queryExtractionRules(db *sql.DB, query string, args ...interface{}) ([]ExtractionRule, error) {
+        rows, err := db.Query(query, args...)
+        if err != nil {
+                return nil, err
+        }
+        defer rows.Close()
+
+        rules := []ExtractionRule{}
+        for rows.Next() {
+                var r ExtractionRule
+                err := rows.Scan(&r.ID, &r.Name, &r.URLID, &r.URLPattern, &r.Method, &r.Expression,
+                        &r.Attribute, &r.ValueType, &r.Multiple, &r.CreatedAt)
+                if err != nil {
+                        return nil, err
+                }
+                rules = append(rules, r)
+        }
+
+        return rules, rows.Err()
+}
+
+// GetExtractionRuleNames returns the distinct names of all extraction
+// rules, sorted.
+func GetExtractionRuleNames(db *sql.DB) ([]string, error) {
+        rows, err := db.Query(`SELECT DISTINCT name FROM extraction_rules ORDER BY name`)
+        if err != nil {
+                return nil, err
+        }
+        defer rows.Close()
+
+        var names []string
+        for rows.Next() {
+                var name string
+                if err := rows.Scan(&name); err != nil {
+                        return nil, err
+                }
+                names = append(names, name)
+        }
+
+        return names, rows.Err()
+}
+
+// GetExtractionRules returns all rules, or only those attached to urlID
+// when it is given.
+func GetExtractionRules(db *sql.DB, urlID string) ([]ExtractionRule, error) {
+        if urlID != "" {
+                query := `SELECT ` + extractionRuleColumns + ` FROM extraction_rules WHERE url_id = ? ORDER BY name`
+                return queryExtractionRules(db, query, urlID)
+        }
+
+        query := `SELECT ` + extractionRuleColumns + ` FROM extraction_rules ORDER BY name`
+        return queryExtractionRules(db, query)
+}
+
+// GetExtractionRuleCandidates returns the rules attached to a URL plus every
+// pattern rule; the caller decides which patterns match.
+func GetExtractionRuleCandidates(db *sql.DB, urlID string) ([]ExtractionRule, error) {
+        query := `SELECT ` + extractionRuleColumns + ` FROM extraction_rules
+                          WHERE url_id = ? OR url_pattern IS NOT NULL ORDER BY name, created_at`
+        return queryExtractionRules(db, query, urlID)
+}
+
+func CreateExtractionRule(db *sql.DB, rule ExtractionRule) (*ExtractionRule, error) {
+        rule.ID = uuid.New().String()
+        rule.CreatedAt = time.Now()
+
+        query := `INSERT INTO extraction_rules (` + extractionRuleColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+        _, err := db.Exec(query, rule.ID, rule.Name, rule.URLID, rule.URLPattern, rule.Method, rule.Expression,
+                rule.Attribute, rule.ValueType, rule.Multiple, rule.CreatedAt)
+        if err != nil {
+                return nil, err
+        }
+
+        return &rule, nil
+}
+
+func DeleteExtractionRule(db *sql.DB, id string) error {
+        result, err := db.Exec(`DELETE FROM extraction_rules WHERE id = ?`, id)
+        if err != nil {
+                return err
+        }
+        if n, _ := result.RowsAffected(); n == 0 {
+                return sql.ErrNoRows
+        }
+        return nil
+}
+
+// CreateExtractedValues stores the values extracted by a crawl run.
+func CreateExtractedValues(db *sql.DB, urlID, runID string, values []ExtractedValue) error {
+        tx, err := db.Begin()
+        if err != nil {
+                return err
+        }
+        defer tx.Rollback()
+
+        now := time.Now()
+        query := `INSERT INTO extracted_values (id, rule_id, url_id, run_id, name, value_type, value, error, created_at)
+                          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+        for _, v := range values {
+                if _, err := tx.Exec(query, uuid.New().String(), v.RuleID, urlID, runID, v.Name, v.ValueType,
+                        string(v.Value), v.Error, now); err != nil {
+                        return err
+                }
+        }
+
+        return tx.Commit()
+}
+
+// GetExtractedValueHistory returns the values extracted for a URL, newest
+// first, optionally limited to one field name.
+func GetExtractedValueHistory(db *sql.DB, urlID, name string, limit int) ([]ExtractedValue, error) {
+        query := `SELECT id, rule_id, url_id, run_id, name, value_type, value, error, created_at
+                          FROM extracted_values WHERE url_id = ?`
+        args := []interface{}{urlID}
+        if name != "" {
+                query += ` AND name = ?`
+                args = append(args, name)
+        }
+        query += ` ORDER BY created_at DESC, name LIMIT ?`
+        args = append(args, limit)
+
+        rows, err := db.Query(query, args...)
+        if err != nil {
+                return nil, err
+        }
+        defer rows.Close()
+
+        values := []ExtractedValue{}
+        for rows.Next() {
+                var v ExtractedValue
+                var value string
+                err := rows.Scan(&v.ID, &v.RuleID, &v.URLID, &v.RunID, &v.Name, &v.ValueType, &value, &v.Error, &v.CreatedAt)
+                if err != nil {
+                        return nil, err
+                }
+                v.Value = json.RawMessage(value)
+                values = append(values, v)
+        }
+
+        return values, rows.Err()
+}
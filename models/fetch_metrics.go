@@ -0,0 +1,83 @@
+package models
+
+import (
+        "database/sql"
+        "time"
+
+        "github.com/google/uuid"
+)
+
+// FetchMetrics records how long each phase of fetching a page took and how
+// heavy the page was. One row is stored per crawl.
+type FetchMetrics struct {
+        ID                string    `json:"id"`
+        URLID             string    `json:"url_id"`
+        RunID             *string   `json:"run_id"`
+        StatusCode        int       `json:"status_code"`
+        DNSMs             float64   `json:"dns_ms"`
+        ConnectMs         float64   `json:"connect_ms"`
+        TLSMs             float64   `json:"tls_ms"`
+        TTFBMs            float64   `json:"ttfb_ms"`
+        DownloadMs        float64   `json:"download_ms"`
+        TotalMs           float64   `json:"total_ms"`
+        CompressedBytes   int64     `json:"compressed_bytes"`
+        UncompressedBytes int64     `json:"uncompressed_bytes"`
+        ContentEncoding   string    `json:"content_encoding"`
+        SubresourceCount  *int      `json:"subresource_count"`
+        SubresourceBytes  *int64    `json:"subresource_bytes"`
+        CreatedAt         time.Time `json:"created_at"`
+}
+
+const fetchMetricsColumns = `id, url_id, run_id, status_code, dns_ms, connect_ms, tls_ms, ttfb_ms, download_ms, total_ms,
+        compressed_bytes, uncompressed_bytes, content_encoding, subresource_count, subresource_bytes, created_at`
+
+func scanFetchMetrics(row rowScanner) (*FetchMetrics, error) {
+        var m FetchMetrics
+        err := row.Scan(&m.ID, &m.URLID, &m.RunID, &m.StatusCode, &m.DNSMs, &m.ConnectMs, &m.TLSMs, &m.TTFBMs,
+                &m.DownloadMs, &m.TotalMs, &m.CompressedBytes, &m.UncompressedBytes, &m.ContentEncoding,
+                &m.SubresourceCount, &m.SubresourceBytes, &m.CreatedAt)
+        if err != nil {
+                return nil, err
+        }
+
+        return &m, nil
+}
+
+// GetFetchMetrics returns the most recent fetch metrics of a URL, newest
+// first.
+func GetFetchMetrics(db *sql.DB, urlID string, limit int) ([]FetchMetrics, error) {
+        query := `SELECT ` + fetchMetricsColumns + ` FROM fetch_metrics WHERE url_id = ? ORDER BY created_at DESC LIMIT ?`
+
+        rows, err := db.Query(query, urlID, limit)
+        if err != nil {
+                return nil, err
+        }
+        defer rows.Close()
+
+        metrics := []FetchMetrics{}
+        for rows.Next() {
+                m, err := scanFetchMetrics(rows)
+                if err != nil {
+                        return nil, err
+                }
+                metrics = append(metrics, *m)
+        }
+
+        return metrics, rows.Err()
+}
+
+func getRunFetchMetrics(db *sql.DB, runID string) (*FetchMetrics, error) {
+        query := `SELECT ` + fetchMetricsColumns + ` FROM fetch_metrics WHERE run_id = ?`
+
+        return scanFetchMetrics(db.QueryRow(query, runID))
+}
+
+func CreateFetchMetrics(db *sql.DB, urlID, runID string, m FetchMetrics) error {
+        query := `INSERT INTO fetch_metrics (id, url_id, run_id, status_code, dns_ms, connect_ms, tls_ms, ttfb_ms, download_ms,
+                          total_ms, compressed_bytes, uncompressed_bytes, content_encoding, subresource_count, subresource_bytes, created_at)
+                          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+        _, err := db.Exec(query, uuid.New().String(), urlID, runID, m.StatusCode, m.DNSMs, m.ConnectMs, m.TLSMs, m.TTFBMs,
+                m.DownloadMs, m.TotalMs, m.CompressedBytes, m.UncompressedBytes, m.ContentEncoding,
+                m.SubresourceCount, m.SubresourceBytes, time.Now())
+        return err
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+        "database/sql"
+        "encoding/json"
+        "strings"
+        "time"
+
+        "github.com/google/uuid"
+)
+
+// Form describes a <form> found on a crawled page.
+type Form struct {
+        ID           string      `json:"id"`
+        URLID        string      `json:"url_id"`
+        Position     int         `json:"position"`
+        Selector     string      `json:"selector"`
+        Method       string      `json:"method"`
+        Action       string      `json:"action"`
+        Fields       []FormField `json:"fields"`
+        HasCSRFToken bool        `json:"has_csrf_token"`
+        Findings     []string    `json:"findings"`
+        CreatedAt    time.Time   `json:"created_at"`
+}
+
+type FormField struct {
+        Name string `json:"name"`
+        Type string `json:"type"`
+}
+
+func GetForms(db *sql.DB, urlID string) ([]Form, error) {
+        query := `SELECT id, url_id, position, selector, method, action, fields, has_csrf_token, findings, created_at
+                          FROM forms WHERE url_id = ? ORDER BY position`
+
+        rows, err := db.Query(query, urlID)
+        if err != nil {
+                return nil, err
+        }
+        defer rows.Close()
+
+        forms := []Form{}
+        for rows.Next() {
+                var f Form
+                var fields, findings string
+                err := rows.Scan(&f.ID, &f.URLID, &f.Position, &f.Selector, &f.Method, &f.Action,
+                        &fields, &f.HasCSRFToken, &findings, &f.CreatedAt)
+                if err != nil {
+                        return nil, err
+                }
+                if err := json.Unmarshal([]byte(fields), &f.Fields); err != nil {
+                        return nil, err
+                }
+                f.Findings = []string{}
+                if findings != "" {
+                        f.Findings = strings.Split(findings, ",")
+                }
+                forms = append(forms, f)
+        }
+
+        return forms, rows.Err()
+}
+
+// ReplaceForms swaps the stored form inventory for a URL with the one from
+// the latest crawl.
+func ReplaceForms(db *sql.DB, urlID string, forms []Form) error {
+        tx, err := db.Begin()
+        if err != nil {
+                return err
+        }
+        defer tx.Rollback()
+
+        if _, err := tx.Exec(`DELETE FROM forms WHERE url_id = ?`, urlID); err != nil {
+                return err
+        }
+
+        query := `INSERT INTO forms (id, url_id, position, selector, method, action, fields, has_csrf_token, findings, created_at)
+                          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+        now := time.Now()
+        for _, f := range forms {
+                fields, err := json.Marshal(f.Fields)
+                if err != nil {
+                        return err
+                }
+                _, err = tx.Exec(query, uuid.New().String(), urlID, f.Position, f.Selector, f.Method, f.Action,
+                        string(fields), f.HasCSRFToken, strings.Join(f.Findings, ","), now)
+                if err != nil {
+                        return err
+                }
+        }
+
+        return tx.Commit()
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+        "database/sql"
+        "strings"
+        "time"
+
+        "github.com/google/uuid"
+)
+
+// Heading is one entry of a page's heading outline in document order.
+type Heading struct {
+        ID        string    `json:"id"`
+        URLID     string    `json:"url_id"`
+        RunID     *string   `json:"run_id"`
+        Position  int       `json:"position"`
+        Level     int       `json:"level"`
+        Text      string    `json:"text"`
+        Issues    []string  `json:"issues"`
+        CreatedAt time.Time `json:"created_at"`
+}
+
+// GetHeadings returns the heading outline of a URL's latest crawl run.
+func GetHeadings(db *sql.DB, urlID string) ([]Heading, error) {
+        query := `SELECT id, url_id, run_id, position, level, text, issues, created_at
+                          FROM headings WHERE url_id = ?
+                          AND COALESCE(run_id, '') = COALESCE((SELECT latest_run_id FROM urls WHERE id = ?), '')
+                          ORDER BY position`
+
+        return queryHeadings(db, query, urlID, urlID)
+}
+
+// GetRunHeadings returns the heading outline recorded by one crawl run.
+func GetRunHeadings(db *sql.DB, runID string) ([]Heading, error) {
+        query := `SELECT id, url_id, run_id, position, level, text, issues, created_at
+                          FROM headings WHERE run_id = ? ORDER BY position`
+
+        return queryHeadings(db, query, runID)
+}
+
+func queryHeadings(db *sql.DB, query string, args ...interface{}) ([]Heading, error) {
+        rows, err := db.Query(query, args...)
+        if err != nil {
+                return nil, err
+        }
+        defer rows.Close()
+
+        outline := []Heading{}
+        for rows.Next() {
+                var h Heading
+                var issues string
+                err := rows.Scan(&h.ID, &h.URLID, &h.RunID, &h.Position, &h.Level, &h.Text, &issues, &h.CreatedAt)
+                if err != nil {
+                        return nil, err
+                }
+                h.Issues = []string{}
+                if issues != "" {
+                        h.Issues = strings.Split(issues, ",")
+                }
+                outline = append(outline, h)
+        }
+
+        return outline, rows.Err()
+}
+
+// CreateHeadings stores the heading outline found by a crawl run. Outlines
+// of earlier runs are kept.
+func CreateHeadings(db *sql.DB, urlID, runID string, outline []Heading) error {
+        tx, err := db.Begin()
+        if err != nil {
+                return err
+        }
+        defer tx.Rollback()
+
+        query := `INSERT INTO headings (id, url_id, run_id, position, level, text, issues, created_at)
+                          VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+        now := time.Now()
+        for _, h := range outline {
+                _, err := tx.Exec(query, uuid.New().String(), urlID, runID, h.Position, h.Level, h.Text,
+                        strings.Join(h.Issues, ","), now)
+                if err != nil {
+                        return err
+                }
+        }
+
+        return tx.Commit()
+}
@@ -0,0 +1,60 @@
+package models
+
+import "testing"
+
+func TestHeadingsPerRun(t *testing.T) {
+        db := openTestDB(t)
+        u := createTestURL(t, db, "https://example.com/")
+
+        crawl := func(text string, complete bool) string {
+                t.Helper()
+                runID, err := StartCrawlRun(db, u.ID)
+                if err != nil {
+                        t.Fatal(err)
+                }
+                if err := CreateHeadings(db, u.ID, runID, []Heading{{Position: 0, Level: 1, Text: text}}); err != nil {
+                        t.Fatal(err)
+                }
+                if complete {
+                        if err := CompleteCrawlRun(db, runID, map[string]interface{}{}); err != nil {
+                                t.Fatal(err)
+                        }
+                }
+                return runID
+        }
+        latest := func() string {
+                t.Helper()
+                outline, err := GetHeadings(db, u.ID)
+                if err != nil {
+                        t.Fatal(err)
+                }
+                if len(outline) != 1 {
+                        t.Fatalf("latest outline has %d headings, want 1", len(outline))
+                }
+                return outline[0].Text
+        }
+
+        first := crawl("First", true)
+        if got := latest(); got != "First" {
+                t.Errorf("latest outline = %q, want First", got)
+        }
+
+        // A run in progress does not replace the latest outline
+        crawl("Unfinished", false)
+        if got := latest(); got != "First" {
+                t.Errorf("latest outline during a crawl = %q, want First", got)
+        }
+
+        crawl("Second", true)
+        if got := latest(); got != "Second" {
+                t.Errorf("latest outline = %q, want Second", got)
+        }
+
+        outline, err := GetRunHeadings(db, first)
+        if err != nil {
+                t.Fatal(err)
+        }
+        if len(outline) != 1 || outline[0].Text != "First" || outline[0].RunID == nil || *outline[0].RunID != first {
+                t.Errorf("outline of the first run = %+v", outline)
+        }
+}
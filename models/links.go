@@ -0,0 +1,128 @@
+package models
+
+import (
+        "database/sql"
+        "strings"
+        "time"
+
+        "github.com/google/uuid"
+)
+
+// Link is an edge of the link graph: a link found on a crawled page.
+type Link struct {
+        ID          string    `json:"id"`
+        SourceURLID string    `json:"source_url_id"`
+        SourceURL   string    `json:"source_url"`
+        TargetURL   string    `json:"target_url"`
+        AnchorText  string    `json:"anchor_text"`
+        Rel         []string  `json:"rel"`
+        Element     string    `json:"element"`
+        Internal    bool      `json:"internal"`
+        CreatedAt   time.Time `json:"created_at"`
+}
+
+// LinkFilter narrows the links returned by GetOutlinks and GetInlinks.
+type LinkFilter struct {
+        Internal *bool
+        Rel      string
+}
+
+func (f LinkFilter) conditions() ([]string, []interface{}) {
+        conditions := []string{}
+        args := []interface{}{}
+
+        if f.Internal != nil {
+                conditions = append(conditions, "internal = ?")
+                args = append(args, *f.Internal)
+        }
+        if f.Rel != "" {
+                conditions = append(conditions, "(' ' || rel || ' ') LIKE ?")
+                args = append(args, "% "+strings.ToLower(f.Rel)+" %")
+        }
+
+        return conditions, args
+}
+
+// GetOutlinks returns the links found on a URL's latest crawl.
+func GetOutlinks(db *sql.DB, urlID string, filter LinkFilter, page, limit int) ([]Link, int, error) {
+        conditions, args := filter.conditions()
+        conditions = append([]string{"source_url_id = ?"}, conditions...)
+        args = append([]interface{}{urlID}, args...)
+
+        return queryLinks(db, conditions, args, page, limit)
+}
+
+// GetInlinks returns the links from any crawled page to one of the given
+// target URLs.
+func GetInlinks(db *sql.DB, targets []string, filter LinkFilter, page, limit int) ([]Link, int, error) {
+        conditions, args := filter.conditions()
+
+        placeholders := make([]string, len(targets))
+        targetArgs := make([]interface{}, len(targets))
+        for i, target := range targets {
+                placeholders[i] = "?"
+                targetArgs[i] = target
+        }
+        conditions = append([]string{"target_url IN (" + strings.Join(placeholders, ", ") + ")"}, conditions...)
+        args = append(targetArgs, args...)
+
+        return queryLinks(db, conditions, args, page, limit)
+}
+
+func queryLinks(db *sql.DB, conditions []string, args []interface{}, page, limit int) ([]Link, int, error) {
+        whereClause := "WHERE " + strings.Join(conditions, " AND ")
+
+        var total int
+        if err := db.QueryRow(`SELECT COUNT(*) FROM links `+whereClause, args...).Scan(&total); err != nil {
+                return nil, 0, err
+        }
+
+        query := `SELECT id, source_url_id, source_url, target_url, anchor_text, rel, element, internal, created_at
+                          FROM links ` + whereClause + ` ORDER BY source_url, position LIMIT ? OFFSET ?`
+        rows, err := db.Query(query, append(args, limit, (page-1)*limit)...)
+        if err != nil {
+                return nil, 0, err
+        }
+        defer rows.Close()
+
+        links := []Link{}
+        for rows.Next() {
+                var l Link
+                var rel string
+                err := rows.Scan(&l.ID, &l.SourceURLID, &l.SourceURL, &l.TargetURL, &l.AnchorText, &rel,
+                        &l.Element, &l.Internal, &l.CreatedAt)
+                if err != nil {
+                        return nil, 0, err
+                }
+                l.Rel = strings.Fields(rel)
+                links = append(links, l)
+        }
+
+        return links, total, rows.Err()
+}
+
+// ReplaceLinks swaps the stored outlinks of a URL with the ones found by the
+// latest crawl.
+func ReplaceLinks(db *sql.DB, urlID string, links []Link) error {
+        tx, err := db.Begin()
+        if err != nil {
+                return err
+        }
+        defer tx.Rollback()
+
+        if _, err := tx.Exec(`DELETE FROM links WHERE source_url_id = ?`, urlID); err != nil {
+                return err
+        }
+
+        query := `INSERT INTO links (id, source_url_id, source_url, target_url, position, anchor_text, rel, element, internal, created_at)
+                          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+        now := time.Now()
+        for i, l := range links {
+                if _, err := tx.Exec(query, uuid.New().String(), urlID, l.SourceURL, l.TargetURL, i, l.AnchorText,
+                        strings.Join(l.Rel, " "), l.Element, l.Internal, now); err != nil {
+                        return err
+                }
+        }
+
+        return tx.Commit()
+}
@@ -0,0 +1,73 @@
+package models
+
+import "testing"
+
+func TestDeleteURLRemovesLinks(t *testing.T) {
+        db := openTestDB(t)
+        a := createTestURL(t, db, "https://example.com/a")
+        b := createTestURL(t, db, "https://example.com/b")
+
+        if err := ReplaceLinks(db, a.ID, []Link{{SourceURL: a.URL, TargetURL: b.URL, Element: "a", Internal: true}}); err != nil {
+                t.Fatal(err)
+        }
+        if err := ReplaceLinks(db, b.ID, []Link{{SourceURL: b.URL, TargetURL: a.URL, Element: "a", Internal: true}}); err != nil {
+                t.Fatal(err)
+        }
+
+        if err := DeleteURL(db, a.ID); err != nil {
+                t.Fatal(err)
+        }
+
+        outlinks, total, err := GetOutlinks(db, a.ID, LinkFilter{}, 1, 50)
+        if err != nil {
+                t.Fatal(err)
+        }
+        if total != 0 || len(outlinks) != 0 {
+                t.Errorf("deleted URL still has %d outlinks", total)
+        }
+
+        inlinks, total, err := GetInlinks(db, []string{b.URL}, LinkFilter{}, 1, 50)
+        if err != nil {
+                t.Fatal(err)
+        }
+        if total != 0 || len(inlinks) != 0 {
+                t.Errorf("links from the deleted URL still count as inlinks: %+v", inlinks)
+        }
+
+        // Links of other pages to the deleted URL are kept
+        inlinks, total, err = GetInlinks(db, []string{a.URL}, LinkFilter{}, 1, 50)
+        if err != nil {
+                t.Fatal(err)
+        }
+        if total != 1 || len(inlinks) != 1 || inlinks[0].SourceURLID != b.ID {
+                t.Errorf("inlinks to the deleted URL = %+v, want the link from b", inlinks)
+        }
+}
+
+func TestDeleteOrphans(t *testing.T) {
+        db := openTestDB(t)
+        a := createTestURL(t, db, "https://example.com/a")
+        if err := ReplaceLinks(db, a.ID, []Link{{SourceURL: a.URL, TargetURL: "https://example.com/b", Element: "a"}}); err != nil {
+                t.Fatal(err)
+        }
+
+        // Simulate a delete from before foreign keys were enforced
+        db.SetMaxOpenConns(1)
+        if _, err := db.Exec(`PRAGMA foreign_keys = OFF`); err != nil {
+                t.Fatal(err)
+        }
+        if _, err := db.Exec(`DELETE FROM urls WHERE id = ?`, a.ID); err != nil {
+                t.Fatal(err)
+        }
+
+        if err := deleteOrphans(db); err != nil {
+                t.Fatal(err)
+        }
+        var count int
+        if err := db.QueryRow(`SELECT COUNT(*) FROM links`).Scan(&count); err != nil {
+                t.Fatal(err)
+        }
+        if count != 0 {
+                t.Errorf("%d orphaned links left", count)
+        }
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+        "database/sql"
+        "time"
+
+        "github.com/google/uuid"
+)
+
+// MixedContent is an insecure http:// reference found on an HTTPS page:
+// either an active or passive subresource, or a downgrading link.
+type MixedContent struct {
+        ID          string    `json:"id"`
+        URLID       string    `json:"url_id"`
+        Kind        string    `json:"kind"`
+        Element     string    `json:"element"`
+        Attribute   string    `json:"attribute"`
+        ResourceURL string    `json:"resource_url"`
+        Selector    string    `json:"selector"`
+        CreatedAt   time.Time `json:"created_at"`
+}
+
+func GetMixedContent(db *sql.DB, urlID string) ([]MixedContent, error) {
+        query := `SELECT id, url_id, kind, element, attribute, resource_url, selector, created_at
+                          FROM mixed_content WHERE url_id = ? ORDER BY created_at, rowid`
+
+        rows, err := db.Query(query, urlID)
+        if err != nil {
+                return nil, err
+        }
+        defer rows.Close()
+
+        items := []MixedContent{}
+        for rows.Next() {
+                var item MixedContent
+                err := rows.Scan(&item.ID, &item.URLID, &item.Kind, &item.Element, &item.Attribute,
+                        &item.ResourceURL, &item.Selector, &item.CreatedAt)
+                if err != nil {
+                        return nil, err
+                }
+                items = append(items, item)
+        }
+
+        return items, rows.Err()
+}
+
+// ReplaceMixedContent swaps the stored mixed content for a URL with the
+// references found by the latest crawl.
+func ReplaceMixedContent(db *sql.DB, urlID string, items []MixedContent) error {
+        tx, err := db.Begin()
+        if err != nil {
+                return err
+        }
+        defer tx.Rollback()
+
+        if _, err := tx.Exec(`DELETE FROM mixed_content WHERE url_id = ?`, urlID); err != nil {
+                return err
+        }
+
+        query := `INSERT INTO mixed_content (id, url_id, kind, element, attribute, resource_url, selector, created_at)
+                          VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+        now := time.Now()
+        for _, item := range items {
+                _, err := tx.Exec(query, uuid.New().String(), urlID, item.Kind, item.Element, item.Attribute,
+                        item.ResourceURL, item.Selector, now)
+                if err != nil {
+                        return err
+                }
+        }
+
+        return tx.Commit()
+}
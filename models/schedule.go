@@ -0,0 +1,69 @@
+package models
+
+import (
+        "database/sql"
+        "time"
+)
+
+// URLSchedule is the recrawl schedule of a URL: either a cron expression or
+// a fixed interval, evaluated in Timezone, with up to JitterSeconds of
+// random delay added to each run.
+type URLSchedule struct {
+        Cron            *string `json:"cron"`
+        IntervalSeconds *int    `json:"interval_seconds"`
+        Timezone        string  `json:"timezone"`
+        JitterSeconds   int     `json:"jitter_seconds"`
+}
+
+// SetURLSchedule attaches a schedule to a URL along with its first run time.
+func SetURLSchedule(db Execer, id string, schedule URLSchedule, next time.Time) error {
+        query := `UPDATE urls SET schedule_cron = ?, schedule_interval_seconds = ?, schedule_timezone = ?,
+                          schedule_jitter_seconds = ?, next_scheduled_at = ? WHERE id = ?`
+        _, err := db.Exec(query, schedule.Cron, schedule.IntervalSeconds, schedule.Timezone,
+                schedule.JitterSeconds, next, id)
+        return err
+}
+
+func ClearURLSchedule(db *sql.DB, id string) error {
+        query := `UPDATE urls SET schedule_cron = NULL, schedule_interval_seconds = NULL, schedule_timezone = NULL,
+                          schedule_jitter_seconds = 0, next_scheduled_at = NULL WHERE id = ?`
+        _, err := db.Exec(query, id)
+        return err
+}
+
+// GetScheduledURLs returns every URL with a schedule.
+func GetScheduledURLs(db *sql.DB) ([]URL, error) {
+        query := `SELECT ` + urlColumns + ` FROM urls
+                          WHERE schedule_cron IS NOT NULL OR schedule_interval_seconds IS NOT NULL`
+
+        rows, err := db.Query(query)
+        if err != nil {
+                return nil, err
+        }
+        defer rows.Close()
+
+        var urls []URL
+        for rows.Next() {
+                url, err := scanURL(rows)
+                if err != nil {
+                        return nil, err
+                }
+                urls = append(urls, *url)
+        }
+
+        return urls, rows.Err()
+}
+
+// SetNextScheduledRun moves a URL's next run without recording a run, e.g.
+// when a due run is skipped.
+func SetNextScheduledRun(db *sql.DB, id string, next time.Time) error {
+        _, err := db.Exec(`UPDATE urls SET next_scheduled_at = ? WHERE id = ?`, next, id)
+        return err
+}
+
+// RecordScheduledRun records that a scheduled crawl was started and when
+// the next one is due.
+func RecordScheduledRun(db *sql.DB, id string, ran, next time.Time) error {
+        _, err := db.Exec(`UPDATE urls SET last_scheduled_at = ?, next_scheduled_at = ? WHERE id = ?`, ran, next, id)
+        return err
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+        "database/sql"
+        "encoding/json"
+        "time"
+)
+
+// SecurityAudit is the graded result of a page's security response headers.
+type SecurityAudit struct {
+        URLID     string              `json:"url_id"`
+        Score     int                 `json:"score"`
+        Headers   map[string][]string `json:"headers"`
+        CSP       map[string][]string `json:"csp"`
+        Findings  []SecurityFinding   `json:"findings"`
+        CreatedAt time.Time           `json:"created_at"`
+}
+
+type SecurityFinding struct {
+        Header   string `json:"header"`
+        Severity string `json:"severity"`
+        Penalty  int    `json:"penalty"`
+        Message  string `json:"message"`
+}
+
+func GetSecurityAudit(db *sql.DB, urlID string) (*SecurityAudit, error) {
+        query := `SELECT url_id, score, headers, csp, findings, created_at
+                          FROM security_audits WHERE url_id = ?`
+
+        var audit SecurityAudit
+        var headers, csp, findings string
+        err := db.QueryRow(query, urlID).Scan(&audit.URLID, &audit.Score, &headers, &csp, &findings, &audit.CreatedAt)
+        if err != nil {
+                return nil, err
+        }
+
+        if err := json.Unmarshal([]byte(headers), &audit.Headers); err != nil {
+                return nil, err
+        }
+        if err := json.Unmarshal([]byte(csp), &audit.CSP); err != nil {
+                return nil, err
+        }
+        if err := json.Unmarshal([]byte(findings), &audit.Findings); err != nil {
+                return nil, err
+        }
+
+        return &audit, nil
+}
+
+// SaveSecurityAudit stores the audit of the latest crawl, replacing the
+// previous one.
+func SaveSecurityAudit(db *sql.DB, urlID string, audit SecurityAudit) error {
+        headers, err := json.Marshal(audit.Headers)
+        if err != nil {
+                return err
+        }
+        csp, err := json.Marshal(audit.CSP)
+        if err != nil {
+                return err
+        }
+        findings, err := json.Marshal(audit.Findings)
+        if err != nil {
+                return err
+        }
+
+        query := `INSERT OR REPLACE INTO security_audits (url_id, score, headers, csp, findings, created_at)
+                          VALUES (?, ?, ?, ?, ?, ?)`
+        _, err = db.Exec(query, urlID, audit.Score, string(headers), string(csp), string(findings), time.Now())
+        return err
+}
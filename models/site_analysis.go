@@ -0,0 +1,253 @@
+package models
+
+import (
+        "database/sql"
+        "strings"
+        "time"
+
+        "github.com/google/uuid"
+)
+
+// SiteAnalysis is an analysis of the internal link graph of a site, run
+// from a seed URL over the links stored by the crawls of its pages.
+type SiteAnalysis struct {
+        ID           string             `json:"id"`
+        SeedURLID    string             `json:"seed_url_id"`
+        Host         string             `json:"host"`
+        PageCount    int                `json:"page_count"`
+        CrawledCount int                `json:"crawled_count"`
+        MaxDepth     int                `json:"max_depth"`
+        Unreachable  int                `json:"unreachable"`
+        DeepPages    int                `json:"deep_pages"`
+        OrphanPages  int                `json:"orphan_pages"`
+        SitemapURLs  int                `json:"sitemap_urls"`
+        Status       string             `json:"status"`
+        ErrorMessage *string            `json:"error_message"`
+        CreatedAt    time.Time          `json:"created_at"`
+        Pages        []SiteAnalysisPage `json:"pages,omitempty"`
+}
+
+// SiteAnalysisPage holds the metrics of one page of a site analysis. Depth
+// is nil when the page cannot be reached from the seed.
+type SiteAnalysisPage struct {
+        URL       string  `json:"url"`
+        URLID     *string `json:"url_id"`
+        Crawled   bool    `json:"crawled"`
+        Depth     *int    `json:"depth"`
+        Inlinks   int     `json:"inlinks"`
+        Outlinks  int     `json:"outlinks"`
+        PageRank  float64 `json:"pagerank"`
+        Score     float64 `json:"score"`
+        InSitemap bool    `json:"in_sitemap"`
+        Orphan    bool    `json:"orphan"`
+        Deep      bool    `json:"deep"`
+}
+
+// SiteLink is an internal link edge used by the site analysis.
+type SiteLink struct {
+        SourceURL string
+        TargetURL string
+        Nofollow  bool
+}
+
+// GetSiteLinks returns the clickable internal links found on crawled pages
+// whose URL starts with one of the given prefixes.
+func GetSiteLinks(db *sql.DB, prefixes []string) ([]SiteLink, error) {
+        query := `SELECT source_url, target_url, (' ' || rel || ' ') LIKE '% nofollow %' FROM links
+                          WHERE internal = 1 AND element IN ('a', 'area') AND (`
+        args := []interface{}{}
+        for i, prefix := range prefixes {
+                if i > 0 {
+                        query += ` OR `
+                }
+                query += `source_url LIKE ? ESCAPE '\'`
+                args = append(args, escapeLike(prefix)+"%")
+        }
+        query += `)`
+
+        rows, err := db.Query(query, args...)
+        if err != nil {
+                return nil, err
+        }
+        defer rows.Close()
+
+        var links []SiteLink
+        for rows.Next() {
+                var l SiteLink
+                if err := rows.Scan(&l.SourceURL, &l.TargetURL, &l.Nofollow); err != nil {
+                        return nil, err
+                }
+                links = append(links, l)
+        }
+
+        return links, rows.Err()
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern for ESCAPE '\'.
+func escapeLike(s string) string {
+        return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// GetFinalURL returns the address a URL was served from after redirects by
+// its latest completed crawl. Runs recorded before final addresses were
+// kept fall back to the source address of the URL's stored links, and
+// URLs never crawled to their own address.
+func GetFinalURL(db *sql.DB, urlID string) (string, error) {
+        query := `SELECT COALESCE(
+                                  (SELECT json_extract(r.metrics, '$.final_url') FROM crawl_runs r
+                                   JOIN urls u ON r.id = u.latest_run_id WHERE u.id = ?),
+                                  (SELECT source_url FROM links WHERE source_url_id = ? LIMIT 1),
+                                  url)
+                          FROM urls WHERE id = ?`
+
+        var address string
+        err := db.QueryRow(query, urlID, urlID, urlID).Scan(&address)
+        return address, err
+}
+
+// GetAllURLs returns the id and address of every tracked URL.
+func GetAllURLs(db *sql.DB) (map[string]string, error) {
+        rows, err := db.Query(`SELECT id, url FROM urls`)
+        if err != nil {
+                return nil, err
+        }
+        defer rows.Close()
+
+        urls := make(map[string]string)
+        for rows.Next() {
+                var id, url string
+                if err := rows.Scan(&id, &url); err != nil {
+                        return nil, err
+                }
+                urls[url] = id
+        }
+
+        return urls, rows.Err()
+}
+
+// StartSiteAnalysis records an analysis that is still running.
+func StartSiteAnalysis(db *sql.DB, seedURLID, host string) (*SiteAnalysis, error) {
+        analysis := &SiteAnalysis{
+                ID:        uuid.New().String(),
+                SeedURLID: seedURLID,
+                Host:      host,
+                Status:    "running",
+                CreatedAt: time.Now(),
+        }
+
+        query := `INSERT INTO site_analyses (id, seed_url_id, host, status, created_at) VALUES (?, ?, ?, ?, ?)`
+        if _, err := db.Exec(query, analysis.ID, analysis.SeedURLID, analysis.Host, analysis.Status, analysis.CreatedAt); err != nil {
+                return nil, err
+        }
+
+        return analysis, nil
+}
+
+// CompleteSiteAnalysis stores the results of a running analysis with its
+// pages.
+func CompleteSiteAnalysis(db *sql.DB, analysis *SiteAnalysis) error {
+        analysis.Status = "completed"
+
+        tx, err := db.Begin()
+        if err != nil {
+                return err
+        }
+        defer tx.Rollback()
+
+        query := `UPDATE site_analyses SET host = ?, page_count = ?, crawled_count = ?, max_depth = ?, unreachable = ?,
+                          deep_pages = ?, orphan_pages = ?, sitemap_urls = ?, status = ? WHERE id = ?`
+        if _, err := tx.Exec(query, analysis.Host, analysis.PageCount, analysis.CrawledCount, analysis.MaxDepth,
+                analysis.Unreachable, analysis.DeepPages, analysis.OrphanPages, analysis.SitemapURLs,
+                analysis.Status, analysis.ID); err != nil {
+                return err
+        }
+
+        query = `INSERT INTO site_analysis_pages (analysis_id, url, url_id, crawled, depth, inlinks, outlinks,
+                         pagerank, score, in_sitemap, orphan, deep) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+        for _, p := range analysis.Pages {
+                if _, err := tx.Exec(query, analysis.ID, p.URL, p.URLID, p.Crawled, p.Depth, p.Inlinks, p.Outlinks,
+                        p.PageRank, p.Score, p.InSitemap, p.Orphan, p.Deep); err != nil {
+                        return err
+                }
+        }
+
+        return tx.Commit()
+}
+
+// FailSiteAnalysis marks a running analysis as failed.
+func FailSiteAnalysis(db *sql.DB, id, errorMsg string) error {
+        _, err := db.Exec(`UPDATE site_analyses SET status = 'error', error_message = ? WHERE id = ?`, errorMsg, id)
+        return err
+}
+
+// FailRunningSiteAnalyses marks every analysis still running as failed.
+func FailRunningSiteAnalyses(db *sql.DB, errorMsg string) error {
+        _, err := db.Exec(`UPDATE site_analyses SET status = 'error', error_message = ? WHERE status = 'running'`, errorMsg)
+        return err
+}
+
+const siteAnalysisColumns = `id, seed_url_id, host, page_count, crawled_count, max_depth, unreachable,
+        deep_pages, orphan_pages, sitemap_urls, status, error_message, created_at`
+
+func scanSiteAnalysis(row rowScanner) (*SiteAnalysis, error) {
+        var a SiteAnalysis
+        err := row.Scan(&a.ID, &a.SeedURLID, &a.Host, &a.PageCount, &a.CrawledCount, &a.MaxDepth,
+                &a.Unreachable, &a.DeepPages, &a.OrphanPages, &a.SitemapURLs, &a.Status, &a.ErrorMessage, &a.CreatedAt)
+        if err != nil {
+                return nil, err
+        }
+        return &a, nil
+}
+
+// GetSiteAnalyses returns the analyses run from a seed URL, newest first,
+// without their pages.
+func GetSiteAnalyses(db *sql.DB, seedURLID string, limit int) ([]SiteAnalysis, error) {
+        query := `SELECT ` + siteAnalysisColumns + ` FROM site_analyses WHERE seed_url_id = ? ORDER BY created_at DESC LIMIT ?`
+
+        rows, err := db.Query(query, seedURLID, limit)
+        if err != nil {
+                return nil, err
+        }
+        defer rows.Close()
+
+        analyses := []SiteAnalysis{}
+        for rows.Next() {
+                a, err := scanSiteAnalysis(rows)
+                if err != nil {
+                        return nil, err
+                }
+                analyses = append(analyses, *a)
+        }
+
+        return analyses, rows.Err()
+}
+
+// GetSiteAnalysis returns an analysis with its pages ordered by score.
+func GetSiteAnalysis(db *sql.DB, id string) (*SiteAnalysis, error) {
+        query := `SELECT ` + siteAnalysisColumns + ` FROM site_analyses WHERE id = ?`
+
+        analysis, err := scanSiteAnalysis(db.QueryRow(query, id))
+        if err != nil {
+                return nil, err
+        }
+
+        rows, err := db.Query(`SELECT url, url_id, crawled, depth, inlinks, outlinks, pagerank, score, in_sitemap, orphan, deep
+                          FROM site_analysis_pages WHERE analysis_id = ? ORDER BY score DESC, url`, id)
+        if err != nil {
+                return nil, err
+        }
+        defer rows.Close()
+
+        analysis.Pages = []SiteAnalysisPage{}
+        for rows.Next() {
+                var p SiteAnalysisPage
+                err := rows.Scan(&p.URL, &p.URLID, &p.Crawled, &p.Depth, &p.Inlinks, &p.Outlinks, &p.PageRank,
+                        &p.Score, &p.InSitemap, &p.Orphan, &p.Deep)
+                if err != nil {
+                        return nil, err
+                }
+                analysis.Pages = append(analysis.Pages, p)
+        }
+
+        return analysis, rows.Err()
+}
@@ -0,0 +1,22 @@
+package models
+
+import "testing"
+
+func TestGetSiteLinksMatchesPrefixLiterally(t *testing.T) {
+        db := openTestDB(t)
+        for _, address := range []string{"https://a_b.test/", "https://axb.test/"} {
+                u := createTestURL(t, db, address)
+                links := []Link{{SourceURL: address, TargetURL: address + "page", Element: "a", Internal: true}}
+                if err := ReplaceLinks(db, u.ID, links); err != nil {
+                        t.Fatal(err)
+                }
+        }
+
+        links, err := GetSiteLinks(db, []string{"https://a_b.test/"})
+        if err != nil {
+                t.Fatal(err)
+        }
+        if len(links) != 1 || links[0].SourceURL != "https://a_b.test/" {
+                t.Errorf("GetSiteLinks = %+v, want only the links of a_b.test", links)
+        }
+}
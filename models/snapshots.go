@@ -0,0 +1,158 @@
+package models
+
+import (
+        "bytes"
+        "compress/gzip"
+        "crypto/sha256"
+        "database/sql"
+        "encoding/hex"
+        "io"
+        "time"
+
+        "github.com/google/uuid"
+)
+
+// Snapshot is the archived raw response of one crawl. The body is stored
+// decoded and gzip-compressed in snapshot_blobs, shared by all snapshots
+// with the same content hash.
+type Snapshot struct {
+        ID          string    `json:"id"`
+        URLID       string    `json:"url_id"`
+        TargetURL   string    `json:"target_url"`
+        Protocol    string    `json:"protocol"`
+        StatusCode  int       `json:"status_code"`
+        Status      string    `json:"status"`
+        Headers     string    `json:"headers"`
+        ContentHash string    `json:"content_hash"`
+        BodySize    int64     `json:"body_size"`
+        FetchedAt   time.Time `json:"fetched_at"`
+}
+
+// ContentHash returns the hex SHA-256 used to deduplicate snapshot bodies.
+func ContentHash(body []byte) string {
+        sum := sha256.Sum256(body)
+        return hex.EncodeToString(sum[:])
+}
+
+func GetSnapshots(db *sql.DB, urlID string) ([]Snapshot, error) {
+        query := `SELECT id, url_id, target_url, protocol, status_code, status, headers, content_hash, body_size, fetched_at
+                          FROM snapshots WHERE url_id = ? ORDER BY fetched_at DESC`
+
+        rows, err := db.Query(query, urlID)
+        if err != nil {
+                return nil, err
+        }
+        defer rows.Close()
+
+        snapshots := []Snapshot{}
+        for rows.Next() {
+                var s Snapshot
+                err := rows.Scan(&s.ID, &s.URLID, &s.TargetURL, &s.Protocol, &s.StatusCode, &s.Status,
+                        &s.Headers, &s.ContentHash, &s.BodySize, &s.FetchedAt)
+                if err != nil {
+                        return nil, err
+                }
+                snapshots = append(snapshots, s)
+        }
+
+        return snapshots, rows.Err()
+}
+
+func GetSnapshot(db *sql.DB, id string) (*Snapshot, error) {
+        query := `SELECT id, url_id, target_url, protocol, status_code, status, headers, content_hash, body_size, fetched_at
+                          FROM snapshots WHERE id = ?`
+
+        var s Snapshot
+        err := db.QueryRow(query, id).Scan(&s.ID, &s.URLID, &s.TargetURL, &s.Protocol, &s.StatusCode, &s.Status,
+                &s.Headers, &s.ContentHash, &s.BodySize, &s.FetchedAt)
+        if err != nil {
+                return nil, err
+        }
+
+        return &s, nil
+}
+
+// GetSnapshotBody returns the decompressed body stored under a content hash.
+func GetSnapshotBody(db *sql.DB, contentHash string) ([]byte, error) {
+        var data []byte
+        err := db.QueryRow(`SELECT data FROM snapshot_blobs WHERE hash = ?`, contentHash).Scan(&data)
+        if err != nil {
+                return nil, err
+        }
+
+        r, err := gzip.NewReader(bytes.NewReader(data))
+        if err != nil {
+                return nil, err
+        }
+        defer r.Close()
+        return io.ReadAll(r)
+}
+
+// CreateSnapshot archives a response and prunes the URL's snapshots down to
+// the newest retention ones. Bodies no longer referenced are deleted.
+func CreateSnapshot(db *sql.DB, s Snapshot, body []byte, retention int) error {
+        var compressed bytes.Buffer
+        gz := gzip.NewWriter(&compressed)
+        if _, err := gz.Write(body); err != nil {
+                return err
+        }
+        if err := gz.Close(); err != nil {
+                return err
+        }
+
+        tx, err := db.Begin()
+        if err != nil {
+                return err
+        }
+        defer tx.Rollback()
+
+        s.ContentHash = ContentHash(body)
+        _, err = tx.Exec(`INSERT OR IGNORE INTO snapshot_blobs (hash, data, size, created_at) VALUES (?, ?, ?, ?)`,
+                s.ContentHash, compressed.Bytes(), len(body), time.Now())
+        if err != nil {
+                return err
+        }
+
+        query := `INSERT INTO snapshots (id, url_id, target_url, protocol, status_code, status, headers, content_hash, body_size, fetched_at)
+                          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+        _, err = tx.Exec(query, uuid.New().String(), s.URLID, s.TargetURL, s.Protocol, s.StatusCode, s.Status,
+                s.Headers, s.ContentHash, len(body), s.FetchedAt)
+        if err != nil {
+                return err
+        }
+
+        if retention > 0 {
+                result, err := tx.Exec(`DELETE FROM snapshots WHERE url_id = ? AND id NOT IN (
+                        SELECT id FROM snapshots WHERE url_id = ? ORDER BY fetched_at DESC LIMIT ?)`,
+                        s.URLID, s.URLID, retention)
+                if err != nil {
+                        return err
+                }
+                pruned, err := result.RowsAffected()
+                if err != nil {
+                        return err
+                }
+
+                // Only pruning can leave blobs without a snapshot
+                if pruned > 0 {
+                        if err := deleteOrphanBlobs(tx); err != nil {
+                                return err
+                        }
+                }
+        }
+
+        return tx.Commit()
+}
+
+// deleteOrphanBlobs removes the snapshot bodies no snapshot refers to.
+func deleteOrphanBlobs(db Execer) error {
+        _, err := db.Exec(`DELETE FROM snapshot_blobs WHERE hash NOT IN (SELECT content_hash FROM snapshots)`)
+        return err
+}
+
+// SetSnapshotRetention overrides how many snapshots are kept for a URL; nil
+// falls back to the server default.
+func SetSnapshotRetention(db *sql.DB, urlID string, retention *int) error {
+        _, err := db.Exec(`UPDATE urls SET snapshot_retention = ? WHERE id = ?`, retention, urlID)
+        return err
+}
@@ -0,0 +1,42 @@
+package models
+
+import "testing"
+
+func TestDeleteURLRemovesSnapshots(t *testing.T) {
+        db := openTestDB(t)
+        a := createTestURL(t, db, "https://example.com/a")
+        b := createTestURL(t, db, "https://example.com/b")
+
+        snapshot := func(u *URL, body string) {
+                t.Helper()
+                s := Snapshot{URLID: u.ID, TargetURL: u.URL, Protocol: "HTTP/1.1", StatusCode: 200, Status: "200 OK", Headers: "{}"}
+                if err := CreateSnapshot(db, s, []byte(body), 0); err != nil {
+                        t.Fatal(err)
+                }
+        }
+        snapshot(a, "only a")
+        snapshot(a, "shared")
+        snapshot(b, "shared")
+
+        if err := DeleteURL(db, a.ID); err != nil {
+                t.Fatal(err)
+        }
+
+        count := func(query string, args ...interface{}) int {
+                t.Helper()
+                var n int
+                if err := db.QueryRow(query, args...).Scan(&n); err != nil {
+                        t.Fatal(err)
+                }
+                return n
+        }
+        if n := count(`SELECT COUNT(*) FROM snapshots WHERE url_id = ?`, a.ID); n != 0 {
+                t.Errorf("deleted URL still has %d snapshots", n)
+        }
+        if n := count(`SELECT COUNT(*) FROM snapshot_blobs WHERE hash = ?`, ContentHash([]byte("only a"))); n != 0 {
+                t.Errorf("blob of the deleted URL was kept")
+        }
+        if n := count(`SELECT COUNT(*) FROM snapshot_blobs WHERE hash = ?`, ContentHash([]byte("shared"))); n != 1 {
+                t.Errorf("blob shared with another URL was deleted")
+        }
+}
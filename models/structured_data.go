@@ -0,0 +1,87 @@
+package models
+
+import (
+        "database/sql"
+        "encoding/json"
+        "time"
+
+        "github.com/google/uuid"
+)
+
+// StructuredData is a single JSON-LD, Microdata or RDFa entity found on a
+// crawled page, together with any validation errors raised for it.
+type StructuredData struct {
+        ID        string          `json:"id"`
+        URLID     string          `json:"url_id"`
+        Format    string          `json:"format"`
+        Type      string          `json:"type"`
+        Data      json.RawMessage `json:"data"`
+        Errors    []string        `json:"errors"`
+        CreatedAt time.Time       `json:"created_at"`
+}
+
+func GetStructuredData(db *sql.DB, urlID string) ([]StructuredData, error) {
+        query := `SELECT id, url_id, format, type, data, errors, created_at
+                          FROM structured_data WHERE url_id = ? ORDER BY created_at, rowid`
+
+        rows, err := db.Query(query, urlID)
+        if err != nil {
+                return nil, err
+        }
+        defer rows.Close()
+
+        items := []StructuredData{}
+        for rows.Next() {
+                var item StructuredData
+                var typ, errs sql.NullString
+                var data string
+                err := rows.Scan(&item.ID, &item.URLID, &item.Format, &typ, &data, &errs, &item.CreatedAt)
+                if err != nil {
+                        return nil, err
+                }
+                item.Type = typ.String
+                item.Data = json.RawMessage(data)
+                item.Errors = []string{}
+                if errs.Valid && errs.String != "" {
+                        if err := json.Unmarshal([]byte(errs.String), &item.Errors); err != nil {
+                                return nil, err
+                        }
+                }
+                items = append(items, item)
+        }
+
+        return items, rows.Err()
+}
+
+// ReplaceStructuredData swaps the stored entities for a URL with the ones
+// found by the latest crawl.
+func ReplaceStructuredData(db *sql.DB, urlID string, items []StructuredData) error {
+        tx, err := db.Begin()
+        if err != nil {
+                return err
+        }
+        defer tx.Rollback()
+
+        if _, err := tx.Exec(`DELETE FROM structured_data WHERE url_id = ?`, urlID); err != nil {
+                return err
+        }
+
+        query := `INSERT INTO structured_data (id, url_id, format, type, data, errors, created_at)
+                          VALUES (?, ?, ?, ?, ?, ?, ?)`
+        now := time.Now()
+        for _, item := range items {
+                var errs []byte
+                if len(item.Errors) > 0 {
+                        if errs, err = json.Marshal(item.Errors); err != nil {
+                                return err
+                        }
+                }
+                _, err = tx.Exec(query, uuid.New().String(), urlID, item.Format, item.Type,
+                        string(item.Data), string(errs), now)
+                if err != nil {
+                        return err
+                }
+        }
+
+        return tx.Commit()
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+        "database/sql"
+        "time"
+
+        "github.com/google/uuid"
+)
+
+// Technology is a product detected on a crawled page by the fingerprinting
+// engine.
+type Technology struct {
+        ID        string    `json:"id"`
+        URLID     string    `json:"url_id"`
+        Name      string    `json:"name"`
+        Category  string    `json:"category"`
+        Version   string    `json:"version"`
+        CreatedAt time.Time `json:"created_at"`
+}
+
+func GetTechnologies(db *sql.DB, urlID string) ([]Technology, error) {
+        query := `SELECT id, url_id, name, category, version, created_at
+                          FROM technologies WHERE url_id = ? ORDER BY category, name`
+
+        rows, err := db.Query(query, urlID)
+        if err != nil {
+                return nil, err
+        }
+        defer rows.Close()
+
+        technologies := []Technology{}
+        for rows.Next() {
+                var t Technology
+                if err := rows.Scan(&t.ID, &t.URLID, &t.Name, &t.Category, &t.Version, &t.CreatedAt); err != nil {
+                        return nil, err
+                }
+                technologies = append(technologies, t)
+        }
+
+        return technologies, rows.Err()
+}
+
+// ReplaceTechnologies swaps the stored technologies for a URL with the ones
+// detected by the latest crawl.
+func ReplaceTechnologies(db *sql.DB, urlID string, technologies []Technology) error {
+        tx, err := db.Begin()
+        if err != nil {
+                return err
+        }
+        defer tx.Rollback()
+
+        if _, err := tx.Exec(`DELETE FROM technologies WHERE url_id = ?`, urlID); err != nil {
+                return err
+        }
+
+        query := `INSERT INTO technologies (id, url_id, name, category, version, created_at)
+                          VALUES (?, ?, ?, ?, ?, ?)`
+        now := time.Now()
+        for _, t := range technologies {
+                if _, err := tx.Exec(query, uuid.New().String(), urlID, t.Name, t.Category, t.Version, now); err != nil {
+                        return err
+                }
+        }
+
+        return tx.Commit()
+}
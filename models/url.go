@@ -2,14 +2,23 @@ package models
 
 import (
         "database/sql"
+        "encoding/json"
+        "errors"
+        "strings"
         "time"
 
         "github.com/google/uuid"
+        "github.com/mattn/go-sqlite3"
 )
 
+// ErrDuplicateURL is returned when a URL's normalized key is already used
+// by another tracked URL.
+var ErrDuplicateURL = errors.New("URL already exists")
+
 type URL struct {
         ID            string     `json:"id"`
         URL           string     `json:"url"`
+        NormalizedURL *string    `json:"normalized_url"`
         Status        string     `json:"status"`
         CreatedAt     time.Time  `json:"created_at"`
         LastCrawled   *time.Time `json:"last_crawled"`
@@ -26,29 +35,201 @@ type URL struct {
         BrokenLinks   int        `json:"broken_links"`
         HasLoginForm  bool       `json:"has_login_form"`
         ErrorMessage  *string    `json:"error_message"`
+
+        StructuredDataCount   int `json:"structured_data_count"`
+        StructuredDataErrors  int `json:"structured_data_errors"`
+        AccessibilityErrors   int `json:"accessibility_errors"`
+        AccessibilityWarnings int `json:"accessibility_warnings"`
+        HeadingIssues         int `json:"heading_issues"`
+
+        LoginFormConfidence float64 `json:"login_form_confidence"`
+        LoginFormType       *string `json:"login_form_type"`
+        FormCount           int     `json:"form_count"`
+        InsecureForms       int     `json:"insecure_forms"`
+        SecurityScore       *int    `json:"security_score"`
+
+        MixedContentActive  int `json:"mixed_content_active"`
+        MixedContentPassive int `json:"mixed_content_passive"`
+        InsecureLinks       int `json:"insecure_links"`
+
+        WordCount          int      `json:"word_count"`
+        ReadingTimeSeconds int      `json:"reading_time_seconds"`
+        Language           *string  `json:"language"`
+        FleschReadingEase  *float64 `json:"flesch_reading_ease"`
+        SnapshotRetention  *int     `json:"snapshot_retention"`
+        LatestRunID        *string  `json:"latest_run_id"`
+
+        SignificantChange bool       `json:"significant_change"`
+        ChangeSimilarity  *float64   `json:"change_similarity"`
+        LastChangedAt     *time.Time `json:"last_changed_at"`
+
+        Schedule        *URLSchedule `json:"schedule"`
+        NextScheduledAt *time.Time   `json:"next_scheduled_at"`
+        LastScheduledAt *time.Time   `json:"last_scheduled_at"`
+
+        AssertionStatus  *string `json:"assertion_status"`
+        AssertionsFailed int     `json:"assertions_failed"`
+
+        // Extracted maps each extraction rule name to its latest value
+        Extracted json.RawMessage `json:"extracted"`
+
+        DNSOverrides []DNSOverride `json:"dns_overrides"`
+        // TLSInsecure makes crawls accept invalid server certificates
+        TLSInsecure bool `json:"tls_insecure"`
+
+        Tags []string `json:"tags"`
 }
 
 type BrokenLink struct {
         ID           string    `json:"id"`
         URLID        string    `json:"url_id"`
+        RunID        *string   `json:"run_id"`
         LinkURL      string    `json:"link_url"`
         StatusCode   int       `json:"status_code"`
         ErrorMessage *string   `json:"error_message"`
         CreatedAt    time.Time `json:"created_at"`
 }
 
-func GetURLs(db *sql.DB, page, limit int, search, sortBy, sortOrder string) ([]URL, int, error) {
+// urlColumns is the column list shared by every query that loads a URL; it
+// must stay in sync with the field order in scanURL.
+const urlColumns = `id, url, status, created_at, last_crawled, title, html_version,
+        h1_count, h2_count, h3_count, h4_count, h5_count, h6_count,
+        internal_links, external_links, broken_links, has_login_form, error_message,
+        structured_data_count, structured_data_errors, accessibility_errors, accessibility_warnings,
+        heading_issues, login_form_confidence, login_form_type, form_count, insecure_forms,
+        security_score, mixed_content_active, mixed_content_passive, insecure_links,
+        word_count, reading_time_seconds, language, flesch_reading_ease, snapshot_retention,
+        latest_run_id, significant_change, change_similarity, last_changed_at,
+        schedule_cron, schedule_interval_seconds, schedule_timezone, schedule_jitter_seconds,
+        next_scheduled_at, last_scheduled_at, assertion_status, assertions_failed, extracted,
+        normalized_url, dns_overrides, tls_insecure, tags`
+
+type rowScanner interface {
+        Scan(dest ...interface{}) error
+}
+
+// Execer runs statements on a *sql.DB or within a *sql.Tx, so writes can
+// be grouped in a transaction by the caller.
+type Execer interface {
+        Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func scanURL(row rowScanner) (*URL, error) {
+        var url URL
+        var schedule URLSchedule
+        var timezone sql.NullString
+        var jitter sql.NullInt64
+        var extracted, dnsOverrides, tags sql.NullString
+        err := row.Scan(&url.ID, &url.URL, &url.Status, &url.CreatedAt, &url.LastCrawled,
+                &url.Title, &url.HTMLVersion, &url.H1Count, &url.H2Count, &url.H3Count,
+                &url.H4Count, &url.H5Count, &url.H6Count, &url.InternalLinks,
+                &url.ExternalLinks, &url.BrokenLinks, &url.HasLoginForm, &url.ErrorMessage,
+                &url.StructuredDataCount, &url.StructuredDataErrors, &url.AccessibilityErrors,
+                &url.AccessibilityWarnings, &url.HeadingIssues, &url.LoginFormConfidence,
+                &url.LoginFormType, &url.FormCount, &url.InsecureForms, &url.SecurityScore,
+                &url.MixedContentActive, &url.MixedContentPassive, &url.InsecureLinks,
+                &url.WordCount, &url.ReadingTimeSeconds, &url.Language, &url.FleschReadingEase,
+                &url.SnapshotRetention, &url.LatestRunID,
+                &url.SignificantChange, &url.ChangeSimilarity, &url.LastChangedAt,
+                &schedule.Cron, &schedule.IntervalSeconds, &timezone, &jitter,
+                &url.NextScheduledAt, &url.LastScheduledAt, &url.AssertionStatus, &url.AssertionsFailed,
+                &extracted, &url.NormalizedURL, &dnsOverrides, &url.TLSInsecure, &tags)
+        if err != nil {
+                return nil, err
+        }
+
+        if schedule.Cron != nil || schedule.IntervalSeconds != nil {
+                schedule.Timezone = timezone.String
+                schedule.JitterSeconds = int(jitter.Int64)
+                url.Schedule = &schedule
+        }
+        if extracted.Valid {
+                url.Extracted = json.RawMessage(extracted.String)
+        }
+        if dnsOverrides.Valid {
+                if err := json.Unmarshal([]byte(dnsOverrides.String), &url.DNSOverrides); err != nil {
+                        return nil, err
+                }
+        }
+        url.Tags = []string{}
+        if tags.Valid {
+                if err := json.Unmarshal([]byte(tags.String), &url.Tags); err != nil {
+                        return nil, err
+                }
+        }
+
+        return &url, nil
+}
+
+// URLFilter narrows the URL list returned by GetURLs.
+type URLFilter struct {
+        Search             string
+        MinSecurityScore   *int
+        MaxSecurityScore   *int
+        Technology         string
+        TechnologyCategory string
+        Content            string
+        Changed            *bool
+        AssertionStatus    string
+        Tag                string
+}
+
+func (f URLFilter) whereClause() (string, []interface{}) {
+        conditions := []string{}
+        args := []interface{}{}
+
+        if f.Search != "" {
+                conditions = append(conditions, "(url LIKE ? OR title LIKE ?)")
+                args = append(args, "%"+f.Search+"%", "%"+f.Search+"%")
+        }
+        if f.MinSecurityScore != nil {
+                conditions = append(conditions, "security_score >= ?")
+                args = append(args, *f.MinSecurityScore)
+        }
+        if f.MaxSecurityScore != nil {
+                conditions = append(conditions, "security_score <= ?")
+                args = append(args, *f.MaxSecurityScore)
+        }
+        if f.Technology != "" {
+                conditions = append(conditions, "id IN (SELECT url_id FROM technologies WHERE name = ? COLLATE NOCASE)")
+                args = append(args, f.Technology)
+        }
+        if f.TechnologyCategory != "" {
+                conditions = append(conditions, "id IN (SELECT url_id FROM technologies WHERE category = ? COLLATE NOCASE)")
+                args = append(args, f.TechnologyCategory)
+        }
+        if f.Content != "" {
+                conditions = append(conditions, "id IN (SELECT url_id FROM page_content WHERE text LIKE ?)")
+                args = append(args, "%"+f.Content+"%")
+        }
+
+        if f.Changed != nil {
+                conditions = append(conditions, "significant_change = ?")
+                args = append(args, *f.Changed)
+        }
+
+        if f.AssertionStatus != "" {
+                conditions = append(conditions, "assertion_status = ?")
+                args = append(args, f.AssertionStatus)
+        }
+
+        if f.Tag != "" {
+                conditions = append(conditions, "EXISTS (SELECT 1 FROM json_each(urls.tags) WHERE value = ? COLLATE NOCASE)")
+                args = append(args, f.Tag)
+        }
+
+        if len(conditions) == 0 {
+                return "", args
+        }
+        return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func GetURLs(db *sql.DB, page, limit int, filter URLFilter, sortBy, sortOrder string) ([]URL, int, error) {
         // Calculate offset
         offset := (page - 1) * limit
 
         // Build query
-        whereClause := ""
-        args := []interface{}{}
-        
-        if search != "" {
-                whereClause = "WHERE url LIKE ? OR title LIKE ?"
-                args = append(args, "%"+search+"%", "%"+search+"%")
-        }
+        whereClause, args := filter.whereClause()
 
         // Count total
         countQuery := "SELECT COUNT(*) FROM urls " + whereClause
@@ -59,10 +240,7 @@ func GetURLs(db *sql.DB, page, limit int, search, sortBy, sortOrder string) ([]U
         }
 
         // Main query
-        query := `SELECT id, url, status, created_at, last_crawled, title, html_version, 
-                          h1_count, h2_count, h3_count, h4_count, h5_count, h6_count, 
-                          internal_links, external_links, broken_links, has_login_form, error_message
-                          FROM urls ` + whereClause + ` ORDER BY ` + sortBy + ` ` + sortOrder + ` LIMIT ? OFFSET ?`
+        query := `SELECT ` + urlColumns + ` FROM urls ` + whereClause + ` ORDER BY ` + urlOrderClause(sortBy, sortOrder) + ` LIMIT ? OFFSET ?`
         
         args = append(args, limit, offset)
         rows, err := db.Query(query, args...)
@@ -73,90 +251,189 @@ func GetURLs(db *sql.DB, page, limit int, search, sortBy, sortOrder string) ([]U
 
         var urls []URL
         for rows.Next() {
-                var url URL
-                err := rows.Scan(&url.ID, &url.URL, &url.Status, &url.CreatedAt, &url.LastCrawled,
-                        &url.Title, &url.HTMLVersion, &url.H1Count, &url.H2Count, &url.H3Count,
-                        &url.H4Count, &url.H5Count, &url.H6Count, &url.InternalLinks,
-                        &url.ExternalLinks, &url.BrokenLinks, &url.HasLoginForm, &url.ErrorMessage)
+                url, err := scanURL(rows)
                 if err != nil {
                         return nil, 0, err
                 }
-                urls = append(urls, url)
+                urls = append(urls, *url)
         }
 
         return urls, total, nil
 }
 
-func CreateURL(db *sql.DB, urlStr string) (*URL, error) {
+// StreamURLs calls fn for every URL matching the filter in the given order,
+// reading them one at a time from the database cursor.
+func StreamURLs(db *sql.DB, filter URLFilter, sortBy, sortOrder string, fn func(*URL) error) error {
+        whereClause, args := filter.whereClause()
+        query := `SELECT ` + urlColumns + ` FROM urls ` + whereClause + ` ORDER BY ` + urlOrderClause(sortBy, sortOrder)
+
+        rows, err := db.Query(query, args...)
+        if err != nil {
+                return err
+        }
+        defer rows.Close()
+
+        for rows.Next() {
+                url, err := scanURL(rows)
+                if err != nil {
+                        return err
+                }
+                if err := fn(url); err != nil {
+                        return err
+                }
+        }
+
+        return rows.Err()
+}
+
+// urlOrderClause builds the ORDER BY clause of URL lists. Only columns of
+// the urls table are accepted; anything else sorts by creation date.
+func urlOrderClause(sortBy, sortOrder string) string {
+        valid := false
+        for _, column := range strings.Split(urlColumns, ",") {
+                if strings.TrimSpace(column) == sortBy {
+                        valid = true
+                        break
+                }
+        }
+        if !valid {
+                sortBy = "created_at"
+        }
+
+        if strings.ToLower(sortOrder) == "asc" {
+                return sortBy + " ASC"
+        }
+        return sortBy + " DESC"
+}
+
+// CreateURL stores a new URL with its normalized key. It returns
+// ErrDuplicateURL when the key is already taken.
+func CreateURL(db Execer, urlStr, normalizedURL string) (*URL, error) {
         id := uuid.New().String()
         now := time.Now()
 
-        query := `INSERT INTO urls (id, url, status, created_at) VALUES (?, ?, 'pending', ?)`
-        _, err := db.Exec(query, id, urlStr, now)
+        query := `INSERT INTO urls (id, url, normalized_url, status, created_at) VALUES (?, ?, ?, 'pending', ?)`
+        _, err := db.Exec(query, id, urlStr, normalizedURL, now)
         if err != nil {
-                return nil, err
+                return nil, duplicateURLError(err)
         }
 
         return &URL{
-                ID:        id,
-                URL:       urlStr,
-                Status:    "pending",
-                CreatedAt: now,
+                ID:            id,
+                URL:           urlStr,
+                NormalizedURL: &normalizedURL,
+                Status:        "pending",
+                CreatedAt:     now,
         }, nil
 }
 
-func UpdateURL(db *sql.DB, id, urlStr string) (*URL, error) {
-        query := `UPDATE urls SET url = ? WHERE id = ?`
-        _, err := db.Exec(query, urlStr, id)
+func UpdateURL(db *sql.DB, id, urlStr, normalizedURL string) (*URL, error) {
+        query := `UPDATE urls SET url = ?, normalized_url = ? WHERE id = ?`
+        _, err := db.Exec(query, urlStr, normalizedURL, id)
         if err != nil {
-                return nil, err
+                return nil, duplicateURLError(err)
         }
 
         return GetURLByID(db, id)
 }
 
 func DeleteURL(db *sql.DB, id string) error {
+        tx, err := db.Begin()
+        if err != nil {
+                return err
+        }
+        defer tx.Rollback()
+
         query := `DELETE FROM urls WHERE id = ?`
-        _, err := db.Exec(query, id)
-        return err
+        if _, err := tx.Exec(query, id); err != nil {
+                return err
+        }
+
+        // The URL's snapshots are deleted with it; drop the bodies no other
+        // URL shares
+        if err := deleteOrphanBlobs(tx); err != nil {
+                return err
+        }
+
+        return tx.Commit()
 }
 
 func GetURLByID(db *sql.DB, id string) (*URL, error) {
-        query := `SELECT id, url, status, created_at, last_crawled, title, html_version, 
-                          h1_count, h2_count, h3_count, h4_count, h5_count, h6_count, 
-                          internal_links, external_links, broken_links, has_login_form, error_message
-                          FROM urls WHERE id = ?`
+        query := `SELECT ` + urlColumns + ` FROM urls WHERE id = ?`
         
-        var url URL
-        err := db.QueryRow(query, id).Scan(&url.ID, &url.URL, &url.Status, &url.CreatedAt,
-                &url.LastCrawled, &url.Title, &url.HTMLVersion, &url.H1Count, &url.H2Count,
-                &url.H3Count, &url.H4Count, &url.H5Count, &url.H6Count, &url.InternalLinks,
-                &url.ExternalLinks, &url.BrokenLinks, &url.HasLoginForm, &url.ErrorMessage)
+        return scanURL(db.QueryRow(query, id))
+}
+
+func GetURLByURL(db *sql.DB, urlStr string) (*URL, error) {
+        query := `SELECT ` + urlColumns + ` FROM urls WHERE url = ?`
         
+        return scanURL(db.QueryRow(query, urlStr))
+}
+
+// GetURLByNormalizedURL returns the URL tracked under a normalized key.
+func GetURLByNormalizedURL(db *sql.DB, normalizedURL string) (*URL, error) {
+        query := `SELECT ` + urlColumns + ` FROM urls WHERE normalized_url = ?`
+
+        return scanURL(db.QueryRow(query, normalizedURL))
+}
+
+// GetNormalizedKeys returns the id, address and normalized key of every
+// URL, oldest first.
+func GetNormalizedKeys(db *sql.DB) ([]URL, error) {
+        rows, err := db.Query(`SELECT id, url, normalized_url FROM urls ORDER BY created_at`)
         if err != nil {
                 return nil, err
         }
+        defer rows.Close()
 
-        return &url, nil
+        var urls []URL
+        for rows.Next() {
+                var url URL
+                if err := rows.Scan(&url.ID, &url.URL, &url.NormalizedURL); err != nil {
+                        return nil, err
+                }
+                urls = append(urls, url)
+        }
+
+        return urls, rows.Err()
 }
 
-func GetURLByURL(db *sql.DB, urlStr string) (*URL, error) {
-        query := `SELECT id, url, status, created_at, last_crawled, title, html_version, 
-                          h1_count, h2_count, h3_count, h4_count, h5_count, h6_count, 
-                          internal_links, external_links, broken_links, has_login_form, error_message
-                          FROM urls WHERE url = ?`
-        
-        var url URL
-        err := db.QueryRow(query, urlStr).Scan(&url.ID, &url.URL, &url.Status, &url.CreatedAt,
-                &url.LastCrawled, &url.Title, &url.HTMLVersion, &url.H1Count, &url.H2Count,
-                &url.H3Count, &url.H4Count, &url.H5Count, &url.H6Count, &url.InternalLinks,
-                &url.ExternalLinks, &url.BrokenLinks, &url.HasLoginForm, &url.ErrorMessage)
-        
-        if err != nil {
-                return nil, err
+// SetNormalizedURL stores the normalized key of a URL. It returns
+// ErrDuplicateURL when the key is already taken.
+func SetNormalizedURL(db *sql.DB, id, normalizedURL string) error {
+        _, err := db.Exec(`UPDATE urls SET normalized_url = ? WHERE id = ?`, normalizedURL, id)
+        return duplicateURLError(err)
+}
+
+// duplicateURLError maps a unique constraint violation to ErrDuplicateURL.
+func duplicateURLError(err error) error {
+        var sqliteErr sqlite3.Error
+        if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+                return ErrDuplicateURL
+        }
+        return err
+}
+
+// SetURLTags replaces the tags of a URL.
+func SetURLTags(db Execer, id string, tags []string) error {
+        var value interface{}
+        if len(tags) > 0 {
+                encoded, err := json.Marshal(tags)
+                if err != nil {
+                        return err
+                }
+                value = string(encoded)
         }
 
-        return &url, nil
+        _, err := db.Exec(`UPDATE urls SET tags = ? WHERE id = ?`, value, id)
+        return err
+}
+
+// SetTLSInsecure sets whether crawls of a URL accept invalid server
+// certificates.
+func SetTLSInsecure(db Execer, id string, insecure bool) error {
+        _, err := db.Exec(`UPDATE urls SET tls_insecure = ? WHERE id = ?`, insecure, id)
+        return err
 }
 
 func UpdateURLStatus(db *sql.DB, id, status string) error {
@@ -167,27 +444,68 @@ func UpdateURLStatus(db *sql.DB, id, status string) error {
 
 func UpdateURLData(db *sql.DB, id string, data map[string]interface{}) error {
         now := time.Now()
+
+        // Extracted values are kept as a JSON object
+        var extracted interface{}
+        if data["extracted"] != nil {
+                encoded, err := json.Marshal(data["extracted"])
+                if err != nil {
+                        return err
+                }
+                extracted = string(encoded)
+        }
         
         query := `UPDATE urls SET 
                           last_crawled = ?, title = ?, html_version = ?, 
                           h1_count = ?, h2_count = ?, h3_count = ?, h4_count = ?, h5_count = ?, h6_count = ?,
                           internal_links = ?, external_links = ?, broken_links = ?, has_login_form = ?, 
+                          structured_data_count = ?, structured_data_errors = ?,
+                          accessibility_errors = ?, accessibility_warnings = ?, heading_issues = ?,
+                          login_form_confidence = ?, login_form_type = ?, form_count = ?, insecure_forms = ?,
+                          security_score = ?, mixed_content_active = ?, mixed_content_passive = ?, insecure_links = ?,
+                          word_count = ?, reading_time_seconds = ?, language = ?, flesch_reading_ease = ?,
+                          significant_change = ?, change_similarity = ?, assertion_status = ?, assertions_failed = ?,
+                          extracted = ?,
                           status = 'completed'
                           WHERE id = ?`
         
         _, err := db.Exec(query, now, data["title"], data["html_version"],
                 data["h1_count"], data["h2_count"], data["h3_count"], data["h4_count"],
                 data["h5_count"], data["h6_count"], data["internal_links"],
-                data["external_links"], data["broken_links"], data["has_login_form"], id)
+                data["external_links"], data["broken_links"], data["has_login_form"],
+                data["structured_data_count"], data["structured_data_errors"],
+                data["accessibility_errors"], data["accessibility_warnings"], data["heading_issues"],
+                data["login_form_confidence"], data["login_form_type"], data["form_count"],
+                data["insecure_forms"], data["security_score"], data["mixed_content_active"],
+                data["mixed_content_passive"], data["insecure_links"], data["word_count"],
+                data["reading_time_seconds"], data["language"], data["flesch_reading_ease"],
+                data["significant_change"], data["change_similarity"], data["assertion_status"],
+                data["assertions_failed"], extracted, id)
         
         return err
 }
 
+// GetBrokenLinks returns the broken links found by the URL's latest crawl.
+// Links stored before crawl runs were recorded have no run and are returned
+// until the URL is crawled again.
 func GetBrokenLinks(db *sql.DB, urlID string) ([]BrokenLink, error) {
-        query := `SELECT id, url_id, link_url, status_code, error_message, created_at 
-                          FROM broken_links WHERE url_id = ?`
-        
-        rows, err := db.Query(query, urlID)
+        query := `SELECT id, url_id, run_id, link_url, status_code, error_message, created_at 
+                          FROM broken_links WHERE url_id = ?
+                          AND COALESCE(run_id, '') = COALESCE((SELECT latest_run_id FROM urls WHERE id = ?), '')`
+
+        return queryBrokenLinks(db, query, urlID, urlID)
+}
+
+// GetRunBrokenLinks returns the broken links found by one crawl run.
+func GetRunBrokenLinks(db *sql.DB, runID string) ([]BrokenLink, error) {
+        query := `SELECT id, url_id, run_id, link_url, status_code, error_message, created_at 
+                          FROM broken_links WHERE run_id = ?`
+
+        return queryBrokenLinks(db, query, runID)
+}
+
+func queryBrokenLinks(db *sql.DB, query string, args ...interface{}) ([]BrokenLink, error) {
+        rows, err := db.Query(query, args...)
         if err != nil {
                 return nil, err
         }
@@ -196,7 +514,7 @@ func GetBrokenLinks(db *sql.DB, urlID string) ([]BrokenLink, error) {
         var links []BrokenLink
         for rows.Next() {
                 var link BrokenLink
-                err := rows.Scan(&link.ID, &link.URLID, &link.LinkURL, &link.StatusCode,
+                err := rows.Scan(&link.ID, &link.URLID, &link.RunID, &link.LinkURL, &link.StatusCode,
                         &link.ErrorMessage, &link.CreatedAt)
                 if err != nil {
                         return nil, err
@@ -207,10 +525,47 @@ func GetBrokenLinks(db *sql.DB, urlID string) ([]BrokenLink, error) {
         return links, nil
 }
 
-func CreateBrokenLink(db *sql.DB, urlID, linkURL string, statusCode int, errorMsg string) error {
+// StreamBrokenLinks calls fn for every broken link found by the latest
+// crawl of each URL, or of one URL when urlID is set, together with the
+// address of the page containing it. Links are read one at a time from the
+// database cursor.
+func StreamBrokenLinks(db *sql.DB, urlID string, fn func(link BrokenLink, pageURL string) error) error {
+        query := `SELECT b.id, b.url_id, b.run_id, b.link_url, b.status_code, b.error_message, b.created_at, u.url
+                          FROM broken_links b JOIN urls u ON u.id = b.url_id
+                          WHERE COALESCE(b.run_id, '') = COALESCE(u.latest_run_id, '')`
+        args := []interface{}{}
+        if urlID != "" {
+                query += ` AND b.url_id = ?`
+                args = append(args, urlID)
+        }
+        query += ` ORDER BY u.url, b.link_url`
+
+        rows, err := db.Query(query, args...)
+        if err != nil {
+                return err
+        }
+        defer rows.Close()
+
+        for rows.Next() {
+                var link BrokenLink
+                var pageURL string
+                err := rows.Scan(&link.ID, &link.URLID, &link.RunID, &link.LinkURL, &link.StatusCode,
+                        &link.ErrorMessage, &link.CreatedAt, &pageURL)
+                if err != nil {
+                        return err
+                }
+                if err := fn(link, pageURL); err != nil {
+                        return err
+                }
+        }
+
+        return rows.Err()
+}
+
+func CreateBrokenLink(db *sql.DB, urlID, runID, linkURL string, statusCode int, errorMsg string) error {
         id := uuid.New().String()
-        query := `INSERT INTO broken_links (id, url_id, link_url, status_code, error_message) 
-                          VALUES (?, ?, ?, ?, ?)`
-        _, err := db.Exec(query, id, urlID, linkURL, statusCode, errorMsg)
+        query := `INSERT INTO broken_links (id, url_id, run_id, link_url, status_code, error_message) 
+                          VALUES (?, ?, ?, ?, ?, ?)`
+        _, err := db.Exec(query, id, urlID, runID, linkURL, statusCode, errorMsg)
         return err
 }
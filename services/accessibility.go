@@ -0,0 +1,172 @@
+package services
+
+import (
+        "fmt"
+        "strings"
+
+        "github.com/PuerkitoBio/goquery"
+        "web-crawler/models"
+)
+
+// Accessibility rule ids. They follow the axe-core naming so findings can be
+// looked up in its documentation.
+const (
+        ruleImageAlt     = "image-alt"
+        ruleLabel        = "label"
+        ruleHeadingOrder = "heading-order"
+        ruleHTMLLang     = "html-has-lang"
+        ruleLinkName     = "link-name"
+        ruleButtonName   = "button-name"
+        ruleDuplicateID  = "duplicate-id"
+        ruleTableHeaders = "table-headers"
+)
+
+const (
+        severityError   = "error"
+        severityWarning = "warning"
+)
+
+// auditAccessibility checks the static HTML for WCAG problems that can be
+// detected without rendering the page.
+func (c *Crawler) auditAccessibility(doc *goquery.Document) []models.AccessibilityFinding {
+        var findings []models.AccessibilityFinding
+        ids := documentIDs(doc)
+        add := func(rule, severity string, s *goquery.Selection, message string) {
+                findings = append(findings, models.AccessibilityFinding{
+                        RuleID:   rule,
+                        Severity: severity,
+                        Selector: cssPath(s, ids),
+                        Message:  message,
+                })
+        }
+
+        // <html lang>
+        htmlEl := doc.Find("html").First()
+        if strings.TrimSpace(htmlEl.AttrOr("lang", "")) == "" {
+                add(ruleHTMLLang, severityError, htmlEl, "The <html> element has no lang attribute")
+        }
+
+        // Images without alternative text; alt="" is valid for decorative images
+        doc.Find("img, input[type='image'], area[href]").Each(func(i int, s *goquery.Selection) {
+                if _, ok := s.Attr("alt"); ok || hasAriaName(s) {
+                        return
+                }
+                if s.AttrOr("role", "") == "presentation" || s.AttrOr("role", "") == "none" {
+                        return
+                }
+                add(ruleImageAlt, severityError, s, fmt.Sprintf("<%s> has no alt attribute", goquery.NodeName(s)))
+        })
+
+        // Form controls without an accessible label
+        doc.Find("input, select, textarea").Each(func(i int, s *goquery.Selection) {
+                switch strings.ToLower(s.AttrOr("type", "")) {
+                case "hidden", "submit", "reset", "button", "image":
+                        return
+                }
+                if hasAriaName(s) || s.AttrOr("title", "") != "" || s.Closest("label").Length() > 0 {
+                        return
+                }
+                if id := s.AttrOr("id", ""); id != "" && labelFor(doc, id) {
+                        return
+                }
+                add(ruleLabel, severityError, s, fmt.Sprintf("<%s> has no associated label", goquery.NodeName(s)))
+        })
+
+        // Links and buttons must have discernible text
+        doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
+                if !hasAccessibleName(s) {
+                        add(ruleLinkName, severityError, s, "Link has no discernible text")
+                }
+        })
+        doc.Find("button, input[type='button'], [role='button']").Each(func(i int, s *goquery.Selection) {
+                if goquery.NodeName(s) == "input" {
+                        if strings.TrimSpace(s.AttrOr("value", "")) != "" || hasAriaName(s) {
+                                return
+                        }
+                } else if hasAccessibleName(s) {
+                        return
+                }
+                add(ruleButtonName, severityError, s, "Button has no discernible text")
+        })
+
+        // Heading levels should only increase one step at a time
+        previous := 0
+        doc.Find("h1, h2, h3, h4, h5, h6").Each(func(i int, s *goquery.Selection) {
+                level := int(goquery.NodeName(s)[1] - '0')
+                if previous > 0 && level > previous+1 {
+                        add(ruleHeadingOrder, severityWarning, s,
+                                fmt.Sprintf("Heading level skipped from h%d to h%d", previous, level))
+                }
+                previous = level
+        })
+
+        // Duplicate ids break label and ARIA references
+        seen := make(map[string]bool)
+        doc.Find("[id]").Each(func(i int, s *goquery.Selection) {
+                id := s.AttrOr("id", "")
+                if id == "" {
+                        return
+                }
+                if seen[id] {
+                        add(ruleDuplicateID, severityError, s, fmt.Sprintf("Duplicate id %q", id))
+                }
+                seen[id] = true
+        })
+
+        // Data tables need header cells
+        doc.Find("table").Each(func(i int, s *goquery.Selection) {
+                role := s.AttrOr("role", "")
+                if role == "presentation" || role == "none" {
+                        return
+                }
+                if s.Find("th, [scope], [role='columnheader'], [role='rowheader']").Length() == 0 {
+                        add(ruleTableHeaders, severityWarning, s, "Table has no header cells")
+                }
+        })
+
+        return findings
+}
+
+func hasAriaName(s *goquery.Selection) bool {
+        return strings.TrimSpace(s.AttrOr("aria-label", "")) != "" ||
+                strings.TrimSpace(s.AttrOr("aria-labelledby", "")) != ""
+}
+
+func labelFor(doc *goquery.Document, id string) bool {
+        found := false
+        doc.Find("label[for]").EachWithBreak(func(i int, l *goquery.Selection) bool {
+                found = l.AttrOr("for", "") == id
+                return !found
+        })
+        return found
+}
+
+// hasAccessibleName approximates whether the element has an accessible name
+// from its ARIA attributes, text content, title or contained image alt text.
+func hasAccessibleName(s *goquery.Selection) bool {
+        if hasAriaName(s) || collapseWhitespace(s.Text()) != "" {
+                return true
+        }
+        if strings.TrimSpace(s.AttrOr("title", "")) != "" {
+                return true
+        }
+
+        found := false
+        s.Find("img[alt], [aria-label]").EachWithBreak(func(i int, child *goquery.Selection) bool {
+                found = strings.TrimSpace(child.AttrOr("alt", child.AttrOr("aria-label", ""))) != ""
+                return !found
+        })
+        return found
+}
+
+func countAccessibilityFindings(findings []models.AccessibilityFinding) (int, int) {
+        errors, warnings := 0, 0
+        for _, f := range findings {
+                if f.Severity == severityError {
+                        errors++
+                } else {
+                        warnings++
+                }
+        }
+        return errors, warnings
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+        "fmt"
+        "regexp"
+        "strconv"
+        "strings"
+
+        "github.com/PuerkitoBio/goquery"
+        "github.com/andybalholm/cascadia"
+        "web-crawler/models"
+)
+
+// Assertion types supported on tracked pages
+const (
+        assertContainsText    = "contains_text"
+        assertNotContainsText = "not_contains_text"
+        assertSelectorExists  = "selector_exists"
+        assertTitleMatches    = "title_matches"
+        assertStatusEquals    = "status_equals"
+)
+
+// Assertion states reported on the URL
+const (
+        assertionStatusPassed = "passed"
+        assertionStatusFailed = "failed"
+)
+
+// ValidateAssertion checks that an assertion type is known and its value can
+// be evaluated.
+func ValidateAssertion(assertionType, value string) error {
+        if value == "" {
+                return fmt.Errorf("value is required")
+        }
+
+        switch assertionType {
+        case assertContainsText, assertNotContainsText:
+                return nil
+        case assertSelectorExists:
+                if _, err := cascadia.Compile(value); err != nil {
+                        return fmt.Errorf("invalid selector: %v", err)
+                }
+                return nil
+        case assertTitleMatches:
+                if _, err := regexp.Compile(value); err != nil {
+                        return fmt.Errorf("invalid regular expression: %v", err)
+                }
+                return nil
+        case assertStatusEquals:
+                if code, err := strconv.Atoi(value); err != nil || code < 100 || code > 599 {
+                        return fmt.Errorf("invalid status code %q", value)
+                }
+                return nil
+        }
+
+        return fmt.Errorf("unknown assertion type %q", assertionType)
+}
+
+// evaluateAssertions checks every assertion against the crawled page. Text
+// assertions match against the page's visible text with whitespace
+// collapsed.
+func (c *Crawler) evaluateAssertions(assertions []models.Assertion, doc *goquery.Document, statusCode int) []models.AssertionResult {
+        text := collapseWhitespace(doc.Find("body").Text())
+        title := collapseWhitespace(doc.Find("title").First().Text())
+
+        results := make([]models.AssertionResult, 0, len(assertions))
+        for _, a := range assertions {
+                result := models.AssertionResult{AssertionID: a.ID, URLID: a.URLID}
+
+                switch a.Type {
+                case assertContainsText:
+                        result.Passed = strings.Contains(text, a.Value)
+                        result.Message = fmt.Sprintf("page does not contain %q", a.Value)
+                case assertNotContainsText:
+                        result.Passed = !strings.Contains(text, a.Value)
+                        result.Message = fmt.Sprintf("page contains %q", a.Value)
+                case assertSelectorExists:
+                        matcher, err := cascadia.Compile(a.Value)
+                        if err != nil {
+                                result.Message = fmt.Sprintf("invalid selector: %v", err)
+                                break
+                        }
+                        result.Passed = doc.FindMatcher(matcher).Length() > 0
+                        result.Message = fmt.Sprintf("no element matches %q", a.Value)
+                case assertTitleMatches:
+                        pattern, err := regexp.Compile(a.Value)
+                        if err != nil {
+                                result.Message = fmt.Sprintf("invalid regular expression: %v", err)
+                                break
+                        }
+                        result.Passed = pattern.MatchString(title)
+                        result.Message = fmt.Sprintf("title %q does not match %q", title, a.Value)
+                case assertStatusEquals:
+                        result.Passed = strconv.Itoa(statusCode) == a.Value
+                        result.Message = fmt.Sprintf("status is %d, expected %s", statusCode, a.Value)
+                default:
+                        result.Message = fmt.Sprintf("unknown assertion type %q", a.Type)
+                }
+
+                if result.Passed {
+                        result.Message = "passed"
+                }
+                results = append(results, result)
+        }
+
+        return results
+}
+
+// summarizeAssertions returns the URL's assertion state and the number of
+// failed assertions. The state is nil when the URL has no assertions.
+func summarizeAssertions(results []models.AssertionResult) (interface{}, int) {
+        if len(results) == 0 {
+                return nil, 0
+        }
+
+        failed := 0
+        for _, r := range results {
+                if !r.Passed {
+                        failed++
+                }
+        }
+        if failed > 0 {
+                return assertionStatusFailed, failed
+        }
+        return assertionStatusPassed, 0
+}
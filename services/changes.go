@@ -0,0 +1,259 @@
+package services
+
+import (
+        "math"
+        "net/url"
+        "sort"
+        "strings"
+
+        "github.com/PuerkitoBio/goquery"
+        "web-crawler/models"
+)
+
+// significantSimilarity is the text similarity, in percent, below which a
+// change to the body text counts as significant.
+const significantSimilarity = 90.0
+
+// maxDiffCells bounds the size of the paragraph LCS table; larger texts are
+// compared as fully replaced after their common prefix and suffix.
+const maxDiffCells = 4000000
+
+// trackedMeta lists the metadata compared between crawls.
+var trackedMeta = []struct {
+        name     string
+        selector string
+        attr     string
+}{
+        {"description", "meta[name='description' i]", "content"},
+        {"keywords", "meta[name='keywords' i]", "content"},
+        {"robots", "meta[name='robots' i]", "content"},
+        {"canonical", "link[rel~='canonical']", "href"},
+        {"og:title", "meta[property='og:title']", "content"},
+        {"og:description", "meta[property='og:description']", "content"},
+        {"og:image", "meta[property='og:image']", "content"},
+}
+
+// significantMeta is the metadata whose change alone is significant.
+var significantMeta = map[string]bool{"description": true, "robots": true, "canonical": true}
+
+// capturePageState records the parts of a page compared between crawls.
+func (c *Crawler) capturePageState(doc *goquery.Document, content models.PageContent, base *url.URL) models.PageState {
+        state := models.PageState{
+                Title:    collapseWhitespace(doc.Find("title").First().Text()),
+                Headings: []string{},
+                Links:    []string{},
+                Meta:     make(map[string]string),
+                Text:     content.Text,
+        }
+
+        doc.Find("h1, h2, h3, h4, h5, h6").Each(func(i int, s *goquery.Selection) {
+                if text := collapseWhitespace(s.Text()); text != "" {
+                        state.Headings = append(state.Headings, goquery.NodeName(s)+": "+text)
+                }
+        })
+
+        seen := make(map[string]bool)
+        doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
+                link, err := resolveLink(base, s.AttrOr("href", ""))
+                if err != nil || (link.Scheme != "http" && link.Scheme != "https") {
+                        return
+                }
+                link.Fragment = ""
+                if key := link.String(); !seen[key] {
+                        seen[key] = true
+                        state.Links = append(state.Links, key)
+                }
+        })
+        sort.Strings(state.Links)
+
+        for _, meta := range trackedMeta {
+                if value := strings.TrimSpace(doc.Find(meta.selector).First().AttrOr(meta.attr, "")); value != "" {
+                        state.Meta[meta.name] = value
+                }
+        }
+
+        return state
+}
+
+// DiffPageStates compares two crawls of a page. A change is significant when
+// the title, headings or key metadata changed, or the body text similarity
+// fell below significantSimilarity.
+func DiffPageStates(old, new models.PageState) models.ContentDiff {
+        diff := models.ContentDiff{
+                MetaChanges: []models.MetaChange{},
+        }
+
+        if old.Title != new.Title {
+                diff.TitleChanged = true
+                diff.OldTitle = old.Title
+                diff.NewTitle = new.Title
+        }
+
+        diff.HeadingsAdded, diff.HeadingsRemoved = diffLists(old.Headings, new.Headings)
+        diff.LinksAdded, diff.LinksRemoved = diffLists(old.Links, new.Links)
+
+        names := make([]string, 0, len(old.Meta)+len(new.Meta))
+        for name := range old.Meta {
+                names = append(names, name)
+        }
+        for name := range new.Meta {
+                if _, ok := old.Meta[name]; !ok {
+                        names = append(names, name)
+                }
+        }
+        sort.Strings(names)
+        metaSignificant := false
+        for _, name := range names {
+                if old.Meta[name] != new.Meta[name] {
+                        diff.MetaChanges = append(diff.MetaChanges, models.MetaChange{Name: name, Old: old.Meta[name], New: new.Meta[name]})
+                        metaSignificant = metaSignificant || significantMeta[name]
+                }
+        }
+
+        diff.TextChanges, diff.Similarity = diffText(old.Text, new.Text)
+
+        diff.Significant = diff.TitleChanged || metaSignificant ||
+                len(diff.HeadingsAdded) > 0 || len(diff.HeadingsRemoved) > 0 ||
+                diff.Similarity < significantSimilarity
+        return diff
+}
+
+// diffLists returns the entries only in new and only in old, counting
+// repeated entries separately.
+func diffLists(old, new []string) ([]string, []string) {
+        counts := make(map[string]int)
+        for _, item := range old {
+                counts[item]++
+        }
+
+        added := []string{}
+        for _, item := range new {
+                if counts[item] > 0 {
+                        counts[item]--
+                } else {
+                        added = append(added, item)
+                }
+        }
+
+        removed := []string{}
+        for _, item := range old {
+                if counts[item] > 0 {
+                        counts[item]--
+                        removed = append(removed, item)
+                }
+        }
+
+        return added, removed
+}
+
+// diffText compares normalized body text paragraph by paragraph and returns
+// the added and removed paragraphs in document order, plus the percentage of
+// words the two versions have in common.
+func diffText(old, new string) ([]models.TextChange, float64) {
+        a := splitParagraphs(old)
+        b := splitParagraphs(new)
+
+        // Skip the common prefix and suffix before building the LCS table
+        prefix := 0
+        for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+                prefix++
+        }
+        suffix := 0
+        for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+                suffix++
+        }
+
+        changes := []models.TextChange{}
+        midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+        if len(midA)*len(midB) > maxDiffCells {
+                for _, p := range midA {
+                        changes = append(changes, models.TextChange{Op: "removed", Text: p})
+                }
+                for _, p := range midB {
+                        changes = append(changes, models.TextChange{Op: "added", Text: p})
+                }
+        } else {
+                changes = lcsDiff(midA, midB)
+        }
+
+        return changes, wordSimilarity(old, new)
+}
+
+// wordSimilarity returns the Dice coefficient of the two texts' words, in
+// percent: how many words they share, ignoring order.
+func wordSimilarity(old, new string) float64 {
+        counts := make(map[string]int)
+        oldWords := textWords(strings.ToLower(old))
+        for _, word := range oldWords {
+                counts[word]++
+        }
+
+        newWords := textWords(strings.ToLower(new))
+        shared := 0
+        for _, word := range newWords {
+                if counts[word] > 0 {
+                        counts[word]--
+                        shared++
+                }
+        }
+
+        total := len(oldWords) + len(newWords)
+        if total == 0 {
+                return 100
+        }
+        return math.Round(float64(2*shared)/float64(total)*1000) / 10
+}
+
+// lcsDiff diffs two paragraph lists by longest common subsequence.
+func lcsDiff(a, b []string) []models.TextChange {
+        n, m := len(a), len(b)
+        table := make([][]int32, n+1)
+        for i := range table {
+                table[i] = make([]int32, m+1)
+        }
+        for i := n - 1; i >= 0; i-- {
+                for j := m - 1; j >= 0; j-- {
+                        if a[i] == b[j] {
+                                table[i][j] = table[i+1][j+1] + 1
+                        } else if table[i+1][j] >= table[i][j+1] {
+                                table[i][j] = table[i+1][j]
+                        } else {
+                                table[i][j] = table[i][j+1]
+                        }
+                }
+        }
+
+        changes := []models.TextChange{}
+        i, j := 0, 0
+        for i < n && j < m {
+                switch {
+                case a[i] == b[j]:
+                        i++
+                        j++
+                case table[i+1][j] >= table[i][j+1]:
+                        changes = append(changes, models.TextChange{Op: "removed", Text: a[i]})
+                        i++
+                default:
+                        changes = append(changes, models.TextChange{Op: "added", Text: b[j]})
+                        j++
+                }
+        }
+        for ; i < n; i++ {
+                changes = append(changes, models.TextChange{Op: "removed", Text: a[i]})
+        }
+        for ; j < m; j++ {
+                changes = append(changes, models.TextChange{Op: "added", Text: b[j]})
+        }
+
+        return changes
+}
+
+func splitParagraphs(text string) []string {
+        var paragraphs []string
+        for _, p := range strings.Split(text, "\n\n") {
+                if p = collapseWhitespace(p); p != "" {
+                        paragraphs = append(paragraphs, p)
+                }
+        }
+        return paragraphs
+}
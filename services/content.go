@@ -0,0 +1,317 @@
+package services
+
+import (
+        "math"
+        "regexp"
+        "strconv"
+        "strings"
+        "unicode"
+
+        "github.com/PuerkitoBio/goquery"
+        "golang.org/x/net/html"
+        "web-crawler/models"
+)
+
+// wordsPerMinute is the reading speed used for the reading time estimate.
+const wordsPerMinute = 200
+
+// boilerplateSelector matches page chrome that is not part of the main
+// content: navigation, headers and footers, sidebars and non-text elements.
+const boilerplateSelector = `script, style, noscript, template, svg, canvas, iframe, form, button, nav, footer, aside,
+        [role='navigation'], [role='banner'], [role='contentinfo'], [role='complementary'], [role='search'],
+        [aria-hidden='true'], [hidden]`
+
+// contentContainer matches the elements whose own header introduces the
+// content rather than the site.
+const contentContainer = `article, main, [role='main']`
+
+// boilerplateName matches ids and classes commonly used for page chrome.
+var boilerplateName = regexp.MustCompile(`(?i)(^|[-_ ])(nav|navbar|menu|footer|sidebar|breadcrumbs?|cookie|consent|banner|share|social|related|newsletter|popup|modal|advert|ads?)([-_ ]|$)`)
+
+// extractMainContent strips boilerplate from a copy of the document and
+// returns the main text as plain text and Markdown with text statistics.
+func (c *Crawler) extractMainContent(doc *goquery.Document) models.PageContent {
+        // Work on a copy so the other extractors still see the full page
+        clone := goquery.NewDocumentFromNode(doc.Selection.Clone().Get(0))
+        clone.Find(boilerplateSelector).Remove()
+        clone.Find("header").Each(func(i int, s *goquery.Selection) {
+                if s.ParentsFiltered(contentContainer).Length() == 0 {
+                        s.Remove()
+                }
+        })
+
+        // Class and id names are only a hint: the content root, the elements
+        // containing it and elements holding most of its text are kept
+        root := mainContentRoot(clone)
+        keep := make(map[*html.Node]bool)
+        for _, node := range root.AddSelection(root.Parents()).Nodes {
+                keep[node] = true
+        }
+        rootLength := len(collapseWhitespace(root.Text()))
+        clone.Find("[id], [class]").Each(func(i int, s *goquery.Selection) {
+                if keep[s.Get(0)] {
+                        return
+                }
+                if !boilerplateName.MatchString(s.AttrOr("id", "")) && !boilerplateName.MatchString(s.AttrOr("class", "")) {
+                        return
+                }
+                if len(collapseWhitespace(s.Text()))*2 > rootLength {
+                        return
+                }
+                s.Remove()
+        })
+
+        var text, markdown strings.Builder
+        for _, node := range root.Nodes {
+                renderText(&text, node)
+                renderMarkdown(&markdown, node)
+        }
+
+        content := models.PageContent{
+                Text:     normalizeBlocks(text.String()),
+                Markdown: normalizeBlocks(markdown.String()),
+        }
+
+        words := textWords(content.Text)
+        content.WordCount = len(words)
+        content.ReadingTimeSeconds = int(math.Ceil(float64(content.WordCount) / wordsPerMinute * 60))
+        if language := detectLanguage(content.Text, words); language != "" {
+                content.Language = &language
+        }
+        if content.WordCount > 0 {
+                ease, grade := fleschScores(content.Text, words)
+                content.FleschReadingEase = &ease
+                content.FleschKincaidGrade = &grade
+        }
+
+        return content
+}
+
+// mainContentRoot picks the element holding the main content: an explicit
+// <main> landmark, else the <article> with the most text, else <body>.
+func mainContentRoot(doc *goquery.Document) *goquery.Selection {
+        if main := doc.Find("main, [role='main']").First(); main.Length() > 0 {
+                return main
+        }
+
+        var best *goquery.Selection
+        bestLength := 0
+        doc.Find("article").Each(func(i int, s *goquery.Selection) {
+                if length := len(collapseWhitespace(s.Text())); length > bestLength {
+                        best, bestLength = s, length
+                }
+        })
+        if best != nil {
+                return best
+        }
+
+        return doc.Find("body").First()
+}
+
+var blockElements = map[string]bool{
+        "p": true, "div": true, "section": true, "article": true, "main": true, "li": true, "ul": true, "ol": true,
+        "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "blockquote": true, "pre": true,
+        "table": true, "tr": true, "dl": true, "dt": true, "dd": true, "figure": true, "figcaption": true, "br": true,
+        "hr": true,
+}
+
+// renderText writes the text of a node, starting a new line at every block
+// element.
+func renderText(b *strings.Builder, node *html.Node) {
+        switch node.Type {
+        case html.TextNode:
+                b.WriteString(node.Data)
+                return
+        case html.ElementNode, html.DocumentNode:
+        default:
+                return
+        }
+
+        block := blockElements[node.Data]
+        if block {
+                b.WriteString("\n\n")
+        }
+        for child := node.FirstChild; child != nil; child = child.NextSibling {
+                renderText(b, child)
+        }
+        if node.Data == "td" || node.Data == "th" {
+                b.WriteString(" ")
+        }
+        if block {
+                b.WriteString("\n\n")
+        }
+}
+
+// renderMarkdown writes a Markdown rendering of a node. It covers headings,
+// paragraphs, lists, links, emphasis, quotes and preformatted text.
+func renderMarkdown(b *strings.Builder, node *html.Node) {
+        switch node.Type {
+        case html.TextNode:
+                b.WriteString(node.Data)
+                return
+        case html.ElementNode, html.DocumentNode:
+        default:
+                return
+        }
+
+        children := func() {
+                for child := node.FirstChild; child != nil; child = child.NextSibling {
+                        renderMarkdown(b, child)
+                }
+        }
+        inline := func(node *html.Node) string {
+                var inner strings.Builder
+                for child := node.FirstChild; child != nil; child = child.NextSibling {
+                        renderMarkdown(&inner, child)
+                }
+                return collapseWhitespace(inner.String())
+        }
+
+        switch node.Data {
+        case "h1", "h2", "h3", "h4", "h5", "h6":
+                level := int(node.Data[1] - '0')
+                b.WriteString("\n\n" + strings.Repeat("#", level) + " " + inline(node) + "\n\n")
+        case "p", "div", "section", "article", "main", "figure", "figcaption", "table", "tr", "dl", "dd", "dt":
+                b.WriteString("\n\n")
+                children()
+                b.WriteString("\n\n")
+        case "br":
+                b.WriteString("\n")
+        case "hr":
+                b.WriteString("\n\n---\n\n")
+        case "ul", "ol":
+                b.WriteString("\n\n")
+                index := 0
+                for child := node.FirstChild; child != nil; child = child.NextSibling {
+                        if child.Type != html.ElementNode || child.Data != "li" {
+                                continue
+                        }
+                        index++
+                        marker := "- "
+                        if node.Data == "ol" {
+                                marker = strconv.Itoa(index) + ". "
+                        }
+                        b.WriteString("\n" + marker + inline(child))
+                }
+                b.WriteString("\n\n")
+        case "blockquote":
+                b.WriteString("\n\n> " + inline(node) + "\n\n")
+        case "pre":
+                var code strings.Builder
+                renderText(&code, node)
+                b.WriteString("\n\n```\n" + strings.Trim(code.String(), "\n") + "\n```\n\n")
+        case "a":
+                text := inline(node)
+                href := nodeAttr(node, "href")
+                if href == "" || text == "" || strings.HasPrefix(href, "javascript:") {
+                        b.WriteString(text)
+                } else {
+                        b.WriteString("[" + text + "](" + href + ")")
+                }
+        case "strong", "b":
+                if text := inline(node); text != "" {
+                        b.WriteString("**" + text + "**")
+                }
+        case "em", "i":
+                if text := inline(node); text != "" {
+                        b.WriteString("_" + text + "_")
+                }
+        case "code":
+                if text := inline(node); text != "" {
+                        b.WriteString("`" + text + "`")
+                }
+        case "img":
+                if alt := nodeAttr(node, "alt"); alt != "" {
+                        b.WriteString("![" + alt + "](" + nodeAttr(node, "src") + ")")
+                }
+        case "td", "th":
+                children()
+                b.WriteString(" ")
+        default:
+                children()
+        }
+}
+
+// normalizeBlocks collapses whitespace inside each line and separates lines
+// by a single blank line. Lines of preformatted text are kept intact.
+func normalizeBlocks(text string) string {
+        var blocks []string
+        inCode := false
+        var code []string
+
+        for _, line := range strings.Split(text, "\n") {
+                if strings.HasPrefix(strings.TrimSpace(line), "```") {
+                        if inCode {
+                                code = append(code, "```")
+                                blocks = append(blocks, strings.Join(code, "\n"))
+                                code = nil
+                        } else {
+                                code = []string{"```"}
+                        }
+                        inCode = !inCode
+                        continue
+                }
+                if inCode {
+                        code = append(code, line)
+                        continue
+                }
+
+                if trimmed := collapseWhitespace(line); trimmed != "" {
+                        blocks = append(blocks, trimmed)
+                }
+        }
+
+        return strings.Join(blocks, "\n\n")
+}
+
+var wordPattern = regexp.MustCompile(`[\p{L}\p{N}][\p{L}\p{N}'’-]*`)
+
+func textWords(text string) []string {
+        return wordPattern.FindAllString(text, -1)
+}
+
+var sentenceEnd = regexp.MustCompile(`[.!?。！？]+(\s|$)`)
+
+// fleschScores returns the Flesch reading ease and Flesch-Kincaid grade
+// level. Syllables are estimated from vowel groups, which is accurate enough
+// for English and gives comparable numbers for other Latin-script languages.
+func fleschScores(text string, words []string) (float64, float64) {
+        sentences := len(sentenceEnd.FindAllStringIndex(text, -1))
+        if sentences == 0 {
+                sentences = 1
+        }
+
+        syllables := 0
+        for _, word := range words {
+                syllables += countSyllables(word)
+        }
+
+        wordsPerSentence := float64(len(words)) / float64(sentences)
+        syllablesPerWord := float64(syllables) / float64(len(words))
+
+        ease := 206.835 - 1.015*wordsPerSentence - 84.6*syllablesPerWord
+        grade := 0.39*wordsPerSentence + 11.8*syllablesPerWord - 15.59
+        return math.Round(ease*10) / 10, math.Round(grade*10) / 10
+}
+
+func countSyllables(word string) int {
+        word = strings.ToLower(word)
+        count := 0
+        previousVowel := false
+        for _, r := range word {
+                vowel := strings.ContainsRune("aeiouyàáâäæãåāèéêëēėęîïíīįìôöòóœøōõûüùúūÿ", r)
+                if vowel && !previousVowel {
+                        count++
+                }
+                previousVowel = vowel
+        }
+
+        // A trailing silent "e" does not form a syllable ("make"), unless "-le" ("table")
+        if strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "le") && count > 1 {
+                count--
+        }
+        if count == 0 && strings.IndexFunc(word, unicode.IsLetter) >= 0 {
+                count = 1
+        }
+        return count
+}
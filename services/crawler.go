@@ -1,10 +1,13 @@
 package services
 
 import (
+        "bytes"
         "database/sql"
         "fmt"
         "net/http"
         "net/url"
+        "os"
+        "strconv"
         "strings"
         "sync"
         "time"
@@ -14,74 +17,141 @@ import (
 )
 
 type Crawler struct {
-        db          *sql.DB
-        activeJobs  map[string]chan bool
-        jobsMutex   sync.RWMutex
-        httpClient  *http.Client
+        db                *sql.DB
+        activeJobs        map[string]chan bool
+        jobsMutex         sync.RWMutex
+        httpClient        *http.Client
+        snapshotRetention int
 }
 
+// defaultSnapshotRetention is the number of raw snapshots kept per URL when
+// neither SNAPSHOT_RETENTION nor a per-URL limit is set.
+const defaultSnapshotRetention = 10
+
 func NewCrawler(db *sql.DB) *Crawler {
+        snapshotRetention, err := strconv.Atoi(os.Getenv("SNAPSHOT_RETENTION"))
+        if err != nil || snapshotRetention <= 0 {
+                snapshotRetention = defaultSnapshotRetention
+        }
+
         return &Crawler{
                 db:         db,
                 activeJobs: make(map[string]chan bool),
                 httpClient: &http.Client{
-                        Timeout: 30 * time.Second,
+                        Timeout:   30 * time.Second,
+                        Transport: newCrawlerTransport(),
                 },
+                snapshotRetention: snapshotRetention,
         }
 }
 
 func (c *Crawler) CrawlURL(urlID string) {
-        // Set up cancellation channel
+        c.CrawlURLWithOptions(urlID, CrawlOptions{})
+}
+
+// CrawlURLWithOptions crawls a URL and returns when the crawl is done. It
+// does nothing if the URL is already being crawled.
+func (c *Crawler) CrawlURLWithOptions(urlID string, options CrawlOptions) {
+        stopChan, ok := c.reserveJob(urlID)
+        if !ok {
+                return
+        }
+        c.crawl(urlID, options, stopChan)
+}
+
+// TryStartCrawl starts a crawl of the URL in the background. It returns false
+// without starting one if the URL is already being crawled.
+func (c *Crawler) TryStartCrawl(urlID string, options CrawlOptions) bool {
+        stopChan, ok := c.reserveJob(urlID)
+        if !ok {
+                return false
+        }
+        go c.crawl(urlID, options, stopChan)
+        return true
+}
+
+// reserveJob registers the cancellation channel of a new crawl, unless the
+// URL already has one.
+func (c *Crawler) reserveJob(urlID string) (chan bool, bool) {
         c.jobsMutex.Lock()
+        defer c.jobsMutex.Unlock()
+
+        if _, exists := c.activeJobs[urlID]; exists {
+                return nil, false
+        }
         stopChan := make(chan bool, 1)
         c.activeJobs[urlID] = stopChan
-        c.jobsMutex.Unlock()
+        return stopChan, true
+}
 
-        // Clean up when done
+func (c *Crawler) crawl(urlID string, options CrawlOptions, stopChan chan bool) {
+        // Clean up when done. StopCrawl may already have removed this job and
+        // a new crawl registered its own channel, which must be kept.
         defer func() {
                 c.jobsMutex.Lock()
-                delete(c.activeJobs, urlID)
+                if c.activeJobs[urlID] == stopChan {
+                        delete(c.activeJobs, urlID)
+                }
                 c.jobsMutex.Unlock()
         }()
 
-        // Update status to crawling
-        c.updateStatus(urlID, "crawling")
-        
         // Get URL from database
         urlRecord, err := models.GetURLByID(c.db, urlID)
         if err != nil {
-                c.updateError(urlID, "Failed to get URL from database")
+                c.updateError(urlID, "", "Failed to get URL from database")
                 return
         }
 
+        // Update status to crawling
+        c.updateStatus(urlID, "crawling")
+
+        // Record the run so earlier results are kept
+        runID, err := models.StartCrawlRun(c.db, urlID)
+        if err != nil {
+                c.updateError(urlID, runID, "Failed to record crawl run")
+                return
+        }
+
+        // Apply the URL's DNS overrides and TLS options for this crawl
+        client, dialer := c.crawlClient(urlRecord)
+        if dialer != nil {
+                defer client.CloseIdleConnections()
+        }
+        if urlRecord.TLSInsecure {
+                if err := models.MarkCrawlRunInsecure(c.db, runID); err != nil {
+                        c.updateError(urlID, runID, fmt.Sprintf("Failed to flag crawl run: %v", err))
+                        return
+                }
+        }
+
         // Check if job was cancelled
         select {
         case <-stopChan:
-                c.updateStatus(urlID, "stopped")
+                c.stopRun(urlID, runID)
                 return
         default:
         }
 
         // Fetch the webpage
-        resp, err := c.httpClient.Get(urlRecord.URL)
+        page, err := c.fetchPage(client, urlRecord.URL)
         if err != nil {
-                c.updateError(urlID, fmt.Sprintf("Failed to fetch URL: %v", err))
+                c.updateError(urlID, runID, fmt.Sprintf("Failed to fetch URL: %v", err))
                 return
         }
-        defer resp.Body.Close()
+        resp := page.resp
 
         // Check if job was cancelled
         select {
         case <-stopChan:
-                c.updateStatus(urlID, "stopped")
+                c.stopRun(urlID, runID)
                 return
         default:
         }
 
         // Parse HTML
-        doc, err := goquery.NewDocumentFromReader(resp.Body)
+        doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page.body))
         if err != nil {
-                c.updateError(urlID, fmt.Sprintf("Failed to parse HTML: %v", err))
+                c.updateError(urlID, runID, fmt.Sprintf("Failed to parse HTML: %v", err))
                 return
         }
 
@@ -90,11 +160,93 @@ func (c *Crawler) CrawlURL(urlID string) {
 
         // Extract data
         data := c.extractData(doc, urlRecord.URL)
+        data["final_url"] = resp.Request.URL.String()
+
+        // Extract structured data
+        structuredData := c.extractStructuredData(doc)
+        data["structured_data_count"] = len(structuredData)
+        data["structured_data_errors"] = countStructuredDataErrors(structuredData)
+
+        // Audit accessibility
+        accessibilityFindings := c.auditAccessibility(doc)
+        data["accessibility_errors"], data["accessibility_warnings"] = countAccessibilityFindings(accessibilityFindings)
+
+        // Build heading outline
+        headings := c.extractHeadingOutline(doc)
+        data["heading_issues"] = countHeadingIssues(headings)
+
+        // Inventory forms
+        forms := c.inventoryForms(doc, resp.Request.URL)
+        data["form_count"] = len(forms)
+        data["insecure_forms"] = countInsecureForms(forms)
+
+        // Grade security headers of the main response
+        securityAudit := c.auditSecurityHeaders(resp.Header, resp.Request.URL)
+        data["security_score"] = securityAudit.Score
+
+        // Find mixed content and links downgrading to HTTP
+        mixedContent := c.findMixedContent(doc, resp.Request.URL)
+        data["mixed_content_active"], data["mixed_content_passive"], data["insecure_links"] = countMixedContent(mixedContent)
+
+        // Build the page's outgoing link graph
+        links := c.extractLinkGraph(doc, resp.Request.URL)
+
+        // Fingerprint technologies
+        technologies := c.fingerprintTechnologies(resp.Header, resp.Cookies(), doc)
+
+        // Extract main content text and readability
+        content := c.extractMainContent(doc)
+        data["word_count"] = content.WordCount
+        data["reading_time_seconds"] = content.ReadingTimeSeconds
+        data["language"] = content.Language
+        data["flesch_reading_ease"] = content.FleschReadingEase
+
+        // Compare with the previous crawl
+        pageState := c.capturePageState(doc, content, documentBase(doc, resp.Request.URL))
+        previousRunID, contentDiff, err := c.compareWithPreviousRun(urlID, runID, pageState)
+        if err != nil {
+                c.updateError(urlID, runID, fmt.Sprintf("Failed to compare with previous crawl: %v", err))
+                return
+        }
+        data["significant_change"] = contentDiff != nil && contentDiff.Significant
+        data["change_similarity"] = nil
+        if contentDiff != nil {
+                data["change_similarity"] = contentDiff.Similarity
+        }
+
+        // Evaluate the URL's assertions
+        assertions, err := models.GetAssertions(c.db, urlID)
+        if err != nil {
+                c.updateError(urlID, runID, fmt.Sprintf("Failed to load assertions: %v", err))
+                return
+        }
+        assertionResults := c.evaluateAssertions(assertions, doc, resp.StatusCode)
+        data["assertion_status"], data["assertions_failed"] = summarizeAssertions(assertionResults)
+
+        // Evaluate custom extraction rules
+        extractionRules, err := models.GetExtractionRuleCandidates(c.db, urlID)
+        if err != nil {
+                c.updateError(urlID, runID, fmt.Sprintf("Failed to load extraction rules: %v", err))
+                return
+        }
+        extractionRules = applicableRules(extractionRules, urlID, urlRecord.URL)
+        extractedValues, extracted := c.applyExtractionRules(extractionRules, doc, page.body)
+        data["extracted"] = nil
+        if len(extractionRules) > 0 {
+                data["extracted"] = extracted
+        }
+
+        // Measure page weight from subresources when asked
+        if options.Subresources {
+                count, size := c.measureSubresources(client, doc, page, stopChan)
+                page.metrics.SubresourceCount = &count
+                page.metrics.SubresourceBytes = &size
+        }
 
         // Check if job was cancelled
         select {
         case <-stopChan:
-                c.updateStatus(urlID, "stopped")
+                c.stopRun(urlID, runID)
                 return
         default:
         }
@@ -102,24 +254,138 @@ func (c *Crawler) CrawlURL(urlID string) {
         // Check if job was cancelled
         select {
         case <-stopChan:
-                c.updateStatus(urlID, "stopped")
+                c.stopRun(urlID, runID)
                 return
         default:
         }
 
         // Check for broken links (this takes time, so add cancellation check)
-        brokenLinks := c.checkBrokenLinks(doc, urlRecord.URL, stopChan)
+        brokenLinks := c.checkBrokenLinks(client, doc, urlRecord.URL, stopChan)
         data["broken_links"] = len(brokenLinks)
 
         // Store broken links
         for _, link := range brokenLinks {
-                models.CreateBrokenLink(c.db, urlID, link.URL, link.StatusCode, link.Error)
+                models.CreateBrokenLink(c.db, urlID, runID, link.URL, link.StatusCode, link.Error)
+        }
+
+        // Store structured data
+        if err := models.ReplaceStructuredData(c.db, urlID, structuredData); err != nil {
+                c.updateError(urlID, runID, fmt.Sprintf("Failed to store structured data: %v", err))
+                return
+        }
+
+        // Store accessibility findings
+        if err := models.ReplaceAccessibilityFindings(c.db, urlID, accessibilityFindings); err != nil {
+                c.updateError(urlID, runID, fmt.Sprintf("Failed to store accessibility findings: %v", err))
+                return
+        }
+
+        // Store heading outline
+        if err := models.CreateHeadings(c.db, urlID, runID, headings); err != nil {
+                c.updateError(urlID, runID, fmt.Sprintf("Failed to store headings: %v", err))
+                return
+        }
+
+        // Store form inventory
+        if err := models.ReplaceForms(c.db, urlID, forms); err != nil {
+                c.updateError(urlID, runID, fmt.Sprintf("Failed to store forms: %v", err))
+                return
+        }
+
+        // Store security header audit
+        if err := models.SaveSecurityAudit(c.db, urlID, securityAudit); err != nil {
+                c.updateError(urlID, runID, fmt.Sprintf("Failed to store security audit: %v", err))
+                return
+        }
+
+        // Store mixed content
+        if err := models.ReplaceMixedContent(c.db, urlID, mixedContent); err != nil {
+                c.updateError(urlID, runID, fmt.Sprintf("Failed to store mixed content: %v", err))
+                return
+        }
+
+        // Store the link graph
+        if err := models.ReplaceLinks(c.db, urlID, links); err != nil {
+                c.updateError(urlID, runID, fmt.Sprintf("Failed to store links: %v", err))
+                return
+        }
+
+        // Store detected technologies
+        if err := models.ReplaceTechnologies(c.db, urlID, technologies); err != nil {
+                c.updateError(urlID, runID, fmt.Sprintf("Failed to store technologies: %v", err))
+                return
+        }
+
+        // Store main content
+        if err := models.SavePageContent(c.db, urlID, content); err != nil {
+                c.updateError(urlID, runID, fmt.Sprintf("Failed to store page content: %v", err))
+                return
+        }
+
+        // Store the duplicate detection fingerprint
+        fingerprint := computeFingerprint(urlID, pageState.Title, pageState.Meta["description"], content.Text)
+        if err := models.SavePageFingerprint(c.db, fingerprint); err != nil {
+                c.updateError(urlID, runID, fmt.Sprintf("Failed to store page fingerprint: %v", err))
+                return
+        }
+
+        // Store the page state and the change since the previous crawl
+        if err := models.SaveRunPageState(c.db, runID, pageState); err != nil {
+                c.updateError(urlID, runID, fmt.Sprintf("Failed to store page state: %v", err))
+                return
+        }
+        if contentDiff != nil {
+                if err := models.CreateContentChange(c.db, urlID, runID, previousRunID, *contentDiff); err != nil {
+                        c.updateError(urlID, runID, fmt.Sprintf("Failed to store content change: %v", err))
+                        return
+                }
+        }
+
+        // Store assertion results
+        if err := models.CreateAssertionResults(c.db, urlID, runID, assertionResults); err != nil {
+                c.updateError(urlID, runID, fmt.Sprintf("Failed to store assertion results: %v", err))
+                return
+        }
+
+        // Store extracted values
+        if err := models.CreateExtractedValues(c.db, urlID, runID, extractedValues); err != nil {
+                c.updateError(urlID, runID, fmt.Sprintf("Failed to store extracted values: %v", err))
+                return
+        }
+
+        // Archive the raw response
+        if err := c.archiveSnapshot(urlRecord, page); err != nil {
+                c.updateError(urlID, runID, fmt.Sprintf("Failed to store snapshot: %v", err))
+                return
+        }
+
+        // Store fetch timing and page weight
+        if err := models.CreateFetchMetrics(c.db, urlID, runID, page.metrics); err != nil {
+                c.updateError(urlID, runID, fmt.Sprintf("Failed to store fetch metrics: %v", err))
+                return
+        }
+
+        // Record which DNS overrides the crawl connected through and whether
+        // certificates went unverified
+        if dialer != nil {
+                if used := dialer.Used(); len(used) > 0 {
+                        data["dns_overrides"] = used
+                }
+        }
+        if urlRecord.TLSInsecure {
+                data["tls_insecure"] = true
+        }
+
+        // Keep the extracted data with the run
+        if err := models.CompleteCrawlRun(c.db, runID, data); err != nil {
+                c.updateError(urlID, runID, fmt.Sprintf("Failed to complete crawl run: %v", err))
+                return
         }
 
         // Update database
         err = models.UpdateURLData(c.db, urlID, data)
         if err != nil {
-                c.updateError(urlID, fmt.Sprintf("Failed to update database: %v", err))
+                c.updateError(urlID, runID, fmt.Sprintf("Failed to update database: %v", err))
                 return
         }
         
@@ -127,6 +393,46 @@ func (c *Crawler) CrawlURL(urlID string) {
         c.updateStatus(urlID, "completed")
 }
 
+// compareWithPreviousRun diffs the page state against the last completed
+// run of the URL. The diff is nil on the first crawl.
+func (c *Crawler) compareWithPreviousRun(urlID, runID string, state models.PageState) (string, *models.ContentDiff, error) {
+        previousRunID, err := models.GetPreviousRunID(c.db, urlID, runID)
+        if err == sql.ErrNoRows {
+                return "", nil, nil
+        }
+        if err != nil {
+                return "", nil, err
+        }
+
+        previous, err := models.GetRunPageState(c.db, previousRunID)
+        if err != nil {
+                return "", nil, err
+        }
+
+        diff := DiffPageStates(*previous, state)
+        return previousRunID, &diff, nil
+}
+
+// archiveSnapshot stores the raw response of the crawl, keeping the URL's
+// own retention limit or the server default.
+func (c *Crawler) archiveSnapshot(urlRecord *models.URL, page *fetchedPage) error {
+        retention := c.snapshotRetention
+        if urlRecord.SnapshotRetention != nil && *urlRecord.SnapshotRetention > 0 {
+                retention = *urlRecord.SnapshotRetention
+        }
+
+        snapshot := models.Snapshot{
+                URLID:      urlRecord.ID,
+                TargetURL:  page.resp.Request.URL.String(),
+                Protocol:   page.resp.Proto,
+                StatusCode: page.resp.StatusCode,
+                Status:     page.resp.Status,
+                Headers:    formatSnapshotHeaders(page.resp.Header),
+                FetchedAt:  page.fetchedAt,
+        }
+        return models.CreateSnapshot(c.db, snapshot, page.body, retention)
+}
+
 func (c *Crawler) StopCrawl(urlID string) {
         c.jobsMutex.Lock()
         stopChan, exists := c.activeJobs[urlID]
@@ -171,7 +477,13 @@ func (c *Crawler) extractData(doc *goquery.Document, baseURL string) map[string]
         data["external_links"] = externalLinks
 
         // Check for login form
-        data["has_login_form"] = c.hasLoginForm(doc)
+        login := c.detectLoginForm(doc)
+        data["has_login_form"] = login.IsLogin()
+        data["login_form_confidence"] = login.Confidence
+        data["login_form_type"] = nil
+        if login.Type != "" {
+                data["login_form_type"] = login.Type
+        }
 
         return data
 }
@@ -233,33 +545,13 @@ func (c *Crawler) countLinks(doc *goquery.Document, baseURL string) (int, int) {
         return internal, external
 }
 
-func (c *Crawler) hasLoginForm(doc *goquery.Document) bool {
-        // Look for common login form patterns
-        loginPatterns := []string{
-                "input[type='password']",
-                "input[name*='password']",
-                "input[id*='password']",
-                "form[action*='login']",
-                "form[id*='login']",
-                "form[class*='login']",
-        }
-
-        for _, pattern := range loginPatterns {
-                if doc.Find(pattern).Length() > 0 {
-                        return true
-                }
-        }
-
-        return false
-}
-
 type BrokenLink struct {
         URL        string
         StatusCode int
         Error      string
 }
 
-func (c *Crawler) checkBrokenLinks(doc *goquery.Document, baseURL string, stopChan <-chan bool) []BrokenLink {
+func (c *Crawler) checkBrokenLinks(client *http.Client, doc *goquery.Document, baseURL string, stopChan <-chan bool) []BrokenLink {
         var brokenLinks []BrokenLink
         var wg sync.WaitGroup
         var mutex sync.Mutex
@@ -288,15 +580,15 @@ func (c *Crawler) checkBrokenLinks(doc *goquery.Document, baseURL string, stopCh
                 // Skip non-HTTP links
                 if !strings.HasPrefix(href, "http") && !strings.HasPrefix(href, "https") {
                         // Try to resolve relative URLs
-                        linkURL, err := url.Parse(href)
+                        baseURLParsed, err := url.Parse(baseURL)
                         if err != nil {
                                 return
                         }
-                        baseURLParsed, err := url.Parse(baseURL)
+                        linkURL, err := resolveLink(baseURLParsed, href)
                         if err != nil {
                                 return
                         }
-                        href = baseURLParsed.ResolveReference(linkURL).String()
+                        href = linkURL.String()
                 }
 
                 wg.Add(1)
@@ -320,7 +612,7 @@ func (c *Crawler) checkBrokenLinks(doc *goquery.Document, baseURL string, stopCh
                         default:
                         }
 
-                        resp, err := c.httpClient.Head(linkURL)
+                        resp, err := client.Head(linkURL)
                         if err != nil {
                                 mutex.Lock()
                                 brokenLinks = append(brokenLinks, BrokenLink{
@@ -349,12 +641,21 @@ func (c *Crawler) checkBrokenLinks(doc *goquery.Document, baseURL string, stopCh
         return brokenLinks
 }
 
-func (c *Crawler) updateError(urlID, errorMsg string) {
+func (c *Crawler) updateError(urlID, runID, errorMsg string) {
         query := `UPDATE urls SET status = 'error', error_message = ? WHERE id = ?`
         c.db.Exec(query, errorMsg, urlID)
+        if runID != "" {
+                models.FinishCrawlRun(c.db, runID, "error", &errorMsg)
+        }
 }
 
 func (c *Crawler) updateStatus(urlID, status string) {
         query := `UPDATE urls SET status = ?, last_crawled = CURRENT_TIMESTAMP WHERE id = ?`
         c.db.Exec(query, status, urlID)
 }
+
+// stopRun closes the run of a crawl that was stopped
+func (c *Crawler) stopRun(urlID, runID string) {
+        c.updateStatus(urlID, "stopped")
+        models.FinishCrawlRun(c.db, runID, "stopped", nil)
+}
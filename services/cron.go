@@ -0,0 +1,197 @@
+package services
+
+import (
+        "fmt"
+        "strconv"
+        "strings"
+        "time"
+)
+
+// cronExpression is a parsed five-field cron expression: minute, hour, day
+// of month, month and day of week. Each field is a bit set of allowed
+// values.
+type cronExpression struct {
+        minute, hour, dom, month, dow uint64
+
+        // Standard cron matches a day when either the day of month or the day
+        // of week matches, unless one of them is "*".
+        domRestricted, dowRestricted bool
+}
+
+var cronMacros = map[string]string{
+        "@yearly":   "0 0 1 1 *",
+        "@annually": "0 0 1 1 *",
+        "@monthly":  "0 0 1 * *",
+        "@weekly":   "0 0 * * 0",
+        "@daily":    "0 0 * * *",
+        "@midnight": "0 0 * * *",
+        "@hourly":   "0 * * * *",
+}
+
+var monthNames = map[string]int{
+        "jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+        "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var dayNames = map[string]int{
+        "sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// parseCron parses a cron expression such as "*/15 9-17 * * mon-fri" or one
+// of the @daily style macros.
+func parseCron(spec string) (*cronExpression, error) {
+        spec = strings.TrimSpace(spec)
+        if macro, ok := cronMacros[strings.ToLower(spec)]; ok {
+                spec = macro
+        }
+
+        fields := strings.Fields(spec)
+        if len(fields) != 5 {
+                return nil, fmt.Errorf("expected 5 fields, got %d", len(fields))
+        }
+
+        var expr cronExpression
+        var err error
+        if expr.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+                return nil, fmt.Errorf("minute: %v", err)
+        }
+        if expr.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+                return nil, fmt.Errorf("hour: %v", err)
+        }
+        if expr.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+                return nil, fmt.Errorf("day of month: %v", err)
+        }
+        if expr.month, err = parseCronField(fields[3], 1, 12, monthNames); err != nil {
+                return nil, fmt.Errorf("month: %v", err)
+        }
+        if expr.dow, err = parseCronField(fields[4], 0, 7, dayNames); err != nil {
+                return nil, fmt.Errorf("day of week: %v", err)
+        }
+
+        // 7 is an alias for Sunday
+        if expr.dow&(1<<7) != 0 {
+                expr.dow |= 1
+        }
+        expr.domRestricted = !strings.HasPrefix(fields[2], "*")
+        expr.dowRestricted = !strings.HasPrefix(fields[4], "*")
+
+        return &expr, nil
+}
+
+// parseCronField parses a comma-separated list of values, ranges and steps
+// ("1,15", "9-17", "*/5", "10-50/10") into a bit set.
+func parseCronField(field string, min, max int, names map[string]int) (uint64, error) {
+        var bits uint64
+        for _, part := range strings.Split(field, ",") {
+                rangePart, step := part, 1
+                if i := strings.Index(part, "/"); i >= 0 {
+                        n, err := strconv.Atoi(part[i+1:])
+                        if err != nil || n <= 0 {
+                                return 0, fmt.Errorf("invalid step %q", part[i+1:])
+                        }
+                        rangePart, step = part[:i], n
+                }
+
+                start, end := min, max
+                switch {
+                case rangePart == "*":
+                case strings.Contains(rangePart, "-"):
+                        bounds := strings.SplitN(rangePart, "-", 2)
+                        var err error
+                        if start, err = parseCronValue(bounds[0], min, max, names); err != nil {
+                                return 0, err
+                        }
+                        if end, err = parseCronValue(bounds[1], min, max, names); err != nil {
+                                return 0, err
+                        }
+                        if start > end {
+                                return 0, fmt.Errorf("invalid range %q", rangePart)
+                        }
+                default:
+                        value, err := parseCronValue(rangePart, min, max, names)
+                        if err != nil {
+                                return 0, err
+                        }
+                        start = value
+                        // "5/10" means every 10 starting at 5
+                        if step == 1 {
+                                end = value
+                        }
+                }
+
+                for v := start; v <= end; v += step {
+                        bits |= 1 << uint(v)
+                }
+        }
+        return bits, nil
+}
+
+func parseCronValue(value string, min, max int, names map[string]int) (int, error) {
+        if n, ok := names[strings.ToLower(value)]; ok {
+                return n, nil
+        }
+        n, err := strconv.Atoi(value)
+        if err != nil || n < min || n > max {
+                return 0, fmt.Errorf("value %q out of range %d-%d", value, min, max)
+        }
+        return n, nil
+}
+
+// next returns the first time after t that matches the expression in the
+// given location, or the zero time if there is none within five years.
+//
+// Fields are matched against wall-clock time, so a time skipped by a
+// daylight saving change runs at the equivalent instant after the change and
+// a repeated time runs only once.
+func (e *cronExpression) next(t time.Time, loc *time.Location) time.Time {
+        // Walk the calendar in UTC, which has no gaps or repeats, and map each
+        // match back to loc
+        local := t.In(loc)
+        w := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute()+1, 0, 0, time.UTC)
+        limit := w.Year() + 5
+
+        for w.Year() <= limit {
+                if e.month&(1<<uint(w.Month())) == 0 {
+                        w = time.Date(w.Year(), w.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+                        continue
+                }
+                if !e.matchesDay(w) {
+                        w = time.Date(w.Year(), w.Month(), w.Day()+1, 0, 0, 0, 0, time.UTC)
+                        continue
+                }
+                if e.hour&(1<<uint(w.Hour())) == 0 {
+                        w = w.Truncate(time.Hour).Add(time.Hour)
+                        continue
+                }
+                if e.minute&(1<<uint(w.Minute())) == 0 {
+                        w = w.Add(time.Minute)
+                        continue
+                }
+
+                run := time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute(), 0, 0, loc)
+                // A time in a daylight saving gap may be normalized to before
+                // the gap; move it past the gap instead
+                if wall := time.Date(run.Year(), run.Month(), run.Day(), run.Hour(), run.Minute(), 0, 0, time.UTC); wall.Before(w) {
+                        run = run.Add(w.Sub(wall))
+                }
+                if run.After(t) {
+                        return run
+                }
+                w = w.Add(time.Minute)
+        }
+        return time.Time{}
+}
+
+func (e *cronExpression) matchesDay(t time.Time) bool {
+        dom := e.dom&(1<<uint(t.Day())) != 0
+        dow := e.dow&(1<<uint(t.Weekday())) != 0
+        switch {
+        case e.domRestricted && e.dowRestricted:
+                return dom || dow
+        case e.domRestricted:
+                return dom
+        case e.dowRestricted:
+                return dow
+        }
+        return true
+}
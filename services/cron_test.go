@@ -0,0 +1,151 @@
+package services
+
+import (
+        "testing"
+        "time"
+)
+
+func TestParseCron(t *testing.T) {
+        valid := []string{
+                "* * * * *",
+                "*/15 9-17 * * mon-fri",
+                "0 0 1,15 * *",
+                "10-50/10 * * jan-mar 7",
+                "5/10 * * * *",
+                "@daily",
+                "@HOURLY",
+        }
+        for _, spec := range valid {
+                if _, err := parseCron(spec); err != nil {
+                        t.Errorf("parseCron(%q) returned error: %v", spec, err)
+                }
+        }
+
+        invalid := []string{
+                "",
+                "* * * *",
+                "* * * * * *",
+                "60 * * * *",
+                "* 24 * * *",
+                "* * 0 * *",
+                "* * * 13 *",
+                "* * * * 8",
+                "*/0 * * * *",
+                "10-5 * * * *",
+                "* * * foo *",
+                "@weekdays",
+        }
+        for _, spec := range invalid {
+                if _, err := parseCron(spec); err == nil {
+                        t.Errorf("parseCron(%q) returned no error", spec)
+                }
+        }
+}
+
+func TestParseCronFields(t *testing.T) {
+        expr, err := parseCron("*/20 9-11 * * sun")
+        if err != nil {
+                t.Fatal(err)
+        }
+        if want := uint64(1<<0 | 1<<20 | 1<<40); expr.minute != want {
+                t.Errorf("minute = %b, want %b", expr.minute, want)
+        }
+        if want := uint64(1<<9 | 1<<10 | 1<<11); expr.hour != want {
+                t.Errorf("hour = %b, want %b", expr.hour, want)
+        }
+        if expr.domRestricted || !expr.dowRestricted {
+                t.Errorf("restricted = %v/%v, want false/true", expr.domRestricted, expr.dowRestricted)
+        }
+
+        // 7 is Sunday
+        seven, err := parseCron("0 0 * * 7")
+        if err != nil {
+                t.Fatal(err)
+        }
+        if seven.dow&1 == 0 {
+                t.Errorf("day of week 7 does not match Sunday")
+        }
+}
+
+func TestCronNext(t *testing.T) {
+        newYork, err := time.LoadLocation("America/New_York")
+        if err != nil {
+                t.Skipf("time zone data unavailable: %v", err)
+        }
+
+        tests := []struct {
+                name string
+                spec string
+                loc  *time.Location
+                from string
+                want string
+        }{
+                {"next minute", "* * * * *", time.UTC,
+                        "2024-05-01T10:00:30Z", "2024-05-01T10:01:00Z"},
+                {"strictly after", "0 * * * *", time.UTC,
+                        "2024-05-01T10:00:00Z", "2024-05-01T11:00:00Z"},
+                {"step", "*/15 * * * *", time.UTC,
+                        "2024-05-01T10:16:00Z", "2024-05-01T10:30:00Z"},
+                {"rolls over the year", "0 0 1 1 *", time.UTC,
+                        "2024-05-01T10:00:00Z", "2025-01-01T00:00:00Z"},
+                {"leap day", "0 0 29 2 *", time.UTC,
+                        "2024-03-01T00:00:00Z", "2028-02-29T00:00:00Z"},
+                {"never", "0 0 31 2 *", time.UTC,
+                        "2024-01-01T00:00:00Z", ""},
+                {"time zone", "0 9 * * *", newYork,
+                        "2024-05-01T14:00:00Z", "2024-05-02T13:00:00Z"},
+
+                // With both day fields restricted, either one matching is enough.
+                // 2024-05-03 is a Friday.
+                {"day of month or week: week", "0 0 13 * fri", time.UTC,
+                        "2024-05-01T00:00:00Z", "2024-05-03T00:00:00Z"},
+                {"day of month or week: month", "0 0 2 * fri", time.UTC,
+                        "2024-05-01T00:00:00Z", "2024-05-02T00:00:00Z"},
+                // With one of them "*", only the other one counts
+                {"day of week only", "0 0 * * fri", time.UTC,
+                        "2024-05-01T00:00:00Z", "2024-05-03T00:00:00Z"},
+                {"day of month only", "0 0 13 * *", time.UTC,
+                        "2024-05-01T00:00:00Z", "2024-05-13T00:00:00Z"},
+                {"stepped day of month is restricted", "0 0 */10 * fri", time.UTC,
+                        "2024-05-01T00:00:00Z", "2024-05-03T00:00:00Z"},
+
+                // 2024-03-10 02:00 EST skips to 03:00 EDT
+                {"skipped time runs after the change", "30 2 * * *", newYork,
+                        "2024-03-10T05:00:00Z", "2024-03-10T07:30:00Z"},
+                {"hour after the gap", "0 3 * * *", newYork,
+                        "2024-03-10T05:00:00Z", "2024-03-10T07:00:00Z"},
+                // 2024-11-03 02:00 EDT falls back to 01:00 EST
+                {"repeated time runs once", "30 1 * * *", newYork,
+                        "2024-11-03T05:30:00Z", "2024-11-04T06:30:00Z"},
+                {"inside the repeated hour", "*/15 * * * *", newYork,
+                        "2024-11-03T06:20:00Z", "2024-11-03T07:00:00Z"},
+        }
+
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        expr, err := parseCron(tt.spec)
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        from, err := time.Parse(time.RFC3339, tt.from)
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+
+                        got := expr.next(from, tt.loc)
+                        if tt.want == "" {
+                                if !got.IsZero() {
+                                        t.Errorf("next = %v, want zero time", got)
+                                }
+                                return
+                        }
+                        want, err := time.Parse(time.RFC3339, tt.want)
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        if !got.Equal(want) {
+                                t.Errorf("next = %v, want %v", got.UTC(), want)
+                        }
+                })
+        }
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+        "context"
+        "fmt"
+        "log"
+        "net"
+        "net/http"
+        "os"
+        "sort"
+        "strconv"
+        "strings"
+        "sync"
+
+        "golang.org/x/net/idna"
+        "web-crawler/models"
+)
+
+// ParseDNSOverrides parses a comma-separated list of overrides in curl's
+// --resolve format, HOST:PORT:ADDRESS, where PORT may be * for every port
+// and IPv6 addresses may be enclosed in brackets.
+func ParseDNSOverrides(spec string) ([]models.DNSOverride, error) {
+        var overrides []models.DNSOverride
+        for _, entry := range strings.Split(spec, ",") {
+                entry = strings.TrimSpace(entry)
+                if entry == "" {
+                        continue
+                }
+
+                parts := strings.SplitN(entry, ":", 3)
+                if len(parts) != 3 {
+                        return nil, fmt.Errorf("DNS override %q must be HOST:PORT:ADDRESS", entry)
+                }
+
+                override := models.DNSOverride{
+                        Host: parts[0],
+                        IP:   strings.TrimSuffix(strings.TrimPrefix(parts[2], "["), "]"),
+                }
+                if parts[1] != "*" {
+                        port, err := strconv.Atoi(parts[1])
+                        if err != nil {
+                                return nil, fmt.Errorf("DNS override %q has an invalid port", entry)
+                        }
+                        override.Port = port
+                }
+
+                if err := ValidateDNSOverride(&override); err != nil {
+                        return nil, err
+                }
+                overrides = append(overrides, override)
+        }
+
+        return overrides, nil
+}
+
+// ValidateDNSOverride checks an override and normalizes its host and
+// address. The address must be one the crawler may connect to for the
+// host, so overrides cannot be used to reach internal networks outside
+// CRAWL_ALLOWLIST.
+func ValidateDNSOverride(override *models.DNSOverride) error {
+        host, err := idna.Lookup.ToASCII(strings.TrimSuffix(strings.ToLower(strings.TrimSpace(override.Host)), "."))
+        if err != nil || host == "" {
+                return fmt.Errorf("DNS override host %q is invalid", override.Host)
+        }
+        override.Host = host
+
+        if override.Port < 0 || override.Port > 65535 {
+                return fmt.Errorf("DNS override port %d is out of range", override.Port)
+        }
+
+        ip := net.ParseIP(strings.TrimSpace(override.IP))
+        if ip == nil {
+                return fmt.Errorf("DNS override address %q is not an IP address", override.IP)
+        }
+        override.IP = ip.String()
+
+        policy := DefaultTargetPolicy()
+        if !policy.allowsHost(host) {
+                if err := policy.CheckIP(ip); err != nil {
+                        return fmt.Errorf("DNS override for %s: %v", host, err)
+                }
+        }
+
+        return nil
+}
+
+var (
+        globalDNSOverrides     []models.DNSOverride
+        globalDNSOverridesOnce sync.Once
+)
+
+// GlobalDNSOverrides returns the overrides configured through the
+// DNS_OVERRIDES environment variable. Invalid entries are logged and the
+// whole setting is ignored.
+func GlobalDNSOverrides() []models.DNSOverride {
+        globalDNSOverridesOnce.Do(func() {
+                overrides, err := ParseDNSOverrides(os.Getenv("DNS_OVERRIDES"))
+                if err != nil {
+                        log.Printf("Ignoring DNS_OVERRIDES: %v", err)
+                        return
+                }
+                globalDNSOverrides = overrides
+        })
+        return globalDNSOverrides
+}
+
+// overrideDialer dials pinned addresses for overridden hosts and records
+// which overrides were used. Per-URL overrides take precedence over global
+// ones and an override for a specific port over one for every port.
+type overrideDialer struct {
+        policy    *TargetPolicy
+        dialer    *net.Dialer
+        overrides []models.DNSOverride
+
+        mutex sync.Mutex
+        used  map[models.DNSOverride]bool
+}
+
+func newOverrideDialer(policy *TargetPolicy, urlOverrides, globalOverrides []models.DNSOverride) *overrideDialer {
+        // Per-URL overrides come first, then within each set the ones for a
+        // specific port; match takes the first override that applies
+        var overrides []models.DNSOverride
+        for _, set := range [][]models.DNSOverride{urlOverrides, globalOverrides} {
+                start := len(overrides)
+                overrides = append(overrides, set...)
+                group := overrides[start:]
+                sort.SliceStable(group, func(i, j int) bool {
+                        return group[i].Port != 0 && group[j].Port == 0
+                })
+        }
+
+        return &overrideDialer{
+                policy:    policy,
+                dialer:    newDialer(),
+                overrides: overrides,
+                used:      make(map[models.DNSOverride]bool),
+        }
+}
+
+func (d *overrideDialer) match(host string, port int) (models.DNSOverride, bool) {
+        host = strings.TrimSuffix(strings.ToLower(host), ".")
+        for _, o := range d.overrides {
+                if o.Host == host && (o.Port == 0 || o.Port == port) {
+                        return o, true
+                }
+        }
+        return models.DNSOverride{}, false
+}
+
+func (d *overrideDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+        host, portString, err := net.SplitHostPort(address)
+        if err != nil {
+                return nil, err
+        }
+        port, _ := strconv.Atoi(portString)
+
+        override, ok := d.match(host, port)
+        if !ok {
+                return d.policy.DialContext(d.dialer)(ctx, network, address)
+        }
+
+        d.mutex.Lock()
+        d.used[override] = true
+        d.mutex.Unlock()
+
+        // The original host stays in the URL, so the Host header and TLS
+        // SNI are unchanged; only the connection goes to the pinned address.
+        pinned := net.JoinHostPort(override.IP, portString)
+        if d.policy.allowsHost(host) {
+                return d.dialer.DialContext(ctx, network, pinned)
+        }
+        return d.policy.guard(d.dialer).DialContext(ctx, network, pinned)
+}
+
+// Used returns the overrides that were applied to connections so far.
+func (d *overrideDialer) Used() []models.DNSOverride {
+        d.mutex.Lock()
+        defer d.mutex.Unlock()
+
+        used := make([]models.DNSOverride, 0, len(d.used))
+        for o := range d.used {
+                used = append(used, o)
+        }
+        sort.Slice(used, func(i, j int) bool {
+                if used[i].Host != used[j].Host {
+                        return used[i].Host < used[j].Host
+                }
+                return used[i].Port < used[j].Port
+        })
+        return used
+}
+
+// newCrawlerTransport returns the transport shared by requests made
+// outside a crawl, applying the global DNS overrides when there are any.
+func newCrawlerTransport() http.RoundTripper {
+        policy := DefaultTargetPolicy()
+        if global := GlobalDNSOverrides(); len(global) > 0 {
+                return newTransport(newOverrideDialer(policy, nil, global).DialContext, false)
+        }
+        return newTransport(policy.DialContext(newDialer()), false)
+}
+
+// crawlClient returns the HTTP client for one crawl of a URL. URLs with DNS
+// overrides, global or their own, or that accept invalid certificates get
+// a client with a transport of their own, so pooled connections to pinned
+// addresses or to unverified servers are never reused by other crawls. The
+// returned dialer records which overrides were used and is nil when the
+// shared client is returned.
+func (c *Crawler) crawlClient(urlRecord *models.URL) (*http.Client, *overrideDialer) {
+        overrides := len(urlRecord.DNSOverrides) > 0 || len(GlobalDNSOverrides()) > 0
+        if !overrides && !urlRecord.TLSInsecure {
+                return c.httpClient, nil
+        }
+
+        dialer := newOverrideDialer(DefaultTargetPolicy(), urlRecord.DNSOverrides, GlobalDNSOverrides())
+        transport := newTransport(dialer.DialContext, urlRecord.TLSInsecure)
+        return &http.Client{Timeout: c.httpClient.Timeout, Transport: transport}, dialer
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+        "testing"
+
+        "web-crawler/models"
+)
+
+func TestOverrideDialerPrecedence(t *testing.T) {
+        urlOverrides := []models.DNSOverride{
+                {Host: "example.com", Port: 0, IP: "203.0.113.1"},
+                {Host: "api.example.com", Port: 8443, IP: "203.0.113.2"},
+        }
+        globalOverrides := []models.DNSOverride{
+                {Host: "example.com", Port: 443, IP: "198.51.100.1"},
+                {Host: "api.example.com", Port: 0, IP: "198.51.100.2"},
+                {Host: "cdn.example.com", Port: 0, IP: "198.51.100.3"},
+                {Host: "cdn.example.com", Port: 443, IP: "198.51.100.4"},
+        }
+        d := newOverrideDialer(NewTargetPolicy(), urlOverrides, globalOverrides)
+
+        tests := []struct {
+                host   string
+                port   int
+                wantIP string
+        }{
+                // A per-URL override for every port beats a global one for the port
+                {"example.com", 443, "203.0.113.1"},
+                {"Example.com.", 80, "203.0.113.1"},
+                {"api.example.com", 8443, "203.0.113.2"},
+                {"api.example.com", 443, "198.51.100.2"},
+                // Within one set a specific port beats every port
+                {"cdn.example.com", 443, "198.51.100.4"},
+                {"cdn.example.com", 80, "198.51.100.3"},
+                {"other.example.com", 443, ""},
+        }
+
+        for _, tt := range tests {
+                o, _ := d.match(tt.host, tt.port)
+                if o.IP != tt.wantIP {
+                        t.Errorf("match(%s, %d) = %q, want %q", tt.host, tt.port, o.IP, tt.wantIP)
+                }
+        }
+}
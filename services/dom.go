@@ -0,0 +1,99 @@
+package services
+
+import (
+        "fmt"
+        "net/url"
+        "strings"
+
+        "github.com/PuerkitoBio/goquery"
+        "golang.org/x/net/html"
+)
+
+// cssPath builds a CSS selector that identifies the element within the
+// document, anchored at the closest ancestor with a unique id where possible.
+// ids holds the id counts of the document from documentIDs.
+func cssPath(s *goquery.Selection, ids map[string]int) string {
+        if s.Length() == 0 {
+                return ""
+        }
+
+        var parts []string
+        for node := s.Get(0); node != nil && node.Type == html.ElementNode; node = node.Parent {
+                id := nodeAttr(node, "id")
+                if id != "" && !strings.ContainsAny(id, " \t\n") && ids[id] == 1 {
+                        parts = append(parts, "#"+id)
+                        break
+                }
+
+                part := node.Data
+                if index, total := nodeTypeIndex(node); total > 1 {
+                        part = fmt.Sprintf("%s:nth-of-type(%d)", node.Data, index)
+                }
+                parts = append(parts, part)
+        }
+
+        // Reverse so the path reads from the root down
+        for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+                parts[i], parts[j] = parts[j], parts[i]
+        }
+        return strings.Join(parts, " > ")
+}
+
+// nodeTypeIndex returns the 1-based position of the node among its siblings
+// with the same tag name and the number of such siblings.
+func nodeTypeIndex(node *html.Node) (int, int) {
+        if node.Parent == nil {
+                return 1, 1
+        }
+
+        index, total := 0, 0
+        for sibling := node.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+                if sibling.Type != html.ElementNode || sibling.Data != node.Data {
+                        continue
+                }
+                total++
+                if sibling == node {
+                        index = total
+                }
+        }
+        return index, total
+}
+
+// documentIDs counts the elements carrying each id in the document.
+func documentIDs(doc *goquery.Document) map[string]int {
+        ids := make(map[string]int)
+        doc.Find("[id]").Each(func(i int, s *goquery.Selection) {
+                ids[s.AttrOr("id", "")]++
+        })
+        return ids
+}
+
+func nodeAttr(node *html.Node, name string) string {
+        for _, attr := range node.Attr {
+                if attr.Key == name {
+                        return attr.Val
+                }
+        }
+        return ""
+}
+
+// collapseWhitespace trims the text and folds runs of whitespace into a
+// single space.
+func collapseWhitespace(text string) string {
+        return strings.Join(strings.Fields(text), " ")
+}
+
+// documentBase returns the URL relative references in the document resolve
+// against: the first <base href>, itself resolved against the page URL, or
+// the page URL when there is none.
+func documentBase(doc *goquery.Document, pageURL *url.URL) *url.URL {
+        href, ok := doc.Find("base[href]").First().Attr("href")
+        if !ok {
+                return pageURL
+        }
+        base, err := resolveLink(pageURL, strings.TrimSpace(href))
+        if err != nil || (base.Scheme != "http" && base.Scheme != "https") {
+                return pageURL
+        }
+        return base
+}
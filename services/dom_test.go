@@ -0,0 +1,40 @@
+package services
+
+import (
+        "strings"
+        "testing"
+
+        "github.com/PuerkitoBio/goquery"
+)
+
+func TestCSSPath(t *testing.T) {
+        page := `<html><body>
+<div id="main"><p>one</p><p id="dup">two</p></div>
+<div><span id="dup">three</span><span>four</span></div>
+</body></html>`
+        doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+        if err != nil {
+                t.Fatal(err)
+        }
+        ids := documentIDs(doc)
+        if ids["dup"] != 2 || ids["main"] != 1 {
+                t.Fatalf("documentIDs = %v", ids)
+        }
+
+        tests := []struct {
+                selector string
+                want     string
+        }{
+                // Anchored at the closest unique id
+                {"#main p:first-child", "#main > p:nth-of-type(1)"},
+                {"#main", "#main"},
+                // Duplicate ids are not used as anchors
+                {"span:contains(three)", "html > body > div:nth-of-type(2) > span:nth-of-type(1)"},
+                {"p:contains(two)", "#main > p:nth-of-type(2)"},
+        }
+        for _, tt := range tests {
+                if got := cssPath(doc.Find(tt.selector).First(), ids); got != tt.want {
+                        t.Errorf("cssPath(%s) = %q, want %q", tt.selector, got, tt.want)
+                }
+        }
+}
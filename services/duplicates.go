@@ -0,0 +1,262 @@
+package services
+
+import (
+        "crypto/sha256"
+        "database/sql"
+        "encoding/hex"
+        "fmt"
+        "hash/fnv"
+        "math"
+        "math/bits"
+        "sort"
+        "strings"
+
+        "web-crawler/models"
+)
+
+const (
+        // shingleSize is the number of consecutive words hashed together
+        // into one SimHash feature.
+        shingleSize = 3
+
+        // minSimHashWords is the number of words below which a page is only
+        // compared by exact content hash; SimHash of very short texts is
+        // too noisy to judge near-duplicates.
+        minSimHashWords = 20
+
+        // DefaultDuplicateThreshold is the SimHash similarity, in percent,
+        // from which two pages count as near-duplicates.
+        DefaultDuplicateThreshold = 90.0
+)
+
+// DuplicateTypes lists the kinds of duplicates FindDuplicates reports.
+var DuplicateTypes = []string{"title", "description", "content"}
+
+// computeFingerprint fingerprints a page's title, meta description and main
+// text for duplicate detection.
+func computeFingerprint(urlID, title, description, text string) models.PageFingerprint {
+        words := textWords(strings.ToLower(text))
+        sum := sha256.Sum256([]byte(strings.Join(words, " ")))
+
+        return models.PageFingerprint{
+                URLID:       urlID,
+                Title:       normalizeDuplicateText(title),
+                Description: normalizeDuplicateText(description),
+                ContentHash: hex.EncodeToString(sum[:]),
+                SimHash:     simHash(words),
+                WordCount:   len(words),
+        }
+}
+
+// normalizeDuplicateText lowercases text and collapses its whitespace so
+// titles and descriptions differing only in case or spacing match.
+func normalizeDuplicateText(text string) string {
+        return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+// simHash computes the 64-bit SimHash of the word shingles of a text.
+func simHash(words []string) uint64 {
+        if len(words) == 0 {
+                return 0
+        }
+
+        var weights [64]int
+        size := shingleSize
+        if len(words) < size {
+                size = len(words)
+        }
+        for i := 0; i+size <= len(words); i++ {
+                h := fnv.New64a()
+                h.Write([]byte(strings.Join(words[i:i+size], " ")))
+                sum := h.Sum64()
+                for bit := 0; bit < 64; bit++ {
+                        if sum&(1<<uint(bit)) != 0 {
+                                weights[bit]++
+                        } else {
+                                weights[bit]--
+                        }
+                }
+        }
+
+        var hash uint64
+        for bit, weight := range weights {
+                if weight > 0 {
+                        hash |= 1 << uint(bit)
+                }
+        }
+        return hash
+}
+
+// simHashSimilarity returns the share of equal bits of two SimHashes in
+// percent.
+func simHashSimilarity(a, b uint64) float64 {
+        return math.Round(float64(64-bits.OnesCount64(a^b))/64*10000) / 100
+}
+
+// FindDuplicates groups the tracked pages by duplicate title, duplicate
+// meta description and exact or near-duplicate body content. Pages whose
+// content SimHash similarity reaches the threshold are clustered together,
+// transitively. An empty kind reports every type.
+func FindDuplicates(db *sql.DB, kind string, threshold float64) ([]models.DuplicateCluster, error) {
+        fingerprints, err := models.GetPageFingerprints(db)
+        if err != nil {
+                return nil, err
+        }
+
+        clusters := []models.DuplicateCluster{}
+        if kind == "" || kind == "title" {
+                clusters = append(clusters, exactDuplicates("title", fingerprints, func(fp models.PageFingerprint) string {
+                        return fp.Title
+                })...)
+        }
+        if kind == "" || kind == "description" {
+                clusters = append(clusters, exactDuplicates("description", fingerprints, func(fp models.PageFingerprint) string {
+                        return fp.Description
+                })...)
+        }
+        if kind == "" || kind == "content" {
+                clusters = append(clusters, contentDuplicates(fingerprints, threshold)...)
+        }
+
+        return clusters, nil
+}
+
+// exactDuplicates groups pages sharing the same non-empty value.
+func exactDuplicates(kind string, fingerprints []models.PageFingerprint, value func(models.PageFingerprint) string) []models.DuplicateCluster {
+        groups := make(map[string][]models.DuplicatePage)
+        var values []string
+        for _, fp := range fingerprints {
+                v := value(fp)
+                if v == "" {
+                        continue
+                }
+                if _, ok := groups[v]; !ok {
+                        values = append(values, v)
+                }
+                groups[v] = append(groups[v], models.DuplicatePage{URLID: fp.URLID, URL: fp.URL})
+        }
+
+        clusters := []models.DuplicateCluster{}
+        for _, v := range values {
+                if len(groups[v]) > 1 {
+                        clusters = append(clusters, models.DuplicateCluster{
+                                Type:       kind,
+                                Value:      v,
+                                Exact:      true,
+                                Similarity: 100,
+                                Pages:      groups[v],
+                        })
+                }
+        }
+
+        sortClusters(clusters)
+        return clusters
+}
+
+// contentDuplicates clusters pages with identical content hashes or SimHash
+// similarity at or above the threshold.
+func contentDuplicates(fingerprints []models.PageFingerprint, threshold float64) []models.DuplicateCluster {
+        var pages []models.PageFingerprint
+        for _, fp := range fingerprints {
+                if fp.WordCount > 0 {
+                        pages = append(pages, fp)
+                }
+        }
+
+        parent := make([]int, len(pages))
+        for i := range parent {
+                parent[i] = i
+        }
+        var find func(int) int
+        find = func(i int) int {
+                if parent[i] != i {
+                        parent[i] = find(parent[i])
+                }
+                return parent[i]
+        }
+
+        // Lowest similarity of the pairs joined into each cluster
+        lowest := make(map[int]float64)
+        join := func(i, j int, similarity float64) {
+                ri, rj := find(i), find(j)
+                low := similarity
+                if s, ok := lowest[ri]; ok && s < low {
+                        low = s
+                }
+                if s, ok := lowest[rj]; ok && s < low {
+                        low = s
+                }
+                if ri != rj {
+                        parent[rj] = ri
+                        delete(lowest, rj)
+                }
+                lowest[ri] = low
+        }
+
+        for i := range pages {
+                for j := i + 1; j < len(pages); j++ {
+                        if pages[i].ContentHash == pages[j].ContentHash {
+                                join(i, j, 100)
+                                continue
+                        }
+                        if pages[i].WordCount < minSimHashWords || pages[j].WordCount < minSimHashWords {
+                                continue
+                        }
+                        if similarity := simHashSimilarity(pages[i].SimHash, pages[j].SimHash); similarity >= threshold {
+                                join(i, j, similarity)
+                        }
+                }
+        }
+
+        groups := make(map[int][]int)
+        var roots []int
+        for i := range pages {
+                root := find(i)
+                if _, ok := groups[root]; !ok {
+                        roots = append(roots, root)
+                }
+                groups[root] = append(groups[root], i)
+        }
+
+        clusters := []models.DuplicateCluster{}
+        for _, root := range roots {
+                members := groups[root]
+                if len(members) < 2 {
+                        continue
+                }
+
+                cluster := models.DuplicateCluster{
+                        Type:       "content",
+                        Exact:      true,
+                        Similarity: lowest[root],
+                }
+                for _, i := range members {
+                        cluster.Exact = cluster.Exact && pages[i].ContentHash == pages[members[0]].ContentHash
+                        cluster.Pages = append(cluster.Pages, models.DuplicatePage{URLID: pages[i].URLID, URL: pages[i].URL})
+                }
+                clusters = append(clusters, cluster)
+        }
+
+        sortClusters(clusters)
+        return clusters
+}
+
+// sortClusters orders clusters by size, largest first.
+func sortClusters(clusters []models.DuplicateCluster) {
+        sort.SliceStable(clusters, func(i, j int) bool {
+                return len(clusters[i].Pages) > len(clusters[j].Pages)
+        })
+}
+
+// ValidateDuplicateType checks a duplicate type requested through the API.
+func ValidateDuplicateType(kind string) error {
+        if kind == "" {
+                return nil
+        }
+        for _, t := range DuplicateTypes {
+                if kind == t {
+                        return nil
+                }
+        }
+        return fmt.Errorf("type must be one of %s", strings.Join(DuplicateTypes, ", "))
+}
@@ -0,0 +1,370 @@
+package services
+
+import (
+        "archive/zip"
+        "bufio"
+        "encoding/csv"
+        "encoding/json"
+        "encoding/xml"
+        "fmt"
+        "io"
+        "reflect"
+        "strconv"
+        "strings"
+        "time"
+
+        "web-crawler/models"
+)
+
+// Export formats accepted by NewExportWriter.
+const (
+        ExportFormatCSV    = "csv"
+        ExportFormatNDJSON = "ndjson"
+        ExportFormatXLSX   = "xlsx"
+)
+
+var exportContentTypes = map[string]string{
+        ExportFormatCSV:    "text/csv; charset=utf-8",
+        ExportFormatNDJSON: "application/x-ndjson",
+        ExportFormatXLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// ExportContentType returns the MIME type of an export format, or an empty
+// string when the format is not supported.
+func ExportContentType(format string) string {
+        return exportContentTypes[format]
+}
+
+// ExportWriter writes a table row by row. Values are strings, numbers,
+// booleans, nil or, for nested values such as schedules and tags, any
+// value encodable as JSON.
+type ExportWriter interface {
+        WriteRow(values []interface{}) error
+        Close() error
+}
+
+// NewExportWriter starts an export in the given format and writes the
+// header. Rows are written to w as they come so large exports stream.
+func NewExportWriter(format string, w io.Writer, columns []string) (ExportWriter, error) {
+        switch format {
+        case ExportFormatCSV:
+                return newCSVExportWriter(w, columns)
+        case ExportFormatNDJSON:
+                return &ndjsonExportWriter{w: bufio.NewWriter(w), columns: columns}, nil
+        case ExportFormatXLSX:
+                return newXLSXExportWriter(w, columns)
+        default:
+                return nil, fmt.Errorf("format must be one of %s, %s, %s", ExportFormatCSV, ExportFormatNDJSON, ExportFormatXLSX)
+        }
+}
+
+// exportText renders a value as cell text for CSV and XLSX.
+func exportText(value interface{}) string {
+        switch v := value.(type) {
+        case nil:
+                return ""
+        case string:
+                return v
+        case bool:
+                return strconv.FormatBool(v)
+        case int64:
+                return strconv.FormatInt(v, 10)
+        case float64:
+                return strconv.FormatFloat(v, 'f', -1, 64)
+        case []string:
+                return strings.Join(v, "; ")
+        default:
+                encoded, err := json.Marshal(v)
+                if err != nil {
+                        return fmt.Sprint(v)
+                }
+                return string(encoded)
+        }
+}
+
+func isExportNumber(value interface{}) bool {
+        switch value.(type) {
+        case int64, float64:
+                return true
+        }
+        return false
+}
+
+type csvExportWriter struct {
+        w *csv.Writer
+}
+
+func newCSVExportWriter(w io.Writer, columns []string) (*csvExportWriter, error) {
+        writer := &csvExportWriter{w: csv.NewWriter(w)}
+        if err := writer.w.Write(columns); err != nil {
+                return nil, err
+        }
+        return writer, nil
+}
+
+func (e *csvExportWriter) WriteRow(values []interface{}) error {
+        record := make([]string, len(values))
+        for i, value := range values {
+                record[i] = exportText(value)
+                // Keep spreadsheets from evaluating crawled text as formulas.
+                // Only numbers may start with a sign.
+                if !isExportNumber(value) && record[i] != "" && strings.ContainsRune("=+-@\t\r", rune(record[i][0])) {
+                        record[i] = "'" + record[i]
+                }
+        }
+        return e.w.Write(record)
+}
+
+func (e *csvExportWriter) Close() error {
+        e.w.Flush()
+        return e.w.Error()
+}
+
+// ndjsonExportWriter writes one JSON object per row with the columns as
+// keys, in column order.
+type ndjsonExportWriter struct {
+        w       *bufio.Writer
+        columns []string
+}
+
+func (e *ndjsonExportWriter) WriteRow(values []interface{}) error {
+        e.w.WriteByte('{')
+        for i, value := range values {
+                if i > 0 {
+                        e.w.WriteByte(',')
+                }
+                key, _ := json.Marshal(e.columns[i])
+                encoded, err := json.Marshal(value)
+                if err != nil {
+                        return err
+                }
+                e.w.Write(key)
+                e.w.WriteByte(':')
+                e.w.Write(encoded)
+        }
+        e.w.WriteString("}\n")
+
+        // Flush regularly so the export streams instead of piling up
+        if e.w.Buffered() > 32<<10 {
+                return e.w.Flush()
+        }
+        return nil
+}
+
+func (e *ndjsonExportWriter) Close() error {
+        return e.w.Flush()
+}
+
+// maxXLSXCellText is the longest text a spreadsheet cell can hold.
+const maxXLSXCellText = 32767
+
+// xlsxExportWriter writes a single-sheet workbook. The fixed parts are
+// written first and the sheet is streamed last, one row at a time, into
+// the zip archive.
+type xlsxExportWriter struct {
+        zip   *zip.Writer
+        sheet *bufio.Writer
+        row   int
+}
+
+var xlsxParts = []struct {
+        name    string
+        content string
+}{
+        {"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+                `<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+                `<Default Extension="xml" ContentType="application/xml"/>` +
+                `<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+                `<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+                `</Types>`},
+        {"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+                `<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+                `</Relationships>`},
+        {"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+                `<sheets><sheet name="Export" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+        {"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+                `<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+                `</Relationships>`},
+}
+
+func newXLSXExportWriter(w io.Writer, columns []string) (*xlsxExportWriter, error) {
+        archive := zip.NewWriter(w)
+        for _, part := range xlsxParts {
+                f, err := archive.Create(part.name)
+                if err != nil {
+                        return nil, err
+                }
+                if _, err := io.WriteString(f, part.content); err != nil {
+                        return nil, err
+                }
+        }
+
+        f, err := archive.Create("xl/worksheets/sheet1.xml")
+        if err != nil {
+                return nil, err
+        }
+        writer := &xlsxExportWriter{zip: archive, sheet: bufio.NewWriter(f)}
+        writer.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+        header := make([]interface{}, len(columns))
+        for i, column := range columns {
+                header[i] = column
+        }
+        if err := writer.WriteRow(header); err != nil {
+                return nil, err
+        }
+        return writer, nil
+}
+
+func (e *xlsxExportWriter) WriteRow(values []interface{}) error {
+        e.row++
+        fmt.Fprintf(e.sheet, `<row r="%d">`, e.row)
+        for i, value := range values {
+                ref := xlsxColumn(i) + strconv.Itoa(e.row)
+                switch v := value.(type) {
+                case nil:
+                        continue
+                case int64, float64:
+                        fmt.Fprintf(e.sheet, `<c r="%s"><v>%s</v></c>`, ref, exportText(v))
+                case bool:
+                        b := 0
+                        if v {
+                                b = 1
+                        }
+                        fmt.Fprintf(e.sheet, `<c r="%s" t="b"><v>%d</v></c>`, ref, b)
+                default:
+                        text := exportText(v)
+                        if len(text) > maxXLSXCellText {
+                                text = strings.ToValidUTF8(text[:maxXLSXCellText], "")
+                        }
+                        fmt.Fprintf(e.sheet, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+                        xml.EscapeText(e.sheet, []byte(text))
+                        e.sheet.WriteString(`</t></is></c>`)
+                }
+        }
+        _, err := e.sheet.WriteString(`</row>`)
+        return err
+}
+
+func (e *xlsxExportWriter) Close() error {
+        e.sheet.WriteString(`</sheetData></worksheet>`)
+        if err := e.sheet.Flush(); err != nil {
+                return err
+        }
+        return e.zip.Close()
+}
+
+// xlsxColumn returns the spreadsheet column name of a zero-based index:
+// A, B, ..., Z, AA, AB and so on.
+func xlsxColumn(index int) string {
+        name := ""
+        for index >= 0 {
+                name = string(rune('A'+index%26)) + name
+                index = index/26 - 1
+        }
+        return name
+}
+
+// URLExportColumns lists the export columns of URLs: every field of the URL
+// record, then one extracted.<name> column per extraction rule.
+func URLExportColumns(ruleNames []string) []string {
+        var columns []string
+        urlType := reflect.TypeOf(models.URL{})
+        for i := 0; i < urlType.NumField(); i++ {
+                if name, ok := exportFieldName(urlType.Field(i)); ok {
+                        columns = append(columns, name)
+                }
+        }
+        for _, name := range ruleNames {
+                columns = append(columns, "extracted."+name)
+        }
+        return columns
+}
+
+// URLExportRow returns the values of a URL in URLExportColumns order.
+func URLExportRow(url *models.URL, ruleNames []string) []interface{} {
+        var values []interface{}
+        value := reflect.ValueOf(*url)
+        for i := 0; i < value.NumField(); i++ {
+                if _, ok := exportFieldName(value.Type().Field(i)); ok {
+                        values = append(values, exportValue(value.Field(i)))
+                }
+        }
+
+        extracted := map[string]interface{}{}
+        if len(url.Extracted) > 0 {
+                json.Unmarshal(url.Extracted, &extracted)
+        }
+        for _, name := range ruleNames {
+                values = append(values, exportScalar(extracted[name]))
+        }
+        return values
+}
+
+// exportFieldName returns the JSON name of an exported URL field. The
+// extracted values are exported as separate columns instead.
+func exportFieldName(field reflect.StructField) (string, bool) {
+        name := strings.Split(field.Tag.Get("json"), ",")[0]
+        if name == "" || name == "-" || name == "extracted" {
+                return "", false
+        }
+        return name, true
+}
+
+func exportValue(v reflect.Value) interface{} {
+        if v.Kind() == reflect.Ptr {
+                if v.IsNil() {
+                        return nil
+                }
+                v = v.Elem()
+        }
+
+        switch value := v.Interface().(type) {
+        case time.Time:
+                return value.UTC().Format(time.RFC3339)
+        case string, bool, []string:
+                return value
+        }
+
+        switch v.Kind() {
+        case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+                return v.Int()
+        case reflect.Float32, reflect.Float64:
+                return v.Float()
+        case reflect.Slice:
+                if v.IsNil() {
+                        return nil
+                }
+        }
+        return v.Interface()
+}
+
+// exportScalar converts a decoded JSON value to an export value.
+func exportScalar(value interface{}) interface{} {
+        if number, ok := value.(float64); ok && number == float64(int64(number)) {
+                return int64(number)
+        }
+        return value
+}
+
+// BrokenLinkExportColumns lists the export columns of broken links.
+var BrokenLinkExportColumns = []string{"page_url", "url_id", "run_id", "link_url", "status_code", "error_message", "created_at"}
+
+// BrokenLinkExportRow returns the values of a broken link in
+// BrokenLinkExportColumns order.
+func BrokenLinkExportRow(link models.BrokenLink, pageURL string) []interface{} {
+        var runID, errorMessage interface{}
+        if link.RunID != nil {
+                runID = *link.RunID
+        }
+        if link.ErrorMessage != nil {
+                errorMessage = *link.ErrorMessage
+        }
+        return []interface{}{pageURL, link.URLID, runID, link.LinkURL, int64(link.StatusCode), errorMessage,
+                link.CreatedAt.UTC().Format(time.RFC3339)}
+}
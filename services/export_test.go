@@ -0,0 +1,34 @@
+package services
+
+import (
+        "bytes"
+        "testing"
+)
+
+func TestCSVExportEscapesFormulas(t *testing.T) {
+        var out bytes.Buffer
+        writer, err := NewExportWriter(ExportFormatCSV, &out, []string{"title", "tags", "extra", "count", "score"})
+        if err != nil {
+                t.Fatal(err)
+        }
+
+        row := []interface{}{
+                "=1+1",
+                []string{"=HYPERLINK(\"http://evil.test\")", "safe"},
+                "@SUM(A1)",
+                int64(-3),
+                float64(-0.5),
+        }
+        if err := writer.WriteRow(row); err != nil {
+                t.Fatal(err)
+        }
+        if err := writer.Close(); err != nil {
+                t.Fatal(err)
+        }
+
+        want := "title,tags,extra,count,score\n" +
+                `'=1+1,"'=HYPERLINK(""http://evil.test""); safe",'@SUM(A1),-3,-0.5` + "\n"
+        if out.String() != want {
+                t.Errorf("CSV export =\n%s\nwant\n%s", out.String(), want)
+        }
+}
@@ -0,0 +1,306 @@
+package services
+
+import (
+        "encoding/json"
+        "fmt"
+        "math"
+        "regexp"
+        "strconv"
+        "strings"
+        "time"
+
+        "github.com/PuerkitoBio/goquery"
+        "github.com/andybalholm/cascadia"
+        "web-crawler/models"
+)
+
+// Extraction methods
+const (
+        extractCSS   = "css"
+        extractXPath = "xpath"
+        extractRegex = "regex"
+)
+
+// Value types extracted values are converted to
+const (
+        valueString  = "string"
+        valueNumber  = "number"
+        valueInteger = "integer"
+        valueBoolean = "boolean"
+        valueDate    = "date"
+)
+
+// ValidateExtractionRule checks a rule before it is stored and fills in the
+// default value type. Names must be unique among the existing rules of the
+// same URL or the same pattern, since values are reported by name.
+func ValidateExtractionRule(rule *models.ExtractionRule, existing []models.ExtractionRule) error {
+        if strings.TrimSpace(rule.Name) == "" {
+                return fmt.Errorf("name is required")
+        }
+        if (rule.URLID == nil) == (rule.URLPattern == nil) {
+                return fmt.Errorf("exactly one of url_id and url_pattern is required")
+        }
+        if rule.URLPattern != nil && *rule.URLPattern == "" {
+                return fmt.Errorf("url_pattern must not be empty")
+        }
+        for _, other := range existing {
+                if other.Name != rule.Name {
+                        continue
+                }
+                if rule.URLID != nil && other.URLID != nil && *other.URLID == *rule.URLID {
+                        return fmt.Errorf("a rule named %q already exists for this URL", rule.Name)
+                }
+                if rule.URLPattern != nil && other.URLPattern != nil && *other.URLPattern == *rule.URLPattern {
+                        return fmt.Errorf("a rule named %q already exists for this pattern", rule.Name)
+                }
+        }
+
+        switch rule.ValueType {
+        case "":
+                rule.ValueType = valueString
+        case valueString, valueNumber, valueInteger, valueBoolean, valueDate:
+        default:
+                return fmt.Errorf("unknown value_type %q", rule.ValueType)
+        }
+
+        switch rule.Method {
+        case extractCSS:
+                if _, err := cascadia.Compile(rule.Expression); err != nil {
+                        return fmt.Errorf("invalid selector: %v", err)
+                }
+        case extractXPath:
+                if _, err := compileXPath(rule.Expression); err != nil {
+                        return fmt.Errorf("invalid XPath expression: %v", err)
+                }
+        case extractRegex:
+                if _, err := regexp.Compile(rule.Expression); err != nil {
+                        return fmt.Errorf("invalid regular expression: %v", err)
+                }
+        default:
+                return fmt.Errorf("unknown method %q", rule.Method)
+        }
+
+        return nil
+}
+
+// applicableRules keeps the rules that apply to a URL, one per name. A rule
+// attached to the URL itself takes precedence over a pattern rule of the
+// same name, and of several matching patterns the oldest rule wins.
+func applicableRules(rules []models.ExtractionRule, urlID, pageURL string) []models.ExtractionRule {
+        taken := make(map[string]bool)
+        var result []models.ExtractionRule
+        for _, rule := range rules {
+                if rule.URLID != nil && *rule.URLID == urlID && !taken[rule.Name] {
+                        taken[rule.Name] = true
+                        result = append(result, rule)
+                }
+        }
+        for _, rule := range rules {
+                if rule.URLPattern != nil && !taken[rule.Name] && matchURLPattern(*rule.URLPattern, pageURL) {
+                        taken[rule.Name] = true
+                        result = append(result, rule)
+                }
+        }
+        return result
+}
+
+// matchURLPattern matches a URL against a glob where "*" stands for any run
+// of characters, e.g. "https://shop.example.com/products/*".
+func matchURLPattern(pattern, pageURL string) bool {
+        expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$"
+        matched, err := regexp.MatchString(expr, pageURL)
+        return err == nil && matched
+}
+
+// applyExtractionRules evaluates the rules against the page and returns the
+// value of each rule along with a name-to-value summary for the URL.
+func (c *Crawler) applyExtractionRules(rules []models.ExtractionRule, doc *goquery.Document, body []byte) ([]models.ExtractedValue, map[string]interface{}) {
+        values := make([]models.ExtractedValue, 0, len(rules))
+        summary := make(map[string]interface{}, len(rules))
+
+        for _, rule := range rules {
+                value := models.ExtractedValue{RuleID: rule.ID, Name: rule.Name, ValueType: rule.ValueType}
+
+                typed, err := extractRuleValue(rule, doc, body)
+                if err != nil {
+                        message := err.Error()
+                        value.Error = &message
+                        typed = nil
+                }
+
+                encoded, _ := json.Marshal(typed)
+                value.Value = encoded
+                summary[rule.Name] = typed
+                values = append(values, value)
+        }
+
+        return values, summary
+}
+
+// extractRuleValue returns the converted first match, or all matches when
+// the rule is multi-valued.
+func extractRuleValue(rule models.ExtractionRule, doc *goquery.Document, body []byte) (interface{}, error) {
+        raw, err := matchRule(rule, doc, body)
+        if err != nil {
+                return nil, err
+        }
+        if len(raw) == 0 {
+                return nil, fmt.Errorf("no match")
+        }
+
+        if !rule.Multiple {
+                return convertValue(raw[0], rule.ValueType)
+        }
+
+        converted := make([]interface{}, 0, len(raw))
+        for _, r := range raw {
+                v, err := convertValue(r, rule.ValueType)
+                if err != nil {
+                        return nil, err
+                }
+                converted = append(converted, v)
+        }
+        return converted, nil
+}
+
+func matchRule(rule models.ExtractionRule, doc *goquery.Document, body []byte) ([]string, error) {
+        var raw []string
+
+        switch rule.Method {
+        case extractCSS:
+                matcher, err := cascadia.Compile(rule.Expression)
+                if err != nil {
+                        return nil, err
+                }
+                doc.FindMatcher(matcher).Each(func(i int, s *goquery.Selection) {
+                        if rule.Attribute != "" {
+                                if value, ok := s.Attr(rule.Attribute); ok {
+                                        raw = append(raw, strings.TrimSpace(value))
+                                }
+                                return
+                        }
+                        raw = append(raw, collapseWhitespace(s.Text()))
+                })
+        case extractXPath:
+                expr, err := compileXPath(rule.Expression)
+                if err != nil {
+                        return nil, err
+                }
+                for _, value := range expr.evaluate(doc.Get(0)) {
+                        raw = append(raw, strings.TrimSpace(value))
+                }
+        case extractRegex:
+                // Regexes run on the HTML source; the "value" group or the
+                // first group is used when the pattern has one
+                pattern, err := regexp.Compile(rule.Expression)
+                if err != nil {
+                        return nil, err
+                }
+                group := 0
+                if i := pattern.SubexpIndex("value"); i > 0 {
+                        group = i
+                } else if pattern.NumSubexp() > 0 {
+                        group = 1
+                }
+                for _, match := range pattern.FindAllStringSubmatch(string(body), -1) {
+                        raw = append(raw, match[group])
+                }
+        default:
+                return nil, fmt.Errorf("unknown method %q", rule.Method)
+        }
+
+        return raw, nil
+}
+
+var dateLayouts = []string{
+        time.RFC3339,
+        "2006-01-02T15:04:05",
+        "2006-01-02 15:04:05",
+        "2006-01-02",
+        time.RFC1123,
+        time.RFC1123Z,
+        "January 2, 2006",
+        "Jan 2, 2006",
+        "2 January 2006",
+        "2 Jan 2006",
+}
+
+// convertValue converts a matched string to the rule's value type.
+func convertValue(raw, valueType string) (interface{}, error) {
+        raw = strings.TrimSpace(raw)
+
+        switch valueType {
+        case valueNumber, valueInteger:
+                n, err := parseNumber(raw)
+                if err != nil {
+                        return nil, err
+                }
+                if valueType == valueInteger {
+                        if n != math.Trunc(n) {
+                                return nil, fmt.Errorf("%q is not an integer", raw)
+                        }
+                        return int64(n), nil
+                }
+                return n, nil
+        case valueBoolean:
+                switch strings.ToLower(raw) {
+                case "true", "yes", "y", "1", "on":
+                        return true, nil
+                case "false", "no", "n", "0", "off":
+                        return false, nil
+                }
+                return nil, fmt.Errorf("%q is not a boolean", raw)
+        case valueDate:
+                for _, layout := range dateLayouts {
+                        if t, err := time.Parse(layout, raw); err == nil {
+                                // Layouts without a clock give a plain date
+                                if !strings.Contains(layout, "15") {
+                                        return t.Format("2006-01-02"), nil
+                                }
+                                return t.Format(time.RFC3339), nil
+                        }
+                }
+                return nil, fmt.Errorf("%q is not a recognized date", raw)
+        }
+
+        return raw, nil
+}
+
+var numberChars = regexp.MustCompile(`-?[0-9][0-9.,]*`)
+
+// parseNumber reads the first number in a string such as "$1,234.50" or
+// "1.234,50 €". When both separators appear, the last one is the decimal
+// point; a lone comma followed by exactly three digits is a thousands
+// separator.
+func parseNumber(raw string) (float64, error) {
+        match := numberChars.FindString(raw)
+        if match == "" {
+                return 0, fmt.Errorf("%q is not a number", raw)
+        }
+        match = strings.TrimRight(match, ".,")
+
+        lastDot, lastComma := strings.LastIndex(match, "."), strings.LastIndex(match, ",")
+        switch {
+        case lastDot >= 0 && lastComma >= 0:
+                if lastComma > lastDot {
+                        match = strings.ReplaceAll(match, ".", "")
+                        match = strings.Replace(match, ",", ".", 1)
+                } else {
+                        match = strings.ReplaceAll(match, ",", "")
+                }
+        case lastComma >= 0:
+                if strings.Count(match, ",") == 1 && len(match)-lastComma-1 != 3 {
+                        match = strings.Replace(match, ",", ".", 1)
+                } else {
+                        match = strings.ReplaceAll(match, ",", "")
+                }
+        case strings.Count(match, ".") > 1:
+                match = strings.ReplaceAll(match, ".", "")
+        }
+
+        n, err := strconv.ParseFloat(match, 64)
+        if err != nil {
+                return 0, fmt.Errorf("%q is not a number", raw)
+        }
+        return n, nil
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+        "testing"
+
+        "web-crawler/models"
+)
+
+func TestValidateExtractionRuleDuplicateNames(t *testing.T) {
+        urlID, otherID := "u1", "u2"
+        pattern, otherPattern := "https://example.com/*", "https://example.org/*"
+        existing := []models.ExtractionRule{
+                {Name: "price", URLID: &urlID, Method: extractCSS, Expression: ".price"},
+                {Name: "sku", URLPattern: &pattern, Method: extractCSS, Expression: ".sku"},
+        }
+
+        tests := []struct {
+                rule    models.ExtractionRule
+                wantErr bool
+        }{
+                {models.ExtractionRule{Name: "price", URLID: &urlID}, true},
+                {models.ExtractionRule{Name: "price", URLID: &otherID}, false},
+                {models.ExtractionRule{Name: "price", URLPattern: &pattern}, false},
+                {models.ExtractionRule{Name: "sku", URLPattern: &pattern}, true},
+                {models.ExtractionRule{Name: "sku", URLPattern: &otherPattern}, false},
+                {models.ExtractionRule{Name: "sku", URLID: &urlID}, false},
+        }
+
+        for _, tt := range tests {
+                rule := tt.rule
+                rule.Method, rule.Expression = extractCSS, "h1"
+                err := ValidateExtractionRule(&rule, existing)
+                if (err != nil) != tt.wantErr {
+                        t.Errorf("ValidateExtractionRule(%s) error = %v, want error %v", rule.Name, err, tt.wantErr)
+                }
+        }
+}
+
+func TestApplicableRulesOnePerName(t *testing.T) {
+        urlID := "u1"
+        first, second := "https://example.com/*", "https://example.com/products/*"
+        rules := []models.ExtractionRule{
+                {ID: "pattern-old", Name: "price", URLPattern: &first},
+                {ID: "pattern-new", Name: "price", URLPattern: &second},
+                {ID: "pattern-title", Name: "title", URLPattern: &second},
+                {ID: "direct-title", Name: "title", URLID: &urlID},
+        }
+
+        got := applicableRules(rules, urlID, "https://example.com/products/1")
+        ids := make(map[string]string)
+        for _, rule := range got {
+                if _, dup := ids[rule.Name]; dup {
+                        t.Fatalf("rule name %q applied twice", rule.Name)
+                }
+                ids[rule.Name] = rule.ID
+        }
+        if ids["price"] != "pattern-old" || ids["title"] != "direct-title" {
+                t.Errorf("applicable rules = %v", ids)
+        }
+}
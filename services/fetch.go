@@ -0,0 +1,257 @@
+package services
+
+import (
+        "bytes"
+        "compress/flate"
+        "compress/gzip"
+        "compress/zlib"
+        "crypto/tls"
+        "fmt"
+        "io"
+        "net/http"
+        "net/http/httptrace"
+        "strings"
+        "sync"
+        "time"
+
+        "github.com/PuerkitoBio/goquery"
+        "web-crawler/models"
+)
+
+// CrawlOptions controls optional, more expensive parts of a crawl.
+type CrawlOptions struct {
+        // Subresources fetches every referenced script, style, image and frame
+        // to count them and sum their size.
+        Subresources bool
+}
+
+// maxPageSize bounds the size of a page, both as transferred and once
+// decoded, so a small compressed body cannot expand without limit.
+const maxPageSize = 50 << 20
+
+// fetchedPage is the main document of a crawl: the response with its body
+// already read and decoded, plus the timing and size of the fetch.
+type fetchedPage struct {
+        resp    *http.Response
+        body    []byte
+        metrics models.FetchMetrics
+        // fetchedAt is when the response arrived.
+        fetchedAt time.Time
+}
+
+// fetchPage downloads the page while tracing each phase of the request. It
+// asks for compression itself so the transport hands over the encoded body
+// and the transferred size can be measured before decoding.
+func (c *Crawler) fetchPage(client *http.Client, pageURL string) (*fetchedPage, error) {
+        var (
+                start                                  = time.Now()
+                dnsStart, connectStart, tlsStart       time.Time
+                dnsDuration, connectDuration, tlsTotal time.Duration
+                firstByte                              time.Time
+        )
+
+        trace := &httptrace.ClientTrace{
+                DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+                DNSDone: func(httptrace.DNSDoneInfo) {
+                        if !dnsStart.IsZero() {
+                                dnsDuration = time.Since(dnsStart)
+                        }
+                },
+                ConnectStart: func(network, addr string) { connectStart = time.Now() },
+                ConnectDone: func(network, addr string, err error) {
+                        if !connectStart.IsZero() {
+                                connectDuration = time.Since(connectStart)
+                        }
+                },
+                TLSHandshakeStart: func() { tlsStart = time.Now() },
+                TLSHandshakeDone: func(tls.ConnectionState, error) {
+                        if !tlsStart.IsZero() {
+                                tlsTotal = time.Since(tlsStart)
+                        }
+                },
+                GotFirstResponseByte: func() { firstByte = time.Now() },
+        }
+
+        req, err := http.NewRequest(http.MethodGet, pageURL, nil)
+        if err != nil {
+                return nil, err
+        }
+        req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+        req.Header.Set("Accept-Encoding", "gzip, deflate")
+
+        resp, err := client.Do(req)
+        if err != nil {
+                return nil, err
+        }
+        defer resp.Body.Close()
+        fetchedAt := time.Now()
+
+        raw, err := readLimited(resp.Body)
+        if err != nil {
+                return nil, err
+        }
+        end := time.Now()
+
+        encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+        body, err := decodeBody(raw, encoding)
+        if err != nil {
+                return nil, fmt.Errorf("failed to decode %s body: %v", encoding, err)
+        }
+
+        if firstByte.IsZero() {
+                firstByte = end
+        }
+
+        metrics := models.FetchMetrics{
+                StatusCode:        resp.StatusCode,
+                DNSMs:             milliseconds(dnsDuration),
+                ConnectMs:         milliseconds(connectDuration),
+                TLSMs:             milliseconds(tlsTotal),
+                TTFBMs:            milliseconds(firstByte.Sub(start)),
+                DownloadMs:        milliseconds(end.Sub(firstByte)),
+                TotalMs:           milliseconds(end.Sub(start)),
+                CompressedBytes:   int64(len(raw)),
+                UncompressedBytes: int64(len(body)),
+                ContentEncoding:   encoding,
+        }
+
+        return &fetchedPage{resp: resp, body: body, metrics: metrics, fetchedAt: fetchedAt}, nil
+}
+
+// decodeBody undoes the Content-Encoding of a response body. Encodings the
+// crawler did not ask for, such as br or zstd, are kept as they are rather
+// than failing the crawl.
+func decodeBody(raw []byte, encoding string) ([]byte, error) {
+        switch encoding {
+        case "gzip", "x-gzip":
+                r, err := gzip.NewReader(bytes.NewReader(raw))
+                if err != nil {
+                        return nil, err
+                }
+                defer r.Close()
+                return readLimited(r)
+        case "deflate":
+                // "deflate" is meant to be zlib-wrapped, but some servers send raw deflate
+                if r, err := zlib.NewReader(bytes.NewReader(raw)); err == nil {
+                        defer r.Close()
+                        return readLimited(r)
+                }
+                r := flate.NewReader(bytes.NewReader(raw))
+                defer r.Close()
+                return readLimited(r)
+        }
+        return raw, nil
+}
+
+// readLimited reads r to the end, failing once more than maxPageSize bytes
+// come out of it.
+func readLimited(r io.Reader) ([]byte, error) {
+        body, err := io.ReadAll(io.LimitReader(r, maxPageSize+1))
+        if err != nil {
+                return nil, err
+        }
+        if len(body) > maxPageSize {
+                return nil, fmt.Errorf("body exceeds %d MB", maxPageSize>>20)
+        }
+        return body, nil
+}
+
+func milliseconds(d time.Duration) float64 {
+        return float64(d.Microseconds()) / 1000
+}
+
+// subresourceSelectors lists the elements whose referenced files make up the
+// weight of a page, with the attribute holding the URL.
+var subresourceSelectors = []struct {
+        selector  string
+        attribute string
+}{
+        {"script[src]", "src"},
+        {"link[rel~='stylesheet'][href]", "href"},
+        {"link[rel~='icon'][href]", "href"},
+        {"link[rel~='preload'][href]", "href"},
+        {"img[src]", "src"},
+        {"img[srcset], source[srcset]", "srcset"},
+        {"audio[src], video[src], source[src]", "src"},
+        {"iframe[src]", "src"},
+}
+
+// measureSubresources fetches the page's subresources and returns how many
+// there are and their total transferred size.
+func (c *Crawler) measureSubresources(client *http.Client, doc *goquery.Document, page *fetchedPage, stopChan <-chan bool) (int, int64) {
+        seen := make(map[string]bool)
+        var urls []string
+        base := documentBase(doc, page.resp.Request.URL)
+        for _, source := range subresourceSelectors {
+                doc.Find(source.selector).Each(func(i int, s *goquery.Selection) {
+                        for _, raw := range attributeURLs(s, source.attribute) {
+                                resolved, err := resolveLink(base, raw)
+                                if err != nil || (resolved.Scheme != "http" && resolved.Scheme != "https") {
+                                        continue
+                                }
+                                resolved.Fragment = ""
+                                if key := resolved.String(); !seen[key] {
+                                        seen[key] = true
+                                        urls = append(urls, key)
+                                }
+                        }
+                })
+        }
+
+        var wg sync.WaitGroup
+        var mutex sync.Mutex
+        var total int64
+        semaphore := make(chan struct{}, 10)
+        stopped := false
+
+        for _, resourceURL := range urls {
+                select {
+                case <-stopChan:
+                        stopped = true
+                default:
+                }
+                if stopped {
+                        break
+                }
+
+                wg.Add(1)
+                semaphore <- struct{}{}
+                go func(resourceURL string) {
+                        defer wg.Done()
+                        defer func() { <-semaphore }()
+
+                        size := c.resourceSize(client, resourceURL)
+                        mutex.Lock()
+                        total += size
+                        mutex.Unlock()
+                }(resourceURL)
+        }
+
+        wg.Wait()
+        return len(urls), total
+}
+
+// resourceSize returns the transferred size of a resource, taken from
+// Content-Length when the server sends it and by downloading it otherwise.
+func (c *Crawler) resourceSize(client *http.Client, resourceURL string) int64 {
+        req, err := http.NewRequest(http.MethodGet, resourceURL, nil)
+        if err != nil {
+                return 0
+        }
+        req.Header.Set("Accept-Encoding", "gzip, deflate")
+
+        resp, err := client.Do(req)
+        if err != nil {
+                return 0
+        }
+        defer resp.Body.Close()
+
+        if resp.StatusCode >= 400 {
+                return 0
+        }
+        if resp.ContentLength >= 0 {
+                return resp.ContentLength
+        }
+        n, _ := io.Copy(io.Discard, resp.Body)
+        return n
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+        _ "embed"
+        "encoding/json"
+        "fmt"
+        "net/http"
+        "regexp"
+        "sort"
+        "strings"
+
+        "github.com/PuerkitoBio/goquery"
+        "web-crawler/models"
+)
+
+//go:embed signatures/technologies.json
+var technologySignatureFile []byte
+
+// technologySignature describes how to recognise one technology. Every
+// pattern is a case-insensitive regular expression whose first capture group,
+// if any, is the version. An empty header or cookie pattern only checks that
+// the header or cookie is present.
+type technologySignature struct {
+        Name     string            `json:"name"`
+        Category string            `json:"category"`
+        Meta     map[string]string `json:"meta"`
+        Headers  map[string]string `json:"headers"`
+        Cookies  map[string]string `json:"cookies"`
+        Scripts  []string          `json:"scripts"`
+        HTML     []string          `json:"html"`
+        Implies  []string          `json:"implies"`
+
+        meta    []namedPattern
+        headers []namedPattern
+        cookies []namedPattern
+        scripts []*regexp.Regexp
+        html    []*regexp.Regexp
+}
+
+// namedPattern is a pattern for the header, cookie or meta tag with the
+// given name. Signatures keep them sorted by name so the version reported
+// when several patterns capture one is the same on every crawl.
+type namedPattern struct {
+        name string
+        re   *regexp.Regexp
+}
+
+// technologySignatures is parsed once from the bundled signature file; a
+// broken file is a build problem, so it fails at startup.
+var technologySignatures = mustLoadSignatures(technologySignatureFile)
+
+func mustLoadSignatures(data []byte) []*technologySignature {
+        var signatures []*technologySignature
+        if err := json.Unmarshal(data, &signatures); err != nil {
+                panic(fmt.Sprintf("invalid technology signature file: %v", err))
+        }
+
+        compileMap := func(patterns map[string]string, lowerKeys bool) []namedPattern {
+                compiled := make([]namedPattern, 0, len(patterns))
+                for key, pattern := range patterns {
+                        if lowerKeys {
+                                key = strings.ToLower(key)
+                        }
+                        compiled = append(compiled, namedPattern{name: key, re: regexp.MustCompile("(?i)" + pattern)})
+                }
+                sort.Slice(compiled, func(i, j int) bool { return compiled[i].name < compiled[j].name })
+                return compiled
+        }
+        compileList := func(patterns []string) []*regexp.Regexp {
+                compiled := make([]*regexp.Regexp, len(patterns))
+                for i, pattern := range patterns {
+                        compiled[i] = regexp.MustCompile("(?i)" + pattern)
+                }
+                return compiled
+        }
+
+        for _, sig := range signatures {
+                sig.meta = compileMap(sig.Meta, true)
+                sig.headers = compileMap(sig.Headers, false)
+                sig.cookies = compileMap(sig.Cookies, false)
+                sig.scripts = compileList(sig.Scripts)
+                sig.html = compileList(sig.HTML)
+        }
+
+        return signatures
+}
+
+// fingerprintTechnologies matches the response headers, cookies and document
+// against the bundled signatures.
+func (c *Crawler) fingerprintTechnologies(header http.Header, cookies []*http.Cookie, doc *goquery.Document) []models.Technology {
+        meta := make(map[string][]string)
+        doc.Find("meta[name][content]").Each(func(i int, s *goquery.Selection) {
+                name := strings.ToLower(s.AttrOr("name", ""))
+                meta[name] = append(meta[name], s.AttrOr("content", ""))
+        })
+
+        var scripts []string
+        doc.Find("script[src]").Each(func(i int, s *goquery.Selection) {
+                scripts = append(scripts, s.AttrOr("src", ""))
+        })
+
+        cookieNames := make(map[string]string)
+        for _, cookie := range cookies {
+                cookieNames[cookie.Name] = cookie.Value
+        }
+
+        html, _ := doc.Html()
+
+        detected := make(map[string]*models.Technology)
+        for _, sig := range technologySignatures {
+                matched, version := sig.match(header, cookieNames, meta, scripts, html)
+                if !matched {
+                        continue
+                }
+                detected[sig.Name] = &models.Technology{Name: sig.Name, Category: sig.Category, Version: version}
+        }
+
+        // Add implied technologies, e.g. PHP for WordPress
+        for changed := true; changed; {
+                changed = false
+                for _, sig := range technologySignatures {
+                        if detected[sig.Name] == nil {
+                                continue
+                        }
+                        for _, implied := range sig.Implies {
+                                if detected[implied] != nil {
+                                        continue
+                                }
+                                if impliedSig := findSignature(implied); impliedSig != nil {
+                                        detected[implied] = &models.Technology{Name: implied, Category: impliedSig.Category}
+                                        changed = true
+                                }
+                        }
+                }
+        }
+
+        technologies := make([]models.Technology, 0, len(detected))
+        for _, tech := range detected {
+                technologies = append(technologies, *tech)
+        }
+        sort.Slice(technologies, func(i, j int) bool {
+                if technologies[i].Category != technologies[j].Category {
+                        return technologies[i].Category < technologies[j].Category
+                }
+                return technologies[i].Name < technologies[j].Name
+        })
+
+        return technologies
+}
+
+// match reports whether any pattern of the signature matches, and the first
+// version captured by a matching pattern, checking headers, cookies, meta
+// tags, scripts and the HTML in that order.
+func (sig *technologySignature) match(header http.Header, cookies map[string]string, meta map[string][]string, scripts []string, html string) (bool, string) {
+        matched := false
+        version := ""
+        check := func(re *regexp.Regexp, value string) {
+                m := re.FindStringSubmatch(value)
+                if m == nil {
+                        return
+                }
+                matched = true
+                if version == "" && len(m) > 1 {
+                        version = m[1]
+                }
+        }
+
+        for _, p := range sig.headers {
+                for _, value := range header.Values(p.name) {
+                        check(p.re, value)
+                }
+        }
+        for _, p := range sig.cookies {
+                if value, ok := cookies[p.name]; ok {
+                        check(p.re, value)
+                }
+        }
+        for _, p := range sig.meta {
+                for _, value := range meta[p.name] {
+                        check(p.re, value)
+                }
+        }
+        for _, re := range sig.scripts {
+                for _, src := range scripts {
+                        check(re, src)
+                }
+        }
+        for _, re := range sig.html {
+                check(re, html)
+        }
+
+        return matched, version
+}
+
+func findSignature(name string) *technologySignature {
+        for _, sig := range technologySignatures {
+                if sig.Name == name {
+                        return sig
+                }
+        }
+        return nil
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+        "net/url"
+        "regexp"
+        "strings"
+
+        "github.com/PuerkitoBio/goquery"
+        "web-crawler/models"
+)
+
+// Form security finding codes
+const (
+        formFindingPasswordOverHTTP = "password_over_http"
+        formFindingCrossOrigin      = "cross_origin_post"
+        formFindingPasswordInGET    = "password_in_get"
+)
+
+var csrfFieldName = regexp.MustCompile(`(?i)csrf|xsrf|authenticity_token|requestverificationtoken|^_?token$|nonce`)
+
+// inventoryForms records every form on the page with its resolved action,
+// fields and the security problems found in it. Actions resolve against
+// the document base; pageURL is the final URL the page was served from.
+func (c *Crawler) inventoryForms(doc *goquery.Document, pageURL *url.URL) []models.Form {
+        var forms []models.Form
+
+        base := documentBase(doc, pageURL)
+        ids := documentIDs(doc)
+
+        doc.Find("form").Each(func(i int, s *goquery.Selection) {
+                form := models.Form{
+                        Position: i,
+                        Selector: cssPath(s, ids),
+                        Method:   strings.ToUpper(strings.TrimSpace(s.AttrOr("method", "GET"))),
+                        Fields:   []models.FormField{},
+                        Findings: []string{},
+                }
+                if form.Method != "POST" && form.Method != "DIALOG" {
+                        form.Method = "GET"
+                }
+
+                // An empty or missing action submits to the page itself
+                action := pageURL
+                if raw := strings.TrimSpace(s.AttrOr("action", "")); raw != "" {
+                        if parsed, err := url.Parse(raw); err == nil {
+                                action = base.ResolveReference(parsed)
+                        }
+                }
+                form.Action = action.String()
+
+                hasPassword := false
+                s.Find("input, select, textarea, button").Each(func(j int, field *goquery.Selection) {
+                        name, named := field.Attr("name")
+                        if !named || name == "" {
+                                return
+                        }
+
+                        typ := goquery.NodeName(field)
+                        if typ == "input" || typ == "button" {
+                                typ = strings.ToLower(field.AttrOr("type", defaultFieldType(typ)))
+                        }
+                        if typ == "password" {
+                                hasPassword = true
+                        }
+                        if typ == "hidden" && csrfFieldName.MatchString(name) && field.AttrOr("value", "") != "" {
+                                form.HasCSRFToken = true
+                        }
+
+                        form.Fields = append(form.Fields, models.FormField{Name: name, Type: typ})
+                })
+
+                if hasPassword && (pageURL.Scheme != "https" || action.Scheme != "https") {
+                        form.Findings = append(form.Findings, formFindingPasswordOverHTTP)
+                }
+                if form.Method == "POST" && !sameOrigin(pageURL, action) {
+                        form.Findings = append(form.Findings, formFindingCrossOrigin)
+                }
+                if form.Method == "GET" && hasPassword {
+                        form.Findings = append(form.Findings, formFindingPasswordInGET)
+                }
+
+                forms = append(forms, form)
+        })
+
+        return forms
+}
+
+func defaultFieldType(tag string) string {
+        if tag == "button" {
+                return "submit"
+        }
+        return "text"
+}
+
+func sameOrigin(a, b *url.URL) bool {
+        return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Hostname(), b.Hostname()) &&
+                effectivePort(a) == effectivePort(b)
+}
+
+func effectivePort(u *url.URL) string {
+        if port := u.Port(); port != "" {
+                return port
+        }
+        switch strings.ToLower(u.Scheme) {
+        case "https":
+                return "443"
+        case "http":
+                return "80"
+        }
+        return ""
+}
+
+func countInsecureForms(forms []models.Form) int {
+        count := 0
+        for _, f := range forms {
+                if len(f.Findings) > 0 {
+                        count++
+                }
+        }
+        return count
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+        "github.com/PuerkitoBio/goquery"
+        "web-crawler/models"
+)
+
+// Heading outline issue codes
+const (
+        headingIssueMultipleH1 = "multiple_h1"
+        headingIssueEmpty      = "empty"
+        headingIssueSkipped    = "skipped_level"
+)
+
+// extractHeadingOutline returns every heading in document order with the
+// structural problems found at that heading.
+func (c *Crawler) extractHeadingOutline(doc *goquery.Document) []models.Heading {
+        var outline []models.Heading
+        previous := 0
+        h1Seen := false
+
+        doc.Find("h1, h2, h3, h4, h5, h6").Each(func(i int, s *goquery.Selection) {
+                heading := models.Heading{
+                        Position: i,
+                        Level:    int(goquery.NodeName(s)[1] - '0'),
+                        Text:     collapseWhitespace(s.Text()),
+                        Issues:   []string{},
+                }
+
+                if heading.Level == 1 {
+                        if h1Seen {
+                                heading.Issues = append(heading.Issues, headingIssueMultipleH1)
+                        }
+                        h1Seen = true
+                }
+                if heading.Text == "" {
+                        heading.Issues = append(heading.Issues, headingIssueEmpty)
+                }
+                if previous > 0 && heading.Level > previous+1 {
+                        heading.Issues = append(heading.Issues, headingIssueSkipped)
+                }
+                previous = heading.Level
+
+                outline = append(outline, heading)
+        })
+
+        return outline
+}
+
+func countHeadingIssues(outline []models.Heading) int {
+        count := 0
+        for _, h := range outline {
+                count += len(h.Issues)
+        }
+        return count
+}
@@ -0,0 +1,429 @@
+package services
+
+import (
+        "bufio"
+        "database/sql"
+        "encoding/csv"
+        "encoding/json"
+        "fmt"
+        "io"
+        "strconv"
+        "strings"
+        "sync"
+        "time"
+
+        "web-crawler/models"
+)
+
+const (
+        // MaxImportRows is the largest number of entries accepted in one
+        // import.
+        MaxImportRows = 10000
+
+        maxTags      = 20
+        maxTagLength = 50
+
+        // importCrawlConcurrency is the number of imported URLs crawled at
+        // the same time when crawls are queued.
+        importCrawlConcurrency = 5
+)
+
+// Import formats accepted by ParseImport.
+const (
+        ImportFormatCSV  = "csv"
+        ImportFormatText = "text"
+        ImportFormatJSON = "json"
+)
+
+// ImportEntry is one URL of an import with its optional settings. Row is
+// the line of the entry in CSV and text files and its index, from 1, in
+// JSON files.
+type ImportEntry struct {
+        Row          int                  `json:"row"`
+        URL          string               `json:"url"`
+        Tags         []string             `json:"tags,omitempty"`
+        Schedule     *models.URLSchedule  `json:"schedule,omitempty"`
+        DNSOverrides []models.DNSOverride `json:"dns_overrides,omitempty"`
+        TLSInsecure  bool                 `json:"tls_insecure,omitempty"`
+        // RequestProfile is read but not applied since there are no request
+        // profiles; the entry's result warns that it was ignored.
+        RequestProfile string `json:"request_profile,omitempty"`
+}
+
+// ImportResult reports what happened to one entry: created, skipped as a
+// duplicate or invalid. Warnings list the parts of the entry that were
+// ignored.
+type ImportResult struct {
+        Row           int      `json:"row"`
+        URL           string   `json:"url"`
+        Status        string   `json:"status"`
+        ID            string   `json:"id,omitempty"`
+        NormalizedURL string   `json:"normalized_url,omitempty"`
+        ExistingID    string   `json:"existing_id,omitempty"`
+        DuplicateRow  int      `json:"duplicate_row,omitempty"`
+        Error         string   `json:"error,omitempty"`
+        Warnings      []string `json:"warnings,omitempty"`
+}
+
+// Import result statuses.
+const (
+        ImportCreated = "created"
+        ImportSkipped = "skipped"
+        ImportInvalid = "invalid"
+)
+
+// DetectImportFormat picks the import format from a file name, falling back
+// to plain text.
+func DetectImportFormat(filename string) string {
+        name := strings.ToLower(filename)
+        switch {
+        case strings.HasSuffix(name, ".csv"):
+                return ImportFormatCSV
+        case strings.HasSuffix(name, ".json"):
+                return ImportFormatJSON
+        default:
+                return ImportFormatText
+        }
+}
+
+// ParseImport reads the entries of an import file. Entries that cannot be
+// parsed are returned as invalid results instead of failing the import.
+func ParseImport(format string, r io.Reader) ([]ImportEntry, []ImportResult, error) {
+        switch format {
+        case ImportFormatCSV:
+                return parseCSVImport(r)
+        case ImportFormatJSON:
+                return parseJSONImport(r)
+        case ImportFormatText:
+                return parseTextImport(r)
+        default:
+                return nil, nil, fmt.Errorf("format must be one of %s, %s, %s", ImportFormatCSV, ImportFormatText, ImportFormatJSON)
+        }
+}
+
+// parseTextImport reads one URL per line, skipping blank lines and lines
+// starting with #.
+func parseTextImport(r io.Reader) ([]ImportEntry, []ImportResult, error) {
+        var entries []ImportEntry
+        scanner := bufio.NewScanner(r)
+        for row := 1; scanner.Scan(); row++ {
+                line := strings.TrimSpace(scanner.Text())
+                if line == "" || strings.HasPrefix(line, "#") {
+                        continue
+                }
+                if len(entries) >= MaxImportRows {
+                        return nil, nil, fmt.Errorf("imports are limited to %d URLs", MaxImportRows)
+                }
+                entries = append(entries, ImportEntry{Row: row, URL: line})
+        }
+
+        return entries, nil, scanner.Err()
+}
+
+// csvColumns lists the columns a CSV import may have. Only url is
+// required; without a header row the first column is the URL.
+var csvColumns = map[string]bool{
+        "url": true, "tags": true, "cron": true, "interval_seconds": true, "timezone": true,
+        "jitter_seconds": true, "dns_overrides": true, "tls_insecure": true, "request_profile": true,
+}
+
+// parseCSVImport reads a CSV file. Tags and DNS overrides hold several
+// values separated by semicolons or, for tags, pipes.
+func parseCSVImport(r io.Reader) ([]ImportEntry, []ImportResult, error) {
+        reader := csv.NewReader(r)
+        reader.FieldsPerRecord = -1
+        reader.TrimLeadingSpace = true
+
+        records, err := reader.ReadAll()
+        if err != nil {
+                return nil, nil, fmt.Errorf("invalid CSV: %v", err)
+        }
+        if len(records) == 0 {
+                return nil, nil, nil
+        }
+
+        columns := map[string]int{"url": 0}
+        first := 0
+        if header := csvHeader(records[0]); header != nil {
+                columns = header
+                first = 1
+        }
+
+        var entries []ImportEntry
+        var invalid []ImportResult
+        for i := first; i < len(records); i++ {
+                record := records[i]
+                field := func(name string) string {
+                        if index, ok := columns[name]; ok && index < len(record) {
+                                return strings.TrimSpace(record[index])
+                        }
+                        return ""
+                }
+
+                entry := ImportEntry{Row: i + 1, URL: field("url")}
+                if entry.URL == "" && len(strings.Join(record, "")) == 0 {
+                        continue
+                }
+                if len(entries)+len(invalid) >= MaxImportRows {
+                        return nil, nil, fmt.Errorf("imports are limited to %d URLs", MaxImportRows)
+                }
+
+                if err := entry.parseCSVFields(field); err != nil {
+                        invalid = append(invalid, ImportResult{Row: entry.Row, URL: entry.URL, Status: ImportInvalid, Error: err.Error()})
+                        continue
+                }
+                entries = append(entries, entry)
+        }
+
+        return entries, invalid, nil
+}
+
+// csvHeader returns the column indexes of a header row, or nil when the
+// row is not a header.
+func csvHeader(record []string) map[string]int {
+        columns := make(map[string]int)
+        for i, name := range record {
+                name = strings.ToLower(strings.TrimSpace(name))
+                if csvColumns[name] {
+                        columns[name] = i
+                }
+        }
+        if _, ok := columns["url"]; !ok {
+                return nil
+        }
+        return columns
+}
+
+func (e *ImportEntry) parseCSVFields(field func(string) string) error {
+        e.RequestProfile = field("request_profile")
+
+        if tags := field("tags"); tags != "" {
+                e.Tags = strings.FieldsFunc(tags, func(r rune) bool { return r == ';' || r == '|' })
+        }
+
+        cron, interval := field("cron"), field("interval_seconds")
+        if cron != "" || interval != "" {
+                schedule := &models.URLSchedule{Timezone: field("timezone")}
+                if cron != "" {
+                        schedule.Cron = &cron
+                }
+                if interval != "" {
+                        seconds, err := strconv.Atoi(interval)
+                        if err != nil {
+                                return fmt.Errorf("interval_seconds must be a number")
+                        }
+                        schedule.IntervalSeconds = &seconds
+                }
+                if jitter := field("jitter_seconds"); jitter != "" {
+                        seconds, err := strconv.Atoi(jitter)
+                        if err != nil {
+                                return fmt.Errorf("jitter_seconds must be a number")
+                        }
+                        schedule.JitterSeconds = seconds
+                }
+                e.Schedule = schedule
+        }
+
+        if overrides := field("dns_overrides"); overrides != "" {
+                parsed, err := ParseDNSOverrides(strings.ReplaceAll(overrides, ";", ","))
+                if err != nil {
+                        return err
+                }
+                e.DNSOverrides = parsed
+        }
+
+        if insecure := field("tls_insecure"); insecure != "" {
+                value, err := strconv.ParseBool(insecure)
+                if err != nil {
+                        return fmt.Errorf("tls_insecure must be true or false")
+                }
+                e.TLSInsecure = value
+        }
+
+        return nil
+}
+
+// parseJSONImport reads a JSON array whose items are URL strings or
+// objects shaped like ImportEntry.
+func parseJSONImport(r io.Reader) ([]ImportEntry, []ImportResult, error) {
+        var items []json.RawMessage
+        if err := json.NewDecoder(r).Decode(&items); err != nil {
+                return nil, nil, fmt.Errorf("invalid JSON: expected an array of URL strings or objects")
+        }
+        if len(items) > MaxImportRows {
+                return nil, nil, fmt.Errorf("imports are limited to %d URLs", MaxImportRows)
+        }
+
+        var entries []ImportEntry
+        var invalid []ImportResult
+        for i, item := range items {
+                var entry ImportEntry
+                if err := json.Unmarshal(item, &entry.URL); err != nil {
+                        if err := json.Unmarshal(item, &entry); err != nil {
+                                invalid = append(invalid, ImportResult{Row: i + 1, Status: ImportInvalid, Error: "entry must be a URL string or an object with a url"})
+                                continue
+                        }
+                }
+                entry.Row = i + 1
+                entry.URL = strings.TrimSpace(entry.URL)
+                entries = append(entries, entry)
+        }
+
+        return entries, invalid, nil
+}
+
+// NormalizeTags trims tags and drops empty and repeated ones.
+func NormalizeTags(tags []string) ([]string, error) {
+        seen := make(map[string]bool)
+        normalized := []string{}
+        for _, tag := range tags {
+                tag = strings.TrimSpace(tag)
+                if tag == "" || seen[strings.ToLower(tag)] {
+                        continue
+                }
+                if len(tag) > maxTagLength {
+                        return nil, fmt.Errorf("tag %q is longer than %d characters", tag, maxTagLength)
+                }
+                seen[strings.ToLower(tag)] = true
+                normalized = append(normalized, tag)
+        }
+        if len(normalized) > maxTags {
+                return nil, fmt.Errorf("at most %d tags are allowed", maxTags)
+        }
+        return normalized, nil
+}
+
+// ImportURLs validates, normalizes and creates the URLs of an import in row
+// order. URLs already tracked, or repeated earlier in the import, are
+// skipped. Each URL is created together with its settings in one
+// transaction so invalid rows leave nothing behind.
+func ImportURLs(db *sql.DB, entries []ImportEntry) []ImportResult {
+        results := make([]ImportResult, 0, len(entries))
+        seen := make(map[string]int)
+
+        for _, entry := range entries {
+                result := ImportResult{Row: entry.Row, URL: entry.URL}
+                if err := importURL(db, entry, seen, &result); err != nil {
+                        result.Status = ImportInvalid
+                        result.Error = err.Error()
+                }
+                results = append(results, result)
+        }
+
+        return results
+}
+
+func importURL(db *sql.DB, entry ImportEntry, seen map[string]int, result *ImportResult) error {
+        if entry.URL == "" {
+                return fmt.Errorf("url is required")
+        }
+        if entry.RequestProfile != "" {
+                result.Warnings = append(result.Warnings, "request_profile was ignored: request profiles are not supported")
+        }
+        if err := ValidateURL(entry.URL); err != nil {
+                return err
+        }
+        normalizedURL, err := NormalizeURL(entry.URL)
+        if err != nil {
+                return err
+        }
+        result.NormalizedURL = normalizedURL
+
+        tags, err := NormalizeTags(entry.Tags)
+        if err != nil {
+                return err
+        }
+        var next time.Time
+        if entry.Schedule != nil {
+                schedule, err := ParseSchedule(*entry.Schedule)
+                if err != nil {
+                        return err
+                }
+                if next = schedule.Next(time.Now()); next.IsZero() {
+                        return fmt.Errorf("schedule never runs")
+                }
+        }
+        for i := range entry.DNSOverrides {
+                if err := ValidateDNSOverride(&entry.DNSOverrides[i]); err != nil {
+                        return err
+                }
+        }
+
+        if row, ok := seen[normalizedURL]; ok {
+                result.Status = ImportSkipped
+                result.DuplicateRow = row
+                return nil
+        }
+
+        if existing, err := models.GetURLByNormalizedURL(db, normalizedURL); err == nil {
+                seen[normalizedURL] = entry.Row
+                result.Status = ImportSkipped
+                result.ExistingID = existing.ID
+                return nil
+        } else if err != sql.ErrNoRows {
+                return err
+        }
+
+        tx, err := db.Begin()
+        if err != nil {
+                return err
+        }
+        defer tx.Rollback()
+
+        url, err := models.CreateURL(tx, entry.URL, normalizedURL)
+        if err == models.ErrDuplicateURL {
+                result.Status = ImportSkipped
+                return nil
+        }
+        if err != nil {
+                return err
+        }
+
+        if len(tags) > 0 {
+                if err := models.SetURLTags(tx, url.ID, tags); err != nil {
+                        return err
+                }
+        }
+        if entry.Schedule != nil {
+                if err := models.SetURLSchedule(tx, url.ID, *entry.Schedule, next); err != nil {
+                        return err
+                }
+        }
+        if len(entry.DNSOverrides) > 0 {
+                if err := models.SetDNSOverrides(tx, url.ID, entry.DNSOverrides); err != nil {
+                        return err
+                }
+        }
+        if entry.TLSInsecure {
+                if err := models.SetTLSInsecure(tx, url.ID, true); err != nil {
+                        return err
+                }
+        }
+        if err := tx.Commit(); err != nil {
+                return err
+        }
+
+        seen[normalizedURL] = entry.Row
+        result.Status = ImportCreated
+        result.ID = url.ID
+        return nil
+}
+
+// QueueCrawls crawls the URLs in the background, a few at a time, so large
+// imports do not start thousands of crawls at once.
+func (c *Crawler) QueueCrawls(urlIDs []string) {
+        go func() {
+                semaphore := make(chan struct{}, importCrawlConcurrency)
+                var wg sync.WaitGroup
+                for _, id := range urlIDs {
+                        semaphore <- struct{}{}
+                        wg.Add(1)
+                        go func(id string) {
+                                defer wg.Done()
+                                defer func() { <-semaphore }()
+                                c.CrawlURL(id)
+                        }(id)
+                }
+                wg.Wait()
+        }()
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+        "strings"
+        "unicode"
+)
+
+// minLanguageWords is the number of words below which no language is
+// reported; short texts give unreliable stopword counts.
+const minLanguageWords = 20
+
+// languageStopwords holds frequent function words per ISO 639-1 language.
+// Latin-script text is classified by which list matches the most words.
+var languageStopwords = map[string][]string{
+        "en": {"the", "and", "of", "to", "in", "is", "that", "for", "it", "with", "as", "was", "on", "are", "be", "this", "by", "you", "not", "or", "have", "from", "at", "which", "but", "an", "they", "we", "can", "their", "has", "will", "would", "there", "been", "were", "what", "when", "your", "more"},
+        "de": {"der", "die", "und", "in", "den", "von", "zu", "das", "mit", "sich", "des", "auf", "für", "ist", "im", "dem", "nicht", "ein", "eine", "als", "auch", "es", "an", "werden", "aus", "er", "hat", "dass", "sie", "nach", "wird", "bei", "einer", "um", "am", "sind", "noch", "wie", "einem", "über", "so", "zum", "oder", "aber", "ich", "wir"},
+        "fr": {"le", "de", "la", "et", "les", "des", "en", "un", "du", "une", "que", "est", "pour", "qui", "dans", "par", "plus", "pas", "au", "sur", "ne", "se", "sont", "ce", "il", "avec", "aux", "ou", "nous", "vous", "mais", "cette", "son", "ses", "été", "leur", "tout", "elle"},
+        "es": {"de", "la", "que", "el", "en", "y", "los", "del", "se", "las", "por", "un", "para", "con", "no", "una", "su", "al", "es", "lo", "como", "más", "pero", "sus", "le", "ya", "o", "fue", "este", "ha", "sí", "porque", "esta", "son", "entre", "está", "cuando", "muy", "sin", "sobre"},
+        "it": {"di", "e", "il", "la", "che", "in", "un", "a", "per", "è", "non", "una", "sono", "del", "della", "le", "si", "con", "da", "i", "al", "lo", "gli", "come", "ma", "più", "anche", "nel", "dei", "questo", "alla", "ha", "delle", "o", "se", "essere", "suo", "sua"},
+        "pt": {"de", "a", "o", "que", "e", "do", "da", "em", "um", "para", "é", "com", "não", "uma", "os", "no", "se", "na", "por", "mais", "as", "dos", "como", "mas", "foi", "ao", "ele", "das", "tem", "à", "seu", "sua", "ou", "ser", "quando", "muito", "há", "nos", "já", "está", "também"},
+        "nl": {"de", "en", "van", "het", "een", "in", "is", "dat", "op", "te", "zijn", "voor", "met", "die", "niet", "aan", "er", "om", "ook", "als", "maar", "bij", "of", "uit", "nog", "worden", "door", "naar", "heeft", "wordt", "dan", "ze", "wel", "hij", "kan", "zij", "je", "we", "over"},
+        "sv": {"och", "i", "att", "det", "som", "en", "på", "är", "av", "för", "med", "till", "den", "har", "de", "inte", "om", "ett", "var", "jag", "men", "så", "vi", "kan", "man", "från", "eller", "sig", "när", "också", "hade", "efter", "vid", "sina", "han", "hon", "mycket", "skulle"},
+        "pl": {"i", "w", "się", "na", "z", "nie", "do", "to", "że", "jest", "o", "jak", "po", "co", "tak", "za", "od", "ale", "przez", "są", "dla", "jego", "oraz", "jej", "tym", "być", "może", "już", "tylko", "który", "która", "które", "czy", "też", "jako", "bardzo", "ich"},
+}
+
+// stopwordSets is languageStopwords indexed for lookup.
+var stopwordSets = buildStopwordSets()
+
+func buildStopwordSets() map[string]map[string]bool {
+        sets := make(map[string]map[string]bool, len(languageStopwords))
+        for lang, words := range languageStopwords {
+                set := make(map[string]bool, len(words))
+                for _, w := range words {
+                        set[w] = true
+                }
+                sets[lang] = set
+        }
+        return sets
+}
+
+// scriptLanguages maps writing systems that identify a language (or a close
+// family) on their own.
+var scriptLanguages = []struct {
+        table *unicode.RangeTable
+        lang  string
+}{
+        {unicode.Hiragana, "ja"},
+        {unicode.Katakana, "ja"},
+        {unicode.Hangul, "ko"},
+        {unicode.Han, "zh"},
+        {unicode.Cyrillic, "ru"},
+        {unicode.Greek, "el"},
+        {unicode.Arabic, "ar"},
+        {unicode.Hebrew, "he"},
+        {unicode.Thai, "th"},
+        {unicode.Devanagari, "hi"},
+}
+
+// detectLanguage returns the ISO 639-1 code of the text's language, or an
+// empty string when it cannot be determined.
+func detectLanguage(text string, words []string) string {
+        if lang := detectScriptLanguage(text); lang != "" {
+                return lang
+        }
+        if len(words) < minLanguageWords {
+                return ""
+        }
+
+        best, bestHits := "", 0
+        for lang, set := range stopwordSets {
+                hits := 0
+                for _, word := range words {
+                        if set[strings.ToLower(word)] {
+                                hits++
+                        }
+                }
+                if hits > bestHits || (hits == bestHits && lang < best) {
+                        best, bestHits = lang, hits
+                }
+        }
+
+        // Require stopwords to make up a plausible share of the text
+        if float64(bestHits)/float64(len(words)) < 0.05 {
+                return ""
+        }
+        return best
+}
+
+// detectScriptLanguage classifies text written mostly in a non-Latin script.
+// Japanese is checked before Chinese because it mixes kana with Han.
+func detectScriptLanguage(text string) string {
+        letters := 0
+        counts := make(map[string]int)
+        for _, r := range text {
+                if !unicode.IsLetter(r) {
+                        continue
+                }
+                letters++
+                for _, script := range scriptLanguages {
+                        if unicode.Is(script.table, r) {
+                                counts[script.lang]++
+                                break
+                        }
+                }
+        }
+        if letters == 0 {
+                return ""
+        }
+
+        if counts["ja"] > 0 && float64(counts["ja"]+counts["zh"])/float64(letters) > 0.3 {
+                return "ja"
+        }
+        for _, script := range scriptLanguages {
+                if float64(counts[script.lang])/float64(letters) > 0.3 {
+                        return script.lang
+                }
+        }
+        return ""
+}
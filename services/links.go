@@ -0,0 +1,73 @@
+package services
+
+import (
+        "net/url"
+        "strings"
+
+        "github.com/PuerkitoBio/goquery"
+        "web-crawler/models"
+)
+
+// maxAnchorText is the number of characters of anchor text kept per link.
+const maxAnchorText = 500
+
+// linkSources lists the elements that contribute edges to the link graph
+// and the attribute holding the target.
+var linkSources = []struct {
+        selector  string
+        attribute string
+}{
+        {"a[href]", "href"},
+        {"area[href]", "href"},
+        {"link[href]", "href"},
+        {"iframe[src]", "src"},
+}
+
+// extractLinkGraph lists every link on the page with its resolved target,
+// anchor text, rel values and whether it stays on the page's host.
+func (c *Crawler) extractLinkGraph(doc *goquery.Document, pageURL *url.URL) []models.Link {
+        links := []models.Link{}
+        source := pageURL.String()
+        base := documentBase(doc, pageURL)
+
+        for _, ls := range linkSources {
+                doc.Find(ls.selector).Each(func(i int, s *goquery.Selection) {
+                        target, err := resolveLink(base, s.AttrOr(ls.attribute, ""))
+                        if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+                                return
+                        }
+                        target.Fragment = ""
+
+                        links = append(links, models.Link{
+                                SourceURL:  source,
+                                TargetURL:  target.String(),
+                                AnchorText: anchorText(s),
+                                Rel:        strings.Fields(strings.ToLower(s.AttrOr("rel", ""))),
+                                Element:    goquery.NodeName(s),
+                                Internal:   strings.EqualFold(target.Hostname(), pageURL.Hostname()),
+                        })
+                })
+        }
+
+        return links
+}
+
+// anchorText returns the text a link is labelled with: its text content,
+// else the alt text of an image inside it, else its aria-label or title.
+func anchorText(s *goquery.Selection) string {
+        text := collapseWhitespace(s.Text())
+        if text == "" {
+                text = collapseWhitespace(s.Find("img[alt]").First().AttrOr("alt", ""))
+        }
+        for _, attr := range []string{"alt", "aria-label", "title"} {
+                if text != "" {
+                        break
+                }
+                text = collapseWhitespace(s.AttrOr(attr, ""))
+        }
+
+        if runes := []rune(text); len(runes) > maxAnchorText {
+                text = string(runes[:maxAnchorText])
+        }
+        return text
+}
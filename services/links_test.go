@@ -0,0 +1,45 @@
+package services
+
+import (
+        "net/url"
+        "strings"
+        "testing"
+
+        "github.com/PuerkitoBio/goquery"
+)
+
+func TestExtractLinkGraphUsesBaseHref(t *testing.T) {
+        page := `<html><head><base href="https://cdn.example.com/docs/"></head><body>
+<a href="guide.html">Guide</a>
+<a href="/about">About</a>
+<a href="https://example.com/contact">Contact</a>
+</body></html>`
+        doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+        if err != nil {
+                t.Fatal(err)
+        }
+        pageURL, _ := url.Parse("https://example.com/index.html")
+
+        links := (&Crawler{}).extractLinkGraph(doc, pageURL)
+
+        want := []struct {
+                target   string
+                internal bool
+        }{
+                {"https://cdn.example.com/docs/guide.html", false},
+                {"https://cdn.example.com/about", false},
+                {"https://example.com/contact", true},
+        }
+        if len(links) != len(want) {
+                t.Fatalf("got %d links, want %d: %+v", len(links), len(want), links)
+        }
+        for i, w := range want {
+                if links[i].TargetURL != w.target || links[i].Internal != w.internal {
+                        t.Errorf("link %d = %s (internal %v), want %s (internal %v)",
+                                i, links[i].TargetURL, links[i].Internal, w.target, w.internal)
+                }
+                if links[i].SourceURL != pageURL.String() {
+                        t.Errorf("link %d source = %s, want the page URL", i, links[i].SourceURL)
+                }
+        }
+}
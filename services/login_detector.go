@@ -0,0 +1,277 @@
+package services
+
+import (
+        "math"
+        "regexp"
+        "strings"
+
+        "github.com/PuerkitoBio/goquery"
+)
+
+// Authentication form types reported by detectLoginForm
+const (
+        loginTypePassword      = "password_login"
+        loginTypeSignup        = "signup"
+        loginTypePasswordReset = "password_reset"
+        loginTypeSSO           = "sso"
+        loginTypeMultiStep     = "multi_step_login"
+)
+
+// loginTypes fixes the order form scores are compared in, so ties resolve
+// the same way on every crawl.
+var loginTypes = []string{loginTypePassword, loginTypeMultiStep, loginTypeSSO, loginTypeSignup, loginTypePasswordReset}
+
+// loginConfidenceThreshold is the confidence from which a detected login is
+// reported as has_login_form.
+const loginConfidenceThreshold = 0.5
+
+// maxSSOLinkScore caps the confidence single sign-on links give without a
+// form.
+const maxSSOLinkScore = 0.7
+
+var (
+        loginWords      = regexp.MustCompile(`(?i)\b(log ?in|sign ?in|signin|login|logon|log on|authenticate)\b`)
+        signupWords     = regexp.MustCompile(`(?i)\b(sign ?up|signup|register|registration|create (an |your )?account|join)\b`)
+        resetWords      = regexp.MustCompile(`(?i)\b(forgot|reset|recover|change|update|new) ?(your )?password\b|\breset\b|\brecover`)
+        nextWords       = regexp.MustCompile(`(?i)^\s*(next|continue|proceed|weiter)\s*$`)
+        newsletterWords = regexp.MustCompile(`(?i)\b(newsletter|subscribe|subscription|mailing list)\b`)
+        usernameHint    = regexp.MustCompile(`(?i)user|login|email|e-mail|account|identifier|phone`)
+        ssoText         = regexp.MustCompile(`(?i)\b(sign in|log in|login|continue|sign up) with (google|microsoft|apple|facebook|github|gitlab|twitter|linkedin|okta|sso)\b|\bsingle sign[- ]on\b`)
+        ssoHref         = regexp.MustCompile(`(?i)accounts\.google\.com/o/oauth2|login\.microsoftonline\.com|appleid\.apple\.com/auth|facebook\.com/[^/]*/?dialog/oauth|github\.com/login/oauth|/oauth2?/authorize|/saml2?/|/sso\b`)
+)
+
+// LoginDetection is the classification of the most likely authentication
+// form on a page.
+type LoginDetection struct {
+        Type       string
+        Confidence float64
+}
+
+// IsLogin reports whether the detection is confident enough to count as a
+// form the user signs in with.
+func (d LoginDetection) IsLogin() bool {
+        switch d.Type {
+        case loginTypePassword, loginTypeMultiStep, loginTypeSSO:
+                return d.Confidence >= loginConfidenceThreshold
+        }
+        return false
+}
+
+// detectLoginForm scores every form on the page by its structure and the
+// surrounding text, and returns the strongest authentication signal found.
+func (c *Crawler) detectLoginForm(doc *goquery.Document) LoginDetection {
+        best := LoginDetection{}
+
+        doc.Find("form").Each(func(i int, form *goquery.Selection) {
+                scores := scoreForm(form)
+                for _, typ := range loginTypes {
+                        if score := scores[typ]; score > best.Confidence {
+                                best = LoginDetection{Type: typ, Confidence: score}
+                        }
+                }
+        })
+
+        // SSO and OAuth buttons are often plain links outside any form
+        if score := scoreSSO(doc); score > best.Confidence {
+                best = LoginDetection{Type: loginTypeSSO, Confidence: score}
+        }
+
+        best.Confidence = math.Round(math.Min(best.Confidence, 1)*100) / 100
+        return best
+}
+
+// scoreForm returns a score per authentication type for a single form.
+func scoreForm(form *goquery.Selection) map[string]float64 {
+        scores := make(map[string]float64)
+
+        passwords := form.Find("input[type='password']")
+        usernames := form.Find("input").FilterFunction(func(i int, s *goquery.Selection) bool {
+                return isUsernameField(s)
+        })
+        submitText := formSubmitText(form)
+        action := form.AttrOr("action", "")
+        identity := form.AttrOr("id", "") + " " + form.AttrOr("class", "") + " " + form.AttrOr("name", "")
+        context := formContextText(form)
+
+        currentPassword := passwords.Filter("[autocomplete~='current-password']").Length()
+        newPassword := passwords.Filter("[autocomplete~='new-password']").Length()
+        visibleFields := form.Find("input, select, textarea").Not("[type='hidden'], [type='submit'], [type='button'], [type='reset'], [type='image'], [type='checkbox'], [type='radio']").Length()
+
+        // Password login: one password field next to a username
+        if passwords.Length() == 1 {
+                scores[loginTypePassword] += 0.4
+        }
+        if currentPassword > 0 {
+                scores[loginTypePassword] += 0.3
+        }
+        if usernames.Length() > 0 && passwords.Length() > 0 {
+                scores[loginTypePassword] += 0.2
+        }
+        if loginWords.MatchString(submitText) {
+                scores[loginTypePassword] += 0.25
+        }
+        if loginWords.MatchString(action) || loginWords.MatchString(identity) {
+                scores[loginTypePassword] += 0.1
+        }
+        if loginWords.MatchString(context) {
+                scores[loginTypePassword] += 0.1
+        }
+        if form.Find("input[type='checkbox'][name*='remember'], input[type='checkbox'][id*='remember']").Length() > 0 {
+                scores[loginTypePassword] += 0.05
+        }
+
+        // Signup: password confirmation, new-password hints, extra profile fields
+        if passwords.Length() >= 2 {
+                scores[signupTypeOrReset(context, submitText)] += 0.3
+        }
+        if newPassword > 0 {
+                scores[loginTypeSignup] += 0.2
+                scores[loginTypePassword] -= 0.3
+        }
+        if signupWords.MatchString(submitText) {
+                scores[loginTypeSignup] += 0.35
+                scores[loginTypePassword] -= 0.2
+        }
+        if signupWords.MatchString(action) || signupWords.MatchString(identity) {
+                scores[loginTypeSignup] += 0.15
+        }
+        if signupWords.MatchString(context) {
+                scores[loginTypeSignup] += 0.1
+        }
+        if passwords.Length() > 0 && visibleFields >= 4 {
+                scores[loginTypeSignup] += 0.1
+        }
+
+        // Password reset or change
+        if resetWords.MatchString(submitText) {
+                scores[loginTypePasswordReset] += 0.4
+                scores[loginTypePassword] -= 0.3
+        }
+        if resetWords.MatchString(action) || resetWords.MatchString(identity) {
+                scores[loginTypePasswordReset] += 0.2
+        }
+        if resetWords.MatchString(context) {
+                scores[loginTypePasswordReset] += 0.15
+        }
+        if currentPassword > 0 && newPassword > 0 {
+                scores[loginTypePasswordReset] += 0.3
+        }
+        if passwords.Length() == 0 && usernames.Length() == 1 && visibleFields == 1 {
+                scores[loginTypePasswordReset] += 0.1
+        }
+
+        // Multi-step login: identifier first, password on the next screen
+        if passwords.Length() == 0 && usernames.Length() == 1 && visibleFields == 1 {
+                scores[loginTypeMultiStep] += 0.3
+                if nextWords.MatchString(submitText) || loginWords.MatchString(submitText) {
+                        scores[loginTypeMultiStep] += 0.2
+                }
+                if loginWords.MatchString(context) || loginWords.MatchString(action) || loginWords.MatchString(identity) {
+                        scores[loginTypeMultiStep] += 0.3
+                }
+                if usernames.Filter("[autocomplete~='username']").Length() > 0 {
+                        scores[loginTypeMultiStep] += 0.2
+                }
+        }
+
+        // Newsletter sign-ups look like single-field identifier forms
+        if newsletterWords.MatchString(submitText + " " + context + " " + identity) {
+                for _, typ := range loginTypes {
+                        if _, ok := scores[typ]; ok {
+                                scores[typ] -= 0.5
+                        }
+                }
+        }
+
+        return scores
+}
+
+// signupTypeOrReset decides whether a form with two password fields is a
+// registration or a password reset.
+func signupTypeOrReset(context, submitText string) string {
+        if resetWords.MatchString(context) || resetWords.MatchString(submitText) {
+                return loginTypePasswordReset
+        }
+        return loginTypeSignup
+}
+
+// scoreSSO scores the single sign-on buttons of the page. The first
+// provider gives 0.45 and each further one a little more, up to
+// maxSSOLinkScore, so a row of "Sign in with" links alone never reaches
+// the confidence of a real login form.
+func scoreSSO(doc *goquery.Document) float64 {
+        providers := make(map[string]bool)
+        doc.Find("a, button, [role='button']").Each(func(i int, s *goquery.Selection) {
+                text := collapseWhitespace(s.Text()) + " " + s.AttrOr("aria-label", "") + " " + s.AttrOr("title", "")
+                if m := ssoText.FindStringSubmatch(text); m != nil {
+                        provider := strings.ToLower(m[2])
+                        if provider == "" {
+                                provider = "sso"
+                        }
+                        providers[provider] = true
+                } else if m := ssoHref.FindString(s.AttrOr("href", "")); m != "" {
+                        providers[strings.ToLower(m)] = true
+                }
+        })
+
+        if len(providers) == 0 {
+                return 0
+        }
+        return math.Min(0.45+0.1*float64(len(providers)-1), maxSSOLinkScore)
+}
+
+func isUsernameField(s *goquery.Selection) bool {
+        typ := strings.ToLower(s.AttrOr("type", "text"))
+        switch typ {
+        case "email":
+                return true
+        case "text", "tel", "":
+        default:
+                return false
+        }
+
+        autocomplete := s.AttrOr("autocomplete", "")
+        if strings.Contains(autocomplete, "username") || strings.Contains(autocomplete, "email") {
+                return true
+        }
+        return usernameHint.MatchString(s.AttrOr("name", "") + " " + s.AttrOr("id", ""))
+}
+
+func formSubmitText(form *goquery.Selection) string {
+        var parts []string
+        form.Find("button, input[type='submit'], input[type='image']").Each(func(i int, s *goquery.Selection) {
+                if goquery.NodeName(s) == "input" {
+                        parts = append(parts, s.AttrOr("value", s.AttrOr("alt", "")))
+                } else {
+                        parts = append(parts, collapseWhitespace(s.Text()))
+                }
+        })
+        return strings.Join(parts, " ")
+}
+
+// formContextText returns the text a user sees around the form: its legends
+// and labels plus the nearest preceding heading.
+func formContextText(form *goquery.Selection) string {
+        parts := []string{
+                collapseWhitespace(form.Find("legend, label, h1, h2, h3, h4, h5, h6, p").Text()),
+        }
+
+        for s := form; s.Length() > 0 && goquery.NodeName(s) != "body"; s = s.Parent() {
+                // Siblings come nearest first; within one, its last heading
+                // is the one closest to the form
+                var heading *goquery.Selection
+                s.PrevAll().EachWithBreak(func(i int, sibling *goquery.Selection) bool {
+                        heading = sibling.Filter("h1, h2, h3, h4, h5, h6")
+                        if heading.Length() == 0 {
+                                heading = sibling.Find("h1, h2, h3, h4, h5, h6").Last()
+                        }
+                        return heading.Length() == 0
+                })
+                if heading != nil && heading.Length() > 0 {
+                        parts = append(parts, collapseWhitespace(heading.Text()))
+                        break
+                }
+        }
+
+        return strings.Join(parts, " ")
+}
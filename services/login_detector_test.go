@@ -0,0 +1,40 @@
+package services
+
+import (
+        "strings"
+        "testing"
+
+        "github.com/PuerkitoBio/goquery"
+)
+
+func TestScoreSSOCountsProvidersOnce(t *testing.T) {
+        doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<footer>
+                <a href="/a">Sign in with Google</a>
+                <a href="/b">Log in with Google</a>
+                <a href="/c">Sign in with GitHub</a>
+                <a href="/d">Sign in with Microsoft</a>
+                <a href="/e">Sign in with Apple</a>
+                <a href="/f">Sign in with Okta</a>
+        </footer>`))
+        if err != nil {
+                t.Fatal(err)
+        }
+
+        if score := scoreSSO(doc); score > maxSSOLinkScore {
+                t.Errorf("scoreSSO = %v, want at most %v", score, maxSSOLinkScore)
+        }
+}
+
+func TestScoreSSORepeatedProvider(t *testing.T) {
+        doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<div>
+                <a href="https://accounts.google.com/o/oauth2/auth">Sign in with Google</a>
+                <button>Continue with Google</button>
+        </div>`))
+        if err != nil {
+                t.Fatal(err)
+        }
+
+        if score := scoreSSO(doc); score != 0.45 {
+                t.Errorf("scoreSSO = %v, want 0.45", score)
+        }
+}
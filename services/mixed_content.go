@@ -0,0 +1,128 @@
+package services
+
+import (
+        "net/url"
+        "strings"
+
+        "github.com/PuerkitoBio/goquery"
+        "web-crawler/models"
+)
+
+// Mixed content kinds. Active content can modify the page (scripts, styles,
+// frames, form submissions); passive content can only be displayed.
+const (
+        mixedContentActive  = "active"
+        mixedContentPassive = "passive"
+        insecureLink        = "insecure_link"
+)
+
+// mixedContentSources maps selectors to the attribute holding the resource
+// URL and how browsers classify an insecure load of it.
+var mixedContentSources = []struct {
+        selector  string
+        attribute string
+        kind      string
+}{
+        {"script[src]", "src", mixedContentActive},
+        {"link[rel~='stylesheet'][href]", "href", mixedContentActive},
+        {"link[rel~='preload'][href], link[rel~='modulepreload'][href]", "href", mixedContentActive},
+        {"iframe[src], frame[src]", "src", mixedContentActive},
+        {"object[data]", "data", mixedContentActive},
+        {"embed[src]", "src", mixedContentActive},
+        {"form[action]", "action", mixedContentActive},
+        {"button[formaction], input[formaction]", "formaction", mixedContentActive},
+        {"img[src], input[type='image'][src]", "src", mixedContentPassive},
+        {"img[srcset], source[srcset]", "srcset", mixedContentPassive},
+        {"audio[src], video[src], source[src], track[src]", "src", mixedContentPassive},
+        {"video[poster]", "poster", mixedContentPassive},
+        {"link[rel~='icon'][href]", "href", mixedContentPassive},
+}
+
+// findMixedContent lists http:// subresources on an HTTPS page, and anchors
+// that downgrade the user from HTTPS to HTTP.
+func (c *Crawler) findMixedContent(doc *goquery.Document, pageURL *url.URL) []models.MixedContent {
+        var items []models.MixedContent
+        if pageURL.Scheme != "https" {
+                return items
+        }
+        base := documentBase(doc, pageURL)
+        ids := documentIDs(doc)
+
+        for _, source := range mixedContentSources {
+                doc.Find(source.selector).Each(func(i int, s *goquery.Selection) {
+                        for _, raw := range attributeURLs(s, source.attribute) {
+                                resolved, err := resolveLink(base, raw)
+                                if err != nil || resolved.Scheme != "http" {
+                                        continue
+                                }
+                                items = append(items, models.MixedContent{
+                                        Kind:        source.kind,
+                                        Element:     goquery.NodeName(s),
+                                        Attribute:   source.attribute,
+                                        ResourceURL: resolved.String(),
+                                        Selector:    cssPath(s, ids),
+                                })
+                        }
+                })
+        }
+
+        doc.Find("a[href], area[href]").Each(func(i int, s *goquery.Selection) {
+                resolved, err := resolveLink(base, s.AttrOr("href", ""))
+                if err != nil || resolved.Scheme != "http" {
+                        return
+                }
+                items = append(items, models.MixedContent{
+                        Kind:        insecureLink,
+                        Element:     goquery.NodeName(s),
+                        Attribute:   "href",
+                        ResourceURL: resolved.String(),
+                        Selector:    cssPath(s, ids),
+                })
+        })
+
+        return items
+}
+
+// attributeURLs returns the URLs referenced by an attribute; srcset holds a
+// comma-separated list of candidates with optional descriptors.
+func attributeURLs(s *goquery.Selection, attribute string) []string {
+        value := strings.TrimSpace(s.AttrOr(attribute, ""))
+        if value == "" {
+                return nil
+        }
+        if attribute != "srcset" {
+                return []string{value}
+        }
+
+        var urls []string
+        for _, candidate := range strings.Split(value, ",") {
+                if fields := strings.Fields(candidate); len(fields) > 0 {
+                        urls = append(urls, fields[0])
+                }
+        }
+        return urls
+}
+
+// resolveLink resolves an href against the page URL.
+func resolveLink(base *url.URL, href string) (*url.URL, error) {
+        linkURL, err := url.Parse(strings.TrimSpace(href))
+        if err != nil {
+                return nil, err
+        }
+        return base.ResolveReference(linkURL), nil
+}
+
+func countMixedContent(items []models.MixedContent) (int, int, int) {
+        active, passive, links := 0, 0, 0
+        for _, item := range items {
+                switch item.Kind {
+                case mixedContentActive:
+                        active++
+                case mixedContentPassive:
+                        passive++
+                case insecureLink:
+                        links++
+                }
+        }
+        return active, passive, links
+}
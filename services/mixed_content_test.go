@@ -0,0 +1,37 @@
+package services
+
+import (
+        "net/url"
+        "strings"
+        "testing"
+
+        "github.com/PuerkitoBio/goquery"
+)
+
+func TestFindMixedContentUsesBaseHref(t *testing.T) {
+        page := `<html><head><base href="http://static.example.com/"></head><body>
+<img src="logo.png">
+<script src="https://example.com/app.js"></script>
+<a href="about">About</a>
+</body></html>`
+        doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+        if err != nil {
+                t.Fatal(err)
+        }
+        pageURL, _ := url.Parse("https://example.com/")
+
+        items := (&Crawler{}).findMixedContent(doc, pageURL)
+
+        want := map[string]string{
+                "http://static.example.com/logo.png": mixedContentPassive,
+                "http://static.example.com/about":    insecureLink,
+        }
+        if len(items) != len(want) {
+                t.Fatalf("got %d items, want %d: %+v", len(items), len(want), items)
+        }
+        for _, item := range items {
+                if kind, ok := want[item.ResourceURL]; !ok || kind != item.Kind {
+                        t.Errorf("unexpected finding %s (%s)", item.ResourceURL, item.Kind)
+                }
+        }
+}
@@ -0,0 +1,268 @@
+package services
+
+import (
+        "database/sql"
+        "errors"
+        "fmt"
+        "log"
+        "net/url"
+        "os"
+        "path"
+        "sort"
+        "strings"
+        "sync"
+
+        "golang.org/x/net/idna"
+        "web-crawler/models"
+)
+
+// Trailing slash policies for URL normalization. TrailingSlashKeep leaves
+// paths alone, TrailingSlashStrip removes the slash ending a non-root path
+// and TrailingSlashAdd appends one to paths whose last segment has no file
+// extension.
+const (
+        TrailingSlashKeep  = "keep"
+        TrailingSlashStrip = "strip"
+        TrailingSlashAdd   = "add"
+)
+
+// defaultStripParams lists the tracking parameters removed from query
+// strings when URL_STRIP_PARAMS is not set. A trailing * matches any
+// parameter starting with the prefix.
+var defaultStripParams = []string{
+        "utm_*", "gclid", "gbraid", "wbraid", "dclid", "fbclid", "msclkid", "yclid",
+        "mc_cid", "mc_eid", "_ga", "_gl", "igshid", "ref_src",
+}
+
+// URLNormalizer turns URLs into the canonical form used to detect duplicate
+// tracked URLs.
+type URLNormalizer struct {
+        TrailingSlash string
+        StripParams   []string
+}
+
+var (
+        defaultNormalizer     *URLNormalizer
+        defaultNormalizerOnce sync.Once
+)
+
+// DefaultNormalizer returns the normalizer configured through the
+// URL_TRAILING_SLASH and URL_STRIP_PARAMS environment variables. An empty
+// URL_STRIP_PARAMS value set explicitly keeps every query parameter.
+func DefaultNormalizer() *URLNormalizer {
+        defaultNormalizerOnce.Do(func() {
+                defaultNormalizer = &URLNormalizer{
+                        TrailingSlash: TrailingSlashKeep,
+                        StripParams:   defaultStripParams,
+                }
+
+                switch policy := strings.ToLower(os.Getenv("URL_TRAILING_SLASH")); policy {
+                case TrailingSlashStrip, TrailingSlashAdd:
+                        defaultNormalizer.TrailingSlash = policy
+                case "", TrailingSlashKeep:
+                default:
+                        log.Printf("Unknown URL_TRAILING_SLASH policy %q, keeping trailing slashes", policy)
+                }
+
+                if params, ok := os.LookupEnv("URL_STRIP_PARAMS"); ok {
+                        defaultNormalizer.StripParams = nil
+                        for _, param := range strings.Split(params, ",") {
+                                if param = strings.TrimSpace(param); param != "" {
+                                        defaultNormalizer.StripParams = append(defaultNormalizer.StripParams, param)
+                                }
+                        }
+                }
+        })
+
+        return defaultNormalizer
+}
+
+// NormalizeURL normalizes a URL with the default normalizer.
+func NormalizeURL(raw string) (string, error) {
+        return DefaultNormalizer().Normalize(raw)
+}
+
+// Normalize returns the canonical form of an absolute http or https URL:
+// lowercase scheme and host, IDNA host in ASCII, no default port, resolved
+// dot segments, percent-encoding normalized, the trailing slash policy
+// applied, tracking parameters removed, the remaining parameters sorted and
+// the fragment dropped. Queries that cannot be parsed unambiguously, such
+// as those using ";" as a separator, are rejected.
+func (n *URLNormalizer) Normalize(raw string) (string, error) {
+        u, err := url.Parse(strings.TrimSpace(raw))
+        if err != nil {
+                return "", err
+        }
+
+        u.Scheme = strings.ToLower(u.Scheme)
+        if u.Scheme != "http" && u.Scheme != "https" {
+                return "", fmt.Errorf("unsupported scheme %q", u.Scheme)
+        }
+
+        hostname := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+        if hostname == "" {
+                return "", errors.New("missing host")
+        }
+        if !strings.Contains(hostname, ":") {
+                if hostname, err = idna.Lookup.ToASCII(hostname); err != nil {
+                        return "", fmt.Errorf("invalid host: %v", err)
+                }
+        } else {
+                hostname = "[" + hostname + "]"
+        }
+
+        port := u.Port()
+        if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+                port = ""
+        }
+        u.Host = hostname
+        if port != "" {
+                u.Host += ":" + port
+        }
+
+        // Clean the escaped path so reserved characters such as %2F stay
+        // distinct from the delimiters they encode
+        escaped := n.normalizePath(normalizeEscapes(u.EscapedPath()))
+        if u.Path, err = url.PathUnescape(escaped); err != nil {
+                return "", fmt.Errorf("invalid path: %v", err)
+        }
+        u.RawPath = escaped
+
+        query, err := url.ParseQuery(u.RawQuery)
+        if err != nil {
+                return "", fmt.Errorf("invalid query: %v", err)
+        }
+        u.RawQuery = n.normalizeQuery(query)
+        u.ForceQuery = false
+        u.Fragment = ""
+        u.RawFragment = ""
+
+        return u.String(), nil
+}
+
+// normalizeEscapes decodes percent-encoded unreserved characters and
+// uppercases the hex digits of the escapes that remain, so equivalent
+// encodings of a path compare equal.
+func normalizeEscapes(p string) string {
+        var b strings.Builder
+        for i := 0; i < len(p); i++ {
+                if p[i] != '%' || i+2 >= len(p) || !isHex(p[i+1]) || !isHex(p[i+2]) {
+                        b.WriteByte(p[i])
+                        continue
+                }
+                c := unhex(p[i+1])<<4 | unhex(p[i+2])
+                if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' {
+                        b.WriteByte(c)
+                } else {
+                        b.WriteString(strings.ToUpper(p[i : i+3]))
+                }
+                i += 2
+        }
+        return b.String()
+}
+
+func isHex(c byte) bool {
+        return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+        switch {
+        case '0' <= c && c <= '9':
+                return c - '0'
+        case 'a' <= c && c <= 'f':
+                return c - 'a' + 10
+        }
+        return c - 'A' + 10
+}
+
+func (n *URLNormalizer) normalizePath(p string) string {
+        if p == "" {
+                return "/"
+        }
+
+        trailing := strings.HasSuffix(p, "/")
+        p = path.Clean("/" + p)
+        if p == "/" {
+                return p
+        }
+
+        switch n.TrailingSlash {
+        case TrailingSlashStrip:
+                trailing = false
+        case TrailingSlashAdd:
+                trailing = trailing || !strings.Contains(path.Base(p), ".")
+        }
+        if trailing {
+                p += "/"
+        }
+        return p
+}
+
+func (n *URLNormalizer) normalizeQuery(values url.Values) string {
+        keys := make([]string, 0, len(values))
+        for key := range values {
+                if !n.stripParam(key) {
+                        keys = append(keys, key)
+                }
+        }
+        sort.Strings(keys)
+
+        var parts []string
+        for _, key := range keys {
+                params := append([]string(nil), values[key]...)
+                sort.Strings(params)
+                for _, value := range params {
+                        parts = append(parts, url.QueryEscape(key)+"="+url.QueryEscape(value))
+                }
+        }
+        return strings.Join(parts, "&")
+}
+
+func (n *URLNormalizer) stripParam(key string) bool {
+        key = strings.ToLower(key)
+        for _, param := range n.StripParams {
+                param = strings.ToLower(param)
+                if strings.HasSuffix(param, "*") {
+                        if strings.HasPrefix(key, strings.TrimSuffix(param, "*")) {
+                                return true
+                        }
+                } else if key == param {
+                        return true
+                }
+        }
+        return false
+}
+
+// BackfillNormalizedURLs stores the normalized key of tracked URLs created
+// before normalization was introduced, and refreshes keys computed with
+// earlier normalization rules or settings. URLs whose key is already taken
+// by another URL, or that cannot be normalized, are logged and keep their
+// current key.
+func BackfillNormalizedURLs(db *sql.DB) error {
+        urls, err := models.GetNormalizedKeys(db)
+        if err != nil {
+                return err
+        }
+
+        for _, u := range urls {
+                key, err := NormalizeURL(u.URL)
+                if err != nil {
+                        log.Printf("Cannot normalize URL %s: %v", u.URL, err)
+                        continue
+                }
+                if u.NormalizedURL != nil && *u.NormalizedURL == key {
+                        continue
+                }
+
+                err = models.SetNormalizedURL(db, u.ID, key)
+                if err == models.ErrDuplicateURL {
+                        log.Printf("URL %s duplicates another tracked URL as %s", u.URL, key)
+                        continue
+                }
+                if err != nil {
+                        return err
+                }
+        }
+
+        return nil
+}
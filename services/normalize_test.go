@@ -0,0 +1,113 @@
+package services
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+        n := &URLNormalizer{TrailingSlash: TrailingSlashKeep, StripParams: defaultStripParams}
+
+        tests := []struct {
+                raw  string
+                want string
+        }{
+                // Scheme, host and port
+                {"HTTPS://Example.COM", "https://example.com/"},
+                {"http://example.com:80/a", "http://example.com/a"},
+                {"https://example.com:443/a", "https://example.com/a"},
+                {"https://example.com:8443/a", "https://example.com:8443/a"},
+                {"https://example.com./a", "https://example.com/a"},
+                {"https://Bücher.example/", "https://xn--bcher-kva.example/"},
+                {"http://[::1]:8080/", "http://[::1]:8080/"},
+
+                // Paths
+                {"https://example.com/a/./b/../c", "https://example.com/a/c"},
+                {"https://example.com/a//b", "https://example.com/a/b"},
+                {"https://example.com/a/", "https://example.com/a/"},
+                {"https://example.com/%7Euser/%61bc", "https://example.com/~user/abc"},
+                {"https://example.com/a%2fb", "https://example.com/a%2Fb"},
+                {"https://example.com/a%2Fb/../c", "https://example.com/c"},
+                {"https://example.com/a%2F..%2Fb", "https://example.com/a%2F..%2Fb"},
+                {"https://example.com/caf%C3%A9", "https://example.com/caf%C3%A9"},
+                {"https://example.com/a b", "https://example.com/a%20b"},
+
+                // Queries and fragments
+                {"https://example.com/?b=2&a=1", "https://example.com/?a=1&b=2"},
+                {"https://example.com/?a=2&a=1", "https://example.com/?a=1&a=2"},
+                {"https://example.com/?utm_source=x&id=1&fbclid=y", "https://example.com/?id=1"},
+                {"https://example.com/?utm_source=x", "https://example.com/"},
+                {"https://example.com/?", "https://example.com/"},
+                {"https://example.com/?q=a+b", "https://example.com/?q=a+b"},
+                {"https://example.com/?q=a%20b", "https://example.com/?q=a+b"},
+                {"https://example.com/#section", "https://example.com/"},
+        }
+
+        for _, tt := range tests {
+                got, err := n.Normalize(tt.raw)
+                if err != nil {
+                        t.Errorf("Normalize(%q) failed: %v", tt.raw, err)
+                        continue
+                }
+                if got != tt.want {
+                        t.Errorf("Normalize(%q) = %q, want %q", tt.raw, got, tt.want)
+                }
+        }
+}
+
+func TestNormalizeDistinctResources(t *testing.T) {
+        n := &URLNormalizer{TrailingSlash: TrailingSlashKeep, StripParams: defaultStripParams}
+
+        pairs := [][2]string{
+                {"https://example.com/a%2Fb", "https://example.com/a/b"},
+                {"https://example.com/?a=1", "https://example.com/"},
+                {"http://example.com/", "https://example.com/"},
+                {"https://example.com/a", "https://example.com/a/"},
+        }
+        for _, pair := range pairs {
+                a, errA := n.Normalize(pair[0])
+                b, errB := n.Normalize(pair[1])
+                if errA != nil || errB != nil {
+                        t.Errorf("Normalize(%q, %q) failed: %v, %v", pair[0], pair[1], errA, errB)
+                        continue
+                }
+                if a == b {
+                        t.Errorf("%q and %q both normalize to %q", pair[0], pair[1], a)
+                }
+        }
+}
+
+func TestNormalizeTrailingSlash(t *testing.T) {
+        tests := []struct {
+                policy string
+                raw    string
+                want   string
+        }{
+                {TrailingSlashStrip, "https://example.com/a/", "https://example.com/a"},
+                {TrailingSlashStrip, "https://example.com/", "https://example.com/"},
+                {TrailingSlashAdd, "https://example.com/a", "https://example.com/a/"},
+                {TrailingSlashAdd, "https://example.com/a.html", "https://example.com/a.html"},
+                {TrailingSlashKeep, "https://example.com/a", "https://example.com/a"},
+        }
+
+        for _, tt := range tests {
+                n := &URLNormalizer{TrailingSlash: tt.policy}
+                if got, err := n.Normalize(tt.raw); err != nil || got != tt.want {
+                        t.Errorf("Normalize(%q) with %s = %q, %v, want %q", tt.raw, tt.policy, got, err, tt.want)
+                }
+        }
+}
+
+func TestNormalizeErrors(t *testing.T) {
+        n := &URLNormalizer{TrailingSlash: TrailingSlashKeep}
+
+        for _, raw := range []string{
+                "ftp://example.com/",
+                "https:///path",
+                "example.com/path",
+                "https://example.com/?a=1;b=2",
+                "https://example.com/?a=%zz",
+                "https://exa mple.com/",
+        } {
+                if got, err := n.Normalize(raw); err == nil {
+                        t.Errorf("Normalize(%q) = %q, want an error", raw, got)
+                }
+        }
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+        "database/sql"
+        "fmt"
+        "log"
+        "math/rand"
+        "sync"
+        "time"
+
+        // Bundle the timezone database so schedules work without system tzdata
+        _ "time/tzdata"
+
+        "web-crawler/models"
+)
+
+// schedulerTick is how often the scheduler looks for due URLs.
+const schedulerTick = 30 * time.Second
+
+// minScheduleInterval is the shortest interval a schedule may use.
+const minScheduleInterval = time.Minute
+
+// Schedule is a validated URL schedule ready to compute run times.
+type Schedule struct {
+        cron     *cronExpression
+        interval time.Duration
+        location *time.Location
+        jitter   time.Duration
+}
+
+// ParseSchedule validates a URL schedule. Exactly one of Cron and
+// IntervalSeconds must be set; an empty timezone means UTC.
+func ParseSchedule(s models.URLSchedule) (*Schedule, error) {
+        if (s.Cron == nil) == (s.IntervalSeconds == nil) {
+                return nil, fmt.Errorf("exactly one of cron and interval_seconds is required")
+        }
+        if s.JitterSeconds < 0 {
+                return nil, fmt.Errorf("jitter_seconds must not be negative")
+        }
+
+        location, err := time.LoadLocation(s.Timezone)
+        if err != nil {
+                return nil, fmt.Errorf("unknown timezone %q", s.Timezone)
+        }
+
+        schedule := &Schedule{
+                location: location,
+                jitter:   time.Duration(s.JitterSeconds) * time.Second,
+        }
+        if s.Cron != nil {
+                if schedule.cron, err = parseCron(*s.Cron); err != nil {
+                        return nil, fmt.Errorf("invalid cron expression: %v", err)
+                }
+        } else {
+                schedule.interval = time.Duration(*s.IntervalSeconds) * time.Second
+                if schedule.interval < minScheduleInterval {
+                        return nil, fmt.Errorf("interval_seconds must be at least %d", int(minScheduleInterval.Seconds()))
+                }
+        }
+
+        return schedule, nil
+}
+
+var (
+        jitterRand  = rand.New(rand.NewSource(time.Now().UnixNano()))
+        jitterMutex sync.Mutex
+)
+
+// Next returns the first run time after t, with a random jitter added.
+func (s *Schedule) Next(t time.Time) time.Time {
+        var next time.Time
+        if s.cron != nil {
+                next = s.cron.next(t, s.location)
+        } else {
+                next = t.Add(s.interval)
+        }
+
+        if s.jitter > 0 && !next.IsZero() {
+                jitterMutex.Lock()
+                next = next.Add(time.Duration(jitterRand.Int63n(int64(s.jitter) + 1)))
+                jitterMutex.Unlock()
+        }
+        return next
+}
+
+// Scheduler starts the scheduled recrawls of URLs. Runs missed while the
+// server was down are caught up with a single crawl rather than one per
+// missed run, and a run is skipped when the URL is still being crawled.
+type Scheduler struct {
+        db      *sql.DB
+        crawler *Crawler
+        stop    chan struct{}
+}
+
+func NewScheduler(db *sql.DB, crawler *Crawler) *Scheduler {
+        return &Scheduler{
+                db:      db,
+                crawler: crawler,
+                stop:    make(chan struct{}),
+        }
+}
+
+func (s *Scheduler) Start() {
+        go func() {
+                ticker := time.NewTicker(schedulerTick)
+                defer ticker.Stop()
+
+                s.runDue(time.Now())
+                for {
+                        select {
+                        case <-s.stop:
+                                return
+                        case now := <-ticker.C:
+                                s.runDue(now)
+                        }
+                }
+        }()
+}
+
+func (s *Scheduler) Stop() {
+        close(s.stop)
+}
+
+// runDue starts a crawl for every URL whose next run time has passed.
+func (s *Scheduler) runDue(now time.Time) {
+        urls, err := models.GetScheduledURLs(s.db)
+        if err != nil {
+                log.Printf("Scheduler: failed to load scheduled URLs: %v", err)
+                return
+        }
+
+        for _, u := range urls {
+                schedule, err := ParseSchedule(*u.Schedule)
+                if err != nil {
+                        log.Printf("Scheduler: invalid schedule for %s: %v", u.ID, err)
+                        continue
+                }
+
+                // Schedules without a next run time (e.g. set directly in the
+                // database) start counting from now
+                if u.NextScheduledAt == nil {
+                        if err := models.SetNextScheduledRun(s.db, u.ID, schedule.Next(now)); err != nil {
+                                log.Printf("Scheduler: failed to schedule %s: %v", u.ID, err)
+                        }
+                        continue
+                }
+                if u.NextScheduledAt.After(now) {
+                        continue
+                }
+
+                // Compute the next run from now so missed runs are not replayed
+                next := schedule.Next(now)
+
+                if !s.crawler.TryStartCrawl(u.ID, CrawlOptions{}) {
+                        log.Printf("Scheduler: skipping %s, previous crawl still running", u.ID)
+                        if err := models.SetNextScheduledRun(s.db, u.ID, next); err != nil {
+                                log.Printf("Scheduler: failed to schedule %s: %v", u.ID, err)
+                        }
+                        continue
+                }
+
+                if err := models.RecordScheduledRun(s.db, u.ID, now, next); err != nil {
+                        log.Printf("Scheduler: failed to record run for %s: %v", u.ID, err)
+                }
+        }
+}
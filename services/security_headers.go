@@ -0,0 +1,265 @@
+package services
+
+import (
+        "fmt"
+        "net/http"
+        "net/url"
+        "regexp"
+        "strconv"
+        "strings"
+
+        "web-crawler/models"
+)
+
+// minHSTSMaxAge is the shortest Strict-Transport-Security max-age (180 days)
+// that is not reported as too short.
+const minHSTSMaxAge = 15552000
+
+var hstsMaxAge = regexp.MustCompile(`(?i)max-age\s*=\s*"?(\d+)"?`)
+
+// auditSecurityHeaders grades the security-relevant response headers of the
+// main document. The score starts at 100 and each finding deducts its penalty.
+func (c *Crawler) auditSecurityHeaders(header http.Header, pageURL *url.URL) models.SecurityAudit {
+        audit := models.SecurityAudit{
+                Headers:  redactHeaders(header),
+                CSP:      map[string][]string{},
+                Findings: []models.SecurityFinding{},
+        }
+        add := func(name, severity string, penalty int, message string) {
+                audit.Findings = append(audit.Findings, models.SecurityFinding{
+                        Header:   name,
+                        Severity: severity,
+                        Penalty:  penalty,
+                        Message:  message,
+                })
+        }
+
+        // Content-Security-Policy
+        csp := header.Get("Content-Security-Policy")
+        if csp == "" {
+                if header.Get("Content-Security-Policy-Report-Only") != "" {
+                        add("Content-Security-Policy", severityWarning, 15, "CSP is only sent in report-only mode")
+                } else {
+                        add("Content-Security-Policy", severityError, 25, "Content-Security-Policy header is missing")
+                }
+        } else {
+                audit.CSP = parseCSP(csp)
+                auditCSP(audit.CSP, add)
+        }
+
+        // Strict-Transport-Security only has meaning on HTTPS responses
+        if pageURL.Scheme != "https" {
+                add("Strict-Transport-Security", severityError, 15, "Page is not served over HTTPS")
+        } else if hsts := header.Get("Strict-Transport-Security"); hsts == "" {
+                add("Strict-Transport-Security", severityError, 15, "Strict-Transport-Security header is missing")
+        } else if m := hstsMaxAge.FindStringSubmatch(hsts); m == nil {
+                add("Strict-Transport-Security", severityError, 10, "Strict-Transport-Security has no valid max-age")
+        } else if age, _ := strconv.Atoi(m[1]); age < minHSTSMaxAge {
+                add("Strict-Transport-Security", severityWarning, 5,
+                        fmt.Sprintf("Strict-Transport-Security max-age %d is shorter than 180 days", age))
+        }
+
+        // Clickjacking protection through X-Frame-Options or frame-ancestors
+        _, hasFrameAncestors := audit.CSP["frame-ancestors"]
+        switch xfo := strings.ToUpper(strings.TrimSpace(header.Get("X-Frame-Options"))); {
+        case xfo == "" && !hasFrameAncestors:
+                add("X-Frame-Options", severityError, 15, "Neither X-Frame-Options nor CSP frame-ancestors is set")
+        case xfo != "" && xfo != "DENY" && xfo != "SAMEORIGIN" && !hasFrameAncestors:
+                add("X-Frame-Options", severityWarning, 10, fmt.Sprintf("X-Frame-Options value %q is not supported by browsers", xfo))
+        }
+
+        if !strings.EqualFold(strings.TrimSpace(header.Get("X-Content-Type-Options")), "nosniff") {
+                add("X-Content-Type-Options", severityError, 10, "X-Content-Type-Options is not set to nosniff")
+        }
+
+        switch policy := strings.ToLower(lastToken(header.Get("Referrer-Policy"))); policy {
+        case "":
+                add("Referrer-Policy", severityWarning, 5, "Referrer-Policy header is missing")
+        case "unsafe-url", "no-referrer-when-downgrade":
+                add("Referrer-Policy", severityWarning, 5, fmt.Sprintf("Referrer-Policy %q leaks full URLs to other origins", policy))
+        }
+
+        if header.Get("Permissions-Policy") == "" {
+                add("Permissions-Policy", severityWarning, 5, "Permissions-Policy header is missing")
+        }
+
+        audit.Score = 100
+        for _, f := range audit.Findings {
+                audit.Score -= f.Penalty
+        }
+        if audit.Score < 0 {
+                audit.Score = 0
+        }
+
+        return audit
+}
+
+// credentialHeaders lists response headers whose values are credentials.
+// Headers with one of credentialHeaderWords in their name count too.
+var credentialHeaders = map[string]bool{
+        "Authorization":       true,
+        "Proxy-Authorization": true,
+        "Cookie":              true,
+}
+
+var credentialHeaderWords = []string{"token", "secret", "session", "password", "api-key", "apikey"}
+
+// redactHeaders returns a copy of the headers that is safe to store and
+// show: Set-Cookie keeps only the cookie names and credential-bearing
+// headers keep only their name.
+func redactHeaders(header http.Header) http.Header {
+        redacted := make(http.Header, len(header))
+        for name, values := range header {
+                switch {
+                case name == "Set-Cookie":
+                        for _, value := range values {
+                                cookieName, _, _ := strings.Cut(value, "=")
+                                redacted[name] = append(redacted[name], strings.TrimSpace(cookieName))
+                        }
+                case isCredentialHeader(name):
+                        for range values {
+                                redacted[name] = append(redacted[name], "[redacted]")
+                        }
+                default:
+                        redacted[name] = values
+                }
+        }
+        return redacted
+}
+
+func isCredentialHeader(name string) bool {
+        if credentialHeaders[name] {
+                return true
+        }
+        lower := strings.ToLower(name)
+        for _, word := range credentialHeaderWords {
+                if strings.Contains(lower, word) {
+                        return true
+                }
+        }
+        return false
+}
+
+// parseCSP splits a policy into directives and their source lists. Only the
+// first occurrence of a directive counts, as in browsers.
+func parseCSP(policy string) map[string][]string {
+        directives := make(map[string][]string)
+        for _, part := range strings.Split(policy, ";") {
+                tokens := strings.Fields(part)
+                if len(tokens) == 0 {
+                        continue
+                }
+                name := strings.ToLower(tokens[0])
+                if _, seen := directives[name]; seen {
+                        continue
+                }
+                directives[name] = tokens[1:]
+        }
+        return directives
+}
+
+// auditCSP checks how far the policy restricts scripts, plugins and the
+// document base URL: missing directives, unsafe keywords and sources that
+// allow any host, any host of a scheme or any subdomain of a host.
+func auditCSP(directives map[string][]string, add func(name, severity string, penalty int, message string)) {
+        auditScriptSources(directives, add)
+
+        // Plugins can run script, so object-src should be 'none'
+        objectSources, ok := directives["object-src"]
+        directive := "object-src"
+        if !ok {
+                objectSources, ok = directives["default-src"]
+                directive = "default-src"
+        }
+        if !ok {
+                add("Content-Security-Policy", severityWarning, 5, "CSP does not restrict plugins (no object-src or default-src)")
+        } else {
+                for _, src := range objectSources {
+                        if wildcard, hostWildcard := wildcardSource(src); wildcard || hostWildcard {
+                                add("Content-Security-Policy", severityWarning, 5,
+                                        fmt.Sprintf("%s allows wildcard source %s for plugins", directive, src))
+                        }
+                }
+        }
+
+        // base-uri does not fall back to default-src
+        baseSources, ok := directives["base-uri"]
+        if !ok {
+                add("Content-Security-Policy", severityWarning, 5,
+                        "CSP does not set base-uri, so an injected <base> tag can redirect relative script URLs")
+                return
+        }
+        for _, src := range baseSources {
+                if wildcard, hostWildcard := wildcardSource(src); wildcard || hostWildcard {
+                        add("Content-Security-Policy", severityWarning, 5, fmt.Sprintf("base-uri allows wildcard source %s", src))
+                }
+        }
+}
+
+func auditScriptSources(directives map[string][]string, add func(name, severity string, penalty int, message string)) {
+        scriptSources, ok := directives["script-src"]
+        directive := "script-src"
+        if !ok {
+                scriptSources, ok = directives["default-src"]
+                directive = "default-src"
+        }
+        if !ok {
+                add("Content-Security-Policy", severityWarning, 10, "CSP does not restrict scripts (no script-src or default-src)")
+                return
+        }
+
+        nonceOrHash := false
+        for _, src := range scriptSources {
+                lower := strings.ToLower(src)
+                if strings.HasPrefix(lower, "'nonce-") || strings.HasPrefix(lower, "'sha") {
+                        nonceOrHash = true
+                }
+        }
+
+        for _, src := range scriptSources {
+                switch strings.ToLower(src) {
+                case "'unsafe-inline'":
+                        // Browsers ignore unsafe-inline when a nonce or hash is present
+                        if !nonceOrHash {
+                                add("Content-Security-Policy", severityError, 10,
+                                        fmt.Sprintf("%s allows 'unsafe-inline'", directive))
+                        }
+                case "'unsafe-eval'":
+                        add("Content-Security-Policy", severityWarning, 5, fmt.Sprintf("%s allows 'unsafe-eval'", directive))
+                default:
+                        wildcard, hostWildcard := wildcardSource(src)
+                        if wildcard {
+                                add("Content-Security-Policy", severityError, 10,
+                                        fmt.Sprintf("%s allows wildcard source %s", directive, src))
+                        } else if hostWildcard {
+                                add("Content-Security-Policy", severityWarning, 5,
+                                        fmt.Sprintf("%s allows every subdomain through %s", directive, src))
+                        }
+                }
+        }
+}
+
+// wildcardSource classifies a CSP source expression: wildcard when it
+// allows any host ("*", or a bare scheme such as "https:" or "data:"), and
+// hostWildcard when it allows any subdomain of a host, as "*.example.com".
+func wildcardSource(src string) (wildcard, hostWildcard bool) {
+        lower := strings.ToLower(src)
+        switch lower {
+        case "*", "http:", "https:", "data:", "blob:", "ws:", "wss:", "filesystem:":
+                return true, false
+        }
+        if strings.HasPrefix(lower, "'") {
+                return false, false
+        }
+        if _, rest, ok := strings.Cut(lower, "://"); ok {
+                lower = rest
+        }
+        return lower == "*" || strings.HasPrefix(lower, "*:"), strings.HasPrefix(lower, "*.")
+}
+
+// lastToken returns the last comma-separated value; browsers use the last
+// valid Referrer-Policy token when several are sent.
+func lastToken(value string) string {
+        parts := strings.Split(value, ",")
+        return strings.TrimSpace(parts[len(parts)-1])
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+        "net/http"
+        "net/url"
+        "reflect"
+        "testing"
+)
+
+func TestAuditSecurityHeadersRedactsCredentials(t *testing.T) {
+        header := http.Header{}
+        header.Add("Set-Cookie", "session=abc123; Path=/; HttpOnly")
+        header.Add("Set-Cookie", "theme=dark")
+        header.Set("Authorization", "Bearer secret")
+        header.Set("X-Auth-Token", "t0k3n")
+        header.Set("X-Session-Id", "s3ss10n")
+        header.Set("Content-Type", "text/html")
+        header.Set("Public-Key-Pins", "pin-sha256=\"abc\"")
+        pageURL, _ := url.Parse("https://example.com/")
+
+        audit := (&Crawler{}).auditSecurityHeaders(header, pageURL)
+
+        want := map[string][]string{
+                "Set-Cookie":      {"session", "theme"},
+                "Authorization":   {"[redacted]"},
+                "X-Auth-Token":    {"[redacted]"},
+                "X-Session-Id":    {"[redacted]"},
+                "Content-Type":    {"text/html"},
+                "Public-Key-Pins": {"pin-sha256=\"abc\""},
+        }
+        if !reflect.DeepEqual(audit.Headers, want) {
+                t.Errorf("stored headers = %v, want %v", audit.Headers, want)
+        }
+
+        // The response itself is left alone
+        if got := header.Get("Authorization"); got != "Bearer secret" {
+                t.Errorf("response header changed to %q", got)
+        }
+}
@@ -0,0 +1,477 @@
+package services
+
+import (
+        "compress/gzip"
+        "encoding/xml"
+        "fmt"
+        "io"
+        "log"
+        "math"
+        "net/http"
+        "net/url"
+        "sort"
+        "strings"
+
+        "web-crawler/models"
+)
+
+const (
+        // deepClickDepth is the click depth beyond which a page counts as
+        // reachable only through a deep chain of links.
+        deepClickDepth = 3
+
+        pageRankDamping    = 0.85
+        pageRankIterations = 100
+        pageRankTolerance  = 1e-9
+
+        // maxSitemaps and maxSitemapURLs bound the sitemap discovery of an
+        // analysis, including nested sitemap indexes.
+        maxSitemaps    = 50
+        maxSitemapURLs = 50000
+        maxSitemapSize = 50 << 20
+)
+
+// AnalyzeSite starts an analysis of the internal link graph of the seed
+// URL's host and returns it while it runs in the background. The analysis
+// works from the links stored by earlier crawls of the site's pages: click
+// depth is measured from the seed, PageRank ignores nofollow links and pages
+// listed in the site's sitemap that no crawled page links to are reported as
+// orphans.
+func (c *Crawler) AnalyzeSite(seedURLID string) (*models.SiteAnalysis, error) {
+        seed, err := models.GetURLByID(c.db, seedURLID)
+        if err != nil {
+                return nil, err
+        }
+
+        // Links are stored under the address pages were served from, so the
+        // graph starts from where the seed redirected to
+        finalAddress, err := models.GetFinalURL(c.db, seedURLID)
+        if err != nil {
+                return nil, err
+        }
+        keys, err := newSiteKeys(seed.URL, finalAddress)
+        if err != nil {
+                return nil, err
+        }
+        seedURL, _ := url.Parse(keys.key(finalAddress))
+
+        analysis, err := models.StartSiteAnalysis(c.db, seedURLID, seedURL.Host)
+        if err != nil {
+                return nil, err
+        }
+
+        running := *analysis
+        go func() {
+                if err := c.analyzeSite(&running, seed, keys, seedURL); err != nil {
+                        log.Printf("Site analysis %s failed: %v", running.ID, err)
+                        if err := models.FailSiteAnalysis(c.db, running.ID, err.Error()); err != nil {
+                                log.Printf("Site analysis %s: failed to record error: %v", running.ID, err)
+                        }
+                }
+        }()
+
+        return analysis, nil
+}
+
+func (c *Crawler) analyzeSite(analysis *models.SiteAnalysis, seed *models.URL, keys *siteKeys, seedURL *url.URL) error {
+        var prefixes []string
+        for host := range keys.hosts {
+                prefixes = append(prefixes, "http://"+host+"/", "https://"+host+"/")
+        }
+        sort.Strings(prefixes)
+        links, err := models.GetSiteLinks(c.db, prefixes)
+        if err != nil {
+                return err
+        }
+
+        tracked, err := models.GetAllURLs(c.db)
+        if err != nil {
+                return err
+        }
+
+        graph := newSiteGraph()
+        graph.add(seedURL.String())
+        for _, l := range links {
+                l.SourceURL = keys.key(l.SourceURL)
+                l.TargetURL = keys.key(l.TargetURL)
+                graph.addLink(l)
+        }
+        for address, id := range tracked {
+                if id == seed.ID {
+                        graph.ids[seedURL.String()] = id
+                } else if keys.contains(address) {
+                        address = keys.key(address)
+                        graph.add(address)
+                        graph.ids[address] = id
+                }
+        }
+
+        // Fetch sitemaps the way the seed is crawled, with its DNS overrides
+        // and TLS settings
+        client, dialer := c.crawlClient(seed)
+        if dialer != nil {
+                defer client.CloseIdleConnections()
+        }
+        sitemap := make(map[string]bool)
+        for address := range fetchSitemapURLs(client, seedURL, keys) {
+                sitemap[keys.key(address)] = true
+        }
+        for address := range sitemap {
+                graph.add(address)
+        }
+
+        depths := graph.clickDepths(seedURL.String())
+        ranks := graph.pageRank()
+
+        maxRank := 0.0
+        for _, r := range ranks {
+                maxRank = math.Max(maxRank, r)
+        }
+
+        analysis.PageCount = len(graph.nodes)
+        analysis.SitemapURLs = len(sitemap)
+        analysis.Pages = make([]models.SiteAnalysisPage, 0, len(graph.nodes))
+
+        for i, address := range graph.nodes {
+                page := models.SiteAnalysisPage{
+                        URL:       address,
+                        Crawled:   graph.crawled[i],
+                        Inlinks:   len(graph.inlinks[i]),
+                        Outlinks:  len(graph.outlinks[i]),
+                        PageRank:  ranks[i],
+                        InSitemap: sitemap[address],
+                }
+                if id, ok := graph.ids[address]; ok {
+                        page.URLID = &id
+                }
+                if maxRank > 0 {
+                        page.Score = math.Round(ranks[i]/maxRank*10000) / 100
+                }
+
+                if depth, ok := depths[i]; ok {
+                        d := depth
+                        page.Depth = &d
+                        page.Deep = depth > deepClickDepth
+                        if depth > analysis.MaxDepth {
+                                analysis.MaxDepth = depth
+                        }
+                } else {
+                        analysis.Unreachable++
+                }
+                page.Orphan = page.InSitemap && page.Inlinks == 0 && address != seedURL.String()
+
+                if page.Crawled {
+                        analysis.CrawledCount++
+                }
+                if page.Deep {
+                        analysis.DeepPages++
+                }
+                if page.Orphan {
+                        analysis.OrphanPages++
+                }
+                analysis.Pages = append(analysis.Pages, page)
+        }
+
+        sort.SliceStable(analysis.Pages, func(i, j int) bool {
+                return analysis.Pages[i].Score > analysis.Pages[j].Score
+        })
+
+        return models.CompleteSiteAnalysis(c.db, analysis)
+}
+
+// siteKeys maps the addresses of a site's pages to the keys of its graph
+// nodes. Addresses are normalized, and on the hosts of the site, the host
+// the seed was tracked under, the one it redirected to and their www. and
+// bare counterparts, scheme and host are rewritten to those of the seed's
+// final address so variants of a page share one node.
+type siteKeys struct {
+        scheme string
+        host   string
+        hosts  map[string]bool
+}
+
+func newSiteKeys(seedAddress, finalAddress string) (*siteKeys, error) {
+        keys := &siteKeys{hosts: make(map[string]bool)}
+        for _, address := range []string{seedAddress, finalAddress} {
+                normalized, err := NormalizeURL(address)
+                if err != nil {
+                        return nil, fmt.Errorf("invalid seed URL %q: %v", address, err)
+                }
+                u, _ := url.Parse(normalized)
+                keys.scheme, keys.host = u.Scheme, u.Host
+                keys.hosts[u.Host] = true
+                keys.hosts[wwwCounterpart(u.Host)] = true
+        }
+        return keys, nil
+}
+
+// wwwCounterpart returns the bare host of a www. host and the www. host of a
+// bare one.
+func wwwCounterpart(host string) string {
+        if strings.HasPrefix(host, "www.") {
+                return strings.TrimPrefix(host, "www.")
+        }
+        return "www." + host
+}
+
+// key returns the node key of an address. Addresses that cannot be
+// normalized are kept as they are.
+func (k *siteKeys) key(address string) string {
+        normalized, err := NormalizeURL(address)
+        if err != nil {
+                return address
+        }
+        u, err := url.Parse(normalized)
+        if err != nil || !k.hosts[u.Host] {
+                return normalized
+        }
+        u.Scheme, u.Host = k.scheme, k.host
+        return u.String()
+}
+
+// contains reports whether an address is on one of the site's hosts.
+func (k *siteKeys) contains(address string) bool {
+        normalized, err := NormalizeURL(address)
+        if err != nil {
+                return false
+        }
+        u, err := url.Parse(normalized)
+        return err == nil && k.hosts[u.Host]
+}
+
+// siteGraph is the internal link graph of a site. Edges between the same
+// pair of pages are collapsed and self-links are ignored.
+type siteGraph struct {
+        nodes    []string
+        index    map[string]int
+        ids      map[string]string
+        crawled  []bool
+        outlinks []map[int]bool
+        inlinks  []map[int]bool
+        // followed holds the outlinks of each page without a nofollow rel.
+        followed []map[int]bool
+}
+
+func newSiteGraph() *siteGraph {
+        return &siteGraph{
+                index: make(map[string]int),
+                ids:   make(map[string]string),
+        }
+}
+
+func (g *siteGraph) add(address string) int {
+        if i, ok := g.index[address]; ok {
+                return i
+        }
+
+        g.index[address] = len(g.nodes)
+        g.nodes = append(g.nodes, address)
+        g.crawled = append(g.crawled, false)
+        g.outlinks = append(g.outlinks, make(map[int]bool))
+        g.inlinks = append(g.inlinks, make(map[int]bool))
+        g.followed = append(g.followed, make(map[int]bool))
+        return len(g.nodes) - 1
+}
+
+func (g *siteGraph) addLink(l models.SiteLink) {
+        source := g.add(l.SourceURL)
+        g.crawled[source] = true
+
+        target := g.add(l.TargetURL)
+        if source == target {
+                return
+        }
+
+        g.outlinks[source][target] = true
+        g.inlinks[target][source] = true
+        if !l.Nofollow {
+                g.followed[source][target] = true
+        }
+}
+
+// clickDepths returns the number of clicks needed to reach each page from
+// the seed. Unreachable pages are absent from the result.
+func (g *siteGraph) clickDepths(seed string) map[int]int {
+        start := g.index[seed]
+        depths := map[int]int{start: 0}
+
+        queue := []int{start}
+        for len(queue) > 0 {
+                current := queue[0]
+                queue = queue[1:]
+
+                for _, next := range sortedKeys(g.outlinks[current]) {
+                        if _, seen := depths[next]; !seen {
+                                depths[next] = depths[current] + 1
+                                queue = append(queue, next)
+                        }
+                }
+        }
+
+        return depths
+}
+
+// pageRank computes the PageRank of every page over the followed links.
+// The rank of pages without followed outlinks is spread over all pages so
+// the ranks keep summing to one.
+func (g *siteGraph) pageRank() []float64 {
+        n := len(g.nodes)
+        ranks := make([]float64, n)
+        if n == 0 {
+                return ranks
+        }
+        for i := range ranks {
+                ranks[i] = 1 / float64(n)
+        }
+
+        for iteration := 0; iteration < pageRankIterations; iteration++ {
+                next := make([]float64, n)
+                dangling := 0.0
+                for i, rank := range ranks {
+                        if len(g.followed[i]) == 0 {
+                                dangling += rank
+                                continue
+                        }
+                        share := rank / float64(len(g.followed[i]))
+                        for target := range g.followed[i] {
+                                next[target] += share
+                        }
+                }
+
+                delta := 0.0
+                base := (1-pageRankDamping)/float64(n) + pageRankDamping*dangling/float64(n)
+                for i := range next {
+                        next[i] = base + pageRankDamping*next[i]
+                        delta += math.Abs(next[i] - ranks[i])
+                }
+
+                ranks = next
+                if delta < pageRankTolerance {
+                        break
+                }
+        }
+
+        return ranks
+}
+
+func sortedKeys(set map[int]bool) []int {
+        keys := make([]int, 0, len(set))
+        for k := range set {
+                keys = append(keys, k)
+        }
+        sort.Ints(keys)
+        return keys
+}
+
+// sitemapDocument covers both sitemap files and sitemap indexes.
+type sitemapDocument struct {
+        URLs     []sitemapLoc `xml:"url"`
+        Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+        Loc string `xml:"loc"`
+}
+
+// fetchSitemapURLs returns the page URLs on the site's hosts listed in the
+// sitemaps declared in robots.txt, falling back to /sitemap.xml. Sitemaps
+// that cannot be fetched or parsed are skipped.
+func fetchSitemapURLs(client *http.Client, seed *url.URL, keys *siteKeys) map[string]bool {
+        root := seed.Scheme + "://" + seed.Host
+
+        queue := robotsSitemaps(client, root+"/robots.txt")
+        if len(queue) == 0 {
+                queue = []string{root + "/sitemap.xml"}
+        }
+
+        urls := make(map[string]bool)
+        seen := make(map[string]bool)
+        for len(queue) > 0 && len(seen) < maxSitemaps {
+                address := queue[0]
+                queue = queue[1:]
+                if seen[address] {
+                        continue
+                }
+                seen[address] = true
+
+                doc, err := fetchSitemap(client, address)
+                if err != nil {
+                        continue
+                }
+
+                for _, s := range doc.Sitemaps {
+                        queue = append(queue, strings.TrimSpace(s.Loc))
+                }
+                for _, u := range doc.URLs {
+                        if len(urls) >= maxSitemapURLs {
+                                return urls
+                        }
+                        page, err := url.Parse(strings.TrimSpace(u.Loc))
+                        if err != nil || !keys.contains(page.String()) {
+                                continue
+                        }
+                        page.Fragment = ""
+                        urls[page.String()] = true
+                }
+        }
+
+        return urls
+}
+
+func robotsSitemaps(client *http.Client, address string) []string {
+        resp, err := client.Get(address)
+        if err != nil {
+                return nil
+        }
+        defer resp.Body.Close()
+
+        if resp.StatusCode != http.StatusOK {
+                return nil
+        }
+
+        body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+        if err != nil {
+                return nil
+        }
+
+        var sitemaps []string
+        for _, line := range strings.Split(string(body), "\n") {
+                name, value, found := strings.Cut(line, ":")
+                if found && strings.EqualFold(strings.TrimSpace(name), "sitemap") {
+                        if value = strings.TrimSpace(value); value != "" {
+                                sitemaps = append(sitemaps, value)
+                        }
+                }
+        }
+
+        return sitemaps
+}
+
+func fetchSitemap(client *http.Client, address string) (*sitemapDocument, error) {
+        resp, err := client.Get(address)
+        if err != nil {
+                return nil, err
+        }
+        defer resp.Body.Close()
+
+        if resp.StatusCode != http.StatusOK {
+                return nil, fmt.Errorf("sitemap %s returned status %d", address, resp.StatusCode)
+        }
+
+        var body io.Reader = io.LimitReader(resp.Body, maxSitemapSize)
+        if strings.HasSuffix(strings.ToLower(address), ".gz") {
+                gz, err := gzip.NewReader(body)
+                if err != nil {
+                        return nil, err
+                }
+                defer gz.Close()
+                body = io.LimitReader(gz, maxSitemapSize)
+        }
+
+        var doc sitemapDocument
+        if err := xml.NewDecoder(body).Decode(&doc); err != nil {
+                return nil, err
+        }
+
+        return &doc, nil
+}
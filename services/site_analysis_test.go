@@ -0,0 +1,39 @@
+package services
+
+import "testing"
+
+func TestSiteKeys(t *testing.T) {
+        keys, err := newSiteKeys("http://example.com/", "https://www.example.com/")
+        if err != nil {
+                t.Fatal(err)
+        }
+
+        tests := []struct {
+                address  string
+                key      string
+                contains bool
+        }{
+                {"https://www.example.com/a", "https://www.example.com/a", true},
+                {"http://example.com/a", "https://www.example.com/a", true},
+                {"https://EXAMPLE.com:443/a#top", "https://www.example.com/a", true},
+                {"https://blog.example.com/a", "https://blog.example.com/a", false},
+                {"https://example.org/a", "https://example.org/a", false},
+        }
+        for _, tt := range tests {
+                if got := keys.key(tt.address); got != tt.key {
+                        t.Errorf("key(%q) = %q, want %q", tt.address, got, tt.key)
+                }
+                if got := keys.contains(tt.address); got != tt.contains {
+                        t.Errorf("contains(%q) = %v, want %v", tt.address, got, tt.contains)
+                }
+        }
+
+        // A bare seed host also covers its www. counterpart
+        keys, err = newSiteKeys("https://example.com/", "https://example.com/")
+        if err != nil {
+                t.Fatal(err)
+        }
+        if got := keys.key("https://www.example.com/a"); got != "https://example.com/a" {
+                t.Errorf("key of the www. host = %q, want https://example.com/a", got)
+        }
+}
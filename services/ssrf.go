@@ -0,0 +1,239 @@
+package services
+
+import (
+        "context"
+        "errors"
+        "fmt"
+        "log"
+        "net"
+        "net/url"
+        "os"
+        "strings"
+        "sync"
+        "syscall"
+        "time"
+
+        "golang.org/x/net/idna"
+)
+
+// maxURLLength is the longest URL accepted for tracking.
+const maxURLLength = 2048
+
+// allowedSchemes lists the URL schemes the crawler fetches.
+var allowedSchemes = map[string]bool{"http": true, "https": true}
+
+// blockedNetworks are the address ranges the crawler refuses to connect to
+// unless allowlisted: private, loopback, link-local (including cloud
+// metadata endpoints), carrier-grade NAT, multicast and reserved ranges.
+// The NAT64, 6to4 and Teredo prefixes are blocked whole since they can
+// embed any IPv4 address, private ones included.
+var blockedNetworks = mustParseCIDRs(
+        "0.0.0.0/8",
+        "10.0.0.0/8",
+        "100.64.0.0/10",
+        "127.0.0.0/8",
+        "169.254.0.0/16",
+        "172.16.0.0/12",
+        "192.0.0.0/24",
+        "192.168.0.0/16",
+        "198.18.0.0/15",
+        "224.0.0.0/4",
+        "240.0.0.0/4",
+        "::/128",
+        "::1/128",
+        "64:ff9b::/96",
+        "2001::/32",
+        "2002::/16",
+        "fc00::/7",
+        "fe80::/10",
+        "ff00::/8",
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+        networks := make([]*net.IPNet, 0, len(cidrs))
+        for _, cidr := range cidrs {
+                _, network, err := net.ParseCIDR(cidr)
+                if err != nil {
+                        panic(err)
+                }
+                networks = append(networks, network)
+        }
+        return networks
+}
+
+// TargetPolicy decides which hosts and addresses the crawler may connect
+// to. Allowlisted hosts and networks are internal targets audited on
+// purpose and bypass the blocked ranges.
+type TargetPolicy struct {
+        allowedHosts    map[string]bool
+        allowedNetworks []*net.IPNet
+}
+
+// NewTargetPolicy builds a policy from CRAWL_ALLOWLIST, a comma-separated
+// list of hostnames, IP addresses and CIDR ranges.
+func NewTargetPolicy() *TargetPolicy {
+        policy := &TargetPolicy{allowedHosts: make(map[string]bool)}
+
+        for _, entry := range strings.Split(os.Getenv("CRAWL_ALLOWLIST"), ",") {
+                entry = strings.ToLower(strings.TrimSpace(entry))
+                if entry == "" {
+                        continue
+                }
+
+                if _, network, err := net.ParseCIDR(entry); err == nil {
+                        policy.allowedNetworks = append(policy.allowedNetworks, network)
+                } else if ip := net.ParseIP(entry); ip != nil {
+                        policy.allowedNetworks = append(policy.allowedNetworks, singleIPNetwork(ip))
+                } else if host, err := idna.Lookup.ToASCII(entry); err == nil {
+                        policy.allowedHosts[host] = true
+                } else {
+                        log.Printf("Ignoring invalid CRAWL_ALLOWLIST entry %q", entry)
+                }
+        }
+
+        return policy
+}
+
+func singleIPNetwork(ip net.IP) *net.IPNet {
+        if ip4 := ip.To4(); ip4 != nil {
+                return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+        }
+        return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+var (
+        defaultTargetPolicy     *TargetPolicy
+        defaultTargetPolicyOnce sync.Once
+)
+
+// DefaultTargetPolicy returns the policy configured through the
+// environment.
+func DefaultTargetPolicy() *TargetPolicy {
+        defaultTargetPolicyOnce.Do(func() {
+                defaultTargetPolicy = NewTargetPolicy()
+        })
+        return defaultTargetPolicy
+}
+
+// CheckIP returns an error when connections to the address are blocked.
+func (p *TargetPolicy) CheckIP(ip net.IP) error {
+        if ip4 := ip.To4(); ip4 != nil {
+                ip = ip4
+        }
+
+        for _, network := range p.allowedNetworks {
+                if network.Contains(ip) {
+                        return nil
+                }
+        }
+        for _, network := range blockedNetworks {
+                if network.Contains(ip) {
+                        return fmt.Errorf("connections to %s are blocked (%s)", ip, network)
+                }
+        }
+        return nil
+}
+
+func (p *TargetPolicy) allowsHost(host string) bool {
+        return p.allowedHosts[strings.TrimSuffix(strings.ToLower(host), ".")]
+}
+
+// DialContext wraps a dialer so every connection is checked against the
+// policy after DNS resolution, which also covers redirects and hostnames
+// resolving to internal addresses. Allowlisted hostnames are dialed
+// without checks.
+func (p *TargetPolicy) DialContext(dialer *net.Dialer) func(ctx context.Context, network, address string) (net.Conn, error) {
+        return func(ctx context.Context, network, address string) (net.Conn, error) {
+                host, _, err := net.SplitHostPort(address)
+                if err != nil {
+                        return nil, err
+                }
+                if p.allowsHost(host) {
+                        return dialer.DialContext(ctx, network, address)
+                }
+                return p.guard(dialer).DialContext(ctx, network, address)
+        }
+}
+
+// guard returns a copy of the dialer that checks the address of every
+// connection attempt against the policy.
+func (p *TargetPolicy) guard(dialer *net.Dialer) *net.Dialer {
+        guarded := *dialer
+        guarded.Control = func(network, address string, conn syscall.RawConn) error {
+                host, _, err := net.SplitHostPort(address)
+                if err != nil {
+                        return err
+                }
+                ip := net.ParseIP(host)
+                if ip == nil {
+                        return fmt.Errorf("unexpected dial address %s", address)
+                }
+                return p.CheckIP(ip)
+        }
+        return &guarded
+}
+
+// newDialer returns the dialer settings of the default transport.
+func newDialer() *net.Dialer {
+        return &net.Dialer{
+                Timeout:   30 * time.Second,
+                KeepAlive: 30 * time.Second,
+        }
+}
+
+// ValidateURL checks a URL before it is tracked: an allowed scheme, a host
+// that is a valid IP address or domain name (IDNs are checked in their
+// punycode form) and, for IP literals and localhost, a target the policy
+// allows.
+func (p *TargetPolicy) ValidateURL(raw string) error {
+        raw = strings.TrimSpace(raw)
+        if len(raw) > maxURLLength {
+                return fmt.Errorf("URL is longer than %d characters", maxURLLength)
+        }
+
+        u, err := url.Parse(raw)
+        if err != nil {
+                return err
+        }
+        if !allowedSchemes[strings.ToLower(u.Scheme)] {
+                return fmt.Errorf("scheme %q is not allowed, use http or https", u.Scheme)
+        }
+        if u.Opaque != "" || u.Host == "" {
+                return errors.New("URL must be absolute with a host")
+        }
+
+        host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+        if port := u.Port(); port != "" {
+                if _, err := net.LookupPort("tcp", port); err != nil {
+                        return fmt.Errorf("invalid port %q", port)
+                }
+        }
+        if p.allowsHost(host) {
+                return nil
+        }
+
+        if ip := net.ParseIP(host); ip != nil {
+                return p.CheckIP(ip)
+        }
+        if strings.HasPrefix(u.Host, "[") {
+                return fmt.Errorf("invalid IPv6 address %q", host)
+        }
+
+        ascii, err := idna.Lookup.ToASCII(host)
+        if err != nil {
+                return fmt.Errorf("invalid host %q: %v", host, err)
+        }
+        if ascii == "localhost" || strings.HasSuffix(ascii, ".localhost") {
+                return fmt.Errorf("host %q is not allowed", host)
+        }
+        if !strings.Contains(ascii, ".") {
+                return fmt.Errorf("host %q is not a fully qualified domain name", host)
+        }
+
+        return nil
+}
+
+// ValidateURL validates a URL with the default target policy.
+func ValidateURL(raw string) error {
+        return DefaultTargetPolicy().ValidateURL(raw)
+}
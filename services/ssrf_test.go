@@ -0,0 +1,25 @@
+package services
+
+import (
+        "net"
+        "testing"
+)
+
+func TestCheckIPBlocksEmbeddedIPv4(t *testing.T) {
+        policy := &TargetPolicy{allowedHosts: make(map[string]bool)}
+
+        for _, addr := range []string{
+                "64:ff9b::a00:1",                       // NAT64 of 10.0.0.1
+                "2002:a9fe:a9fe::1",                    // 6to4 of 169.254.169.254
+                "2001:0:4136:e378:8000:63bf:3fff:fdd2", // Teredo
+                "::ffff:127.0.0.1",
+        } {
+                if err := policy.CheckIP(net.ParseIP(addr)); err == nil {
+                        t.Errorf("CheckIP(%s) allowed the connection", addr)
+                }
+        }
+
+        if err := policy.CheckIP(net.ParseIP("2606:4700::1111")); err != nil {
+                t.Errorf("CheckIP(2606:4700::1111) = %v, want nil", err)
+        }
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+        "encoding/json"
+        "fmt"
+        "strings"
+
+        "github.com/PuerkitoBio/goquery"
+        "web-crawler/models"
+)
+
+// requiredProperties lists the schema.org properties checked for the types we
+// validate. Alternatives separated by "|" mean at least one must be present.
+var requiredProperties = map[string][]string{
+        "Article":        {"headline", "author", "datePublished"},
+        "NewsArticle":    {"headline", "author", "datePublished"},
+        "BlogPosting":    {"headline", "author", "datePublished"},
+        "Product":        {"name", "offers|review|aggregateRating"},
+        "BreadcrumbList": {"itemListElement"},
+        "Organization":   {"name", "url"},
+}
+
+// extractStructuredData collects JSON-LD, Microdata and RDFa entities from
+// the document and validates the ones with a known schema.org type.
+func (c *Crawler) extractStructuredData(doc *goquery.Document) []models.StructuredData {
+        var items []models.StructuredData
+
+        doc.Find(`script[type="application/ld+json"]`).Each(func(i int, s *goquery.Selection) {
+                items = append(items, parseJSONLD(s.Text())...)
+        })
+
+        // Top-level Microdata items are those not used as a property of another item
+        doc.Find("[itemscope]").Not("[itemprop]").Each(func(i int, s *goquery.Selection) {
+                props := parseMicrodataItem(s)
+                items = append(items, newStructuredData("microdata", props))
+        })
+
+        // Same for RDFa: nested typed resources are stored inside their parent
+        doc.Find("[typeof]").Not("[property]").Each(func(i int, s *goquery.Selection) {
+                props := parseRDFaItem(s)
+                items = append(items, newStructuredData("rdfa", props))
+        })
+
+        return items
+}
+
+func countStructuredDataErrors(items []models.StructuredData) int {
+        count := 0
+        for _, item := range items {
+                count += len(item.Errors)
+        }
+        return count
+}
+
+func parseJSONLD(raw string) []models.StructuredData {
+        var parsed interface{}
+        if err := json.Unmarshal([]byte(strings.TrimSpace(raw)), &parsed); err != nil {
+                data, _ := json.Marshal(raw)
+                return []models.StructuredData{{
+                        Format: "json-ld",
+                        Data:   data,
+                        Errors: []string{fmt.Sprintf("invalid JSON: %v", err)},
+                }}
+        }
+
+        var items []models.StructuredData
+        for _, entity := range flattenJSONLD(parsed) {
+                items = append(items, newStructuredData("json-ld", entity))
+        }
+        return items
+}
+
+// flattenJSONLD expands top-level arrays and @graph containers into the
+// individual entities they hold.
+func flattenJSONLD(value interface{}) []map[string]interface{} {
+        var entities []map[string]interface{}
+
+        switch v := value.(type) {
+        case []interface{}:
+                for _, item := range v {
+                        entities = append(entities, flattenJSONLD(item)...)
+                }
+        case map[string]interface{}:
+                graph, ok := v["@graph"].([]interface{})
+                if !ok {
+                        entities = append(entities, v)
+                        break
+                }
+                for _, item := range graph {
+                        entity, ok := item.(map[string]interface{})
+                        if !ok {
+                                continue
+                        }
+                        if _, has := entity["@context"]; !has && v["@context"] != nil {
+                                entity["@context"] = v["@context"]
+                        }
+                        entities = append(entities, entity)
+                }
+        }
+
+        return entities
+}
+
+func newStructuredData(format string, props map[string]interface{}) models.StructuredData {
+        typ := entityType(props["@type"])
+        data, err := json.Marshal(props)
+        if err != nil {
+                data = []byte("{}")
+        }
+
+        return models.StructuredData{
+                Format: format,
+                Type:   typ,
+                Data:   data,
+                Errors: validateEntity(typ, props),
+        }
+}
+
+func entityType(value interface{}) string {
+        switch v := value.(type) {
+        case string:
+                return schemaTypeName(v)
+        case []interface{}:
+                var names []string
+                for _, item := range v {
+                        if s, ok := item.(string); ok {
+                                names = append(names, schemaTypeName(s))
+                        }
+                }
+                return strings.Join(names, ",")
+        }
+        return ""
+}
+
+// schemaTypeName turns "https://schema.org/Product" or "schema:Product" into
+// the bare type name.
+func schemaTypeName(typ string) string {
+        typ = strings.TrimSpace(typ)
+        if i := strings.LastIndexAny(typ, "/#:"); i >= 0 {
+                typ = typ[i+1:]
+        }
+        return typ
+}
+
+func validateEntity(typ string, props map[string]interface{}) []string {
+        var errs []string
+
+        for _, name := range strings.Split(typ, ",") {
+                required, ok := requiredProperties[name]
+                if !ok {
+                        continue
+                }
+                for _, prop := range required {
+                        if !hasAnyProperty(props, strings.Split(prop, "|")) {
+                                errs = append(errs, fmt.Sprintf("%s is missing required property %s",
+                                        name, strings.ReplaceAll(prop, "|", " or ")))
+                        }
+                }
+        }
+
+        return errs
+}
+
+func hasAnyProperty(props map[string]interface{}, names []string) bool {
+        for _, name := range names {
+                value, ok := props[name]
+                if !ok || value == nil {
+                        continue
+                }
+                if s, isString := value.(string); isString && strings.TrimSpace(s) == "" {
+                        continue
+                }
+                return true
+        }
+        return false
+}
+
+func parseMicrodataItem(item *goquery.Selection) map[string]interface{} {
+        props := make(map[string]interface{})
+        if itemType, ok := item.Attr("itemtype"); ok {
+                // itemtype may hold several space-separated types
+                var types []interface{}
+                for _, t := range strings.Fields(itemType) {
+                        types = append(types, t)
+                }
+                if len(types) == 1 {
+                        props["@type"] = types[0]
+                } else if len(types) > 1 {
+                        props["@type"] = types
+                }
+        }
+        if id, ok := item.Attr("itemid"); ok {
+                props["@id"] = id
+        }
+
+        item.Find("[itemprop]").Each(func(i int, s *goquery.Selection) {
+                // Only take properties that belong to this item, not a nested one
+                if !s.Parent().Closest("[itemscope]").IsSelection(item) {
+                        return
+                }
+
+                var value interface{}
+                if _, nested := s.Attr("itemscope"); nested {
+                        value = parseMicrodataItem(s)
+                } else {
+                        value = elementValue(s)
+                }
+                for _, name := range strings.Fields(s.AttrOr("itemprop", "")) {
+                        addProperty(props, name, value)
+                }
+        })
+
+        return props
+}
+
+func parseRDFaItem(item *goquery.Selection) map[string]interface{} {
+        props := make(map[string]interface{})
+        var types []interface{}
+        for _, t := range strings.Fields(item.AttrOr("typeof", "")) {
+                types = append(types, t)
+        }
+        if len(types) == 1 {
+                props["@type"] = types[0]
+        } else if len(types) > 1 {
+                props["@type"] = types
+        }
+        if resource, ok := item.Attr("resource"); ok {
+                props["@id"] = resource
+        }
+
+        item.Find("[property]").Each(func(i int, s *goquery.Selection) {
+                if !s.Parent().Closest("[typeof]").IsSelection(item) {
+                        return
+                }
+
+                var value interface{}
+                if _, nested := s.Attr("typeof"); nested {
+                        value = parseRDFaItem(s)
+                } else if content, ok := s.Attr("content"); ok {
+                        value = content
+                } else if resource, ok := s.Attr("resource"); ok {
+                        value = resource
+                } else {
+                        value = elementValue(s)
+                }
+                for _, name := range strings.Fields(s.AttrOr("property", "")) {
+                        addProperty(props, schemaTypeName(name), value)
+                }
+        })
+
+        return props
+}
+
+// elementValue returns the property value of a Microdata or RDFa element
+// following the Microdata rules for which attribute carries the value.
+func elementValue(s *goquery.Selection) string {
+        attrByTag := map[string]string{
+                "meta":   "content",
+                "a":      "href",
+                "link":   "href",
+                "area":   "href",
+                "img":    "src",
+                "audio":  "src",
+                "video":  "src",
+                "source": "src",
+                "iframe": "src",
+                "embed":  "src",
+                "object": "data",
+                "time":   "datetime",
+                "data":   "value",
+                "meter":  "value",
+        }
+
+        if attr, ok := attrByTag[goquery.NodeName(s)]; ok {
+                if value, exists := s.Attr(attr); exists {
+                        return value
+                }
+        }
+        if content, ok := s.Attr("content"); ok {
+                return content
+        }
+        return collapseWhitespace(s.Text())
+}
+
+func addProperty(props map[string]interface{}, name string, value interface{}) {
+        existing, ok := props[name]
+        if !ok {
+                props[name] = value
+                return
+        }
+        if list, isList := existing.([]interface{}); isList {
+                props[name] = append(list, value)
+                return
+        }
+        props[name] = []interface{}{existing, value}
+}
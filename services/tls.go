@@ -0,0 +1,184 @@
+package services
+
+import (
+        "context"
+        "crypto/tls"
+        "crypto/x509"
+        "fmt"
+        "log"
+        "net"
+        "net/http"
+        "os"
+        "path"
+        "strings"
+        "sync"
+)
+
+// clientCertificate is a client certificate presented to hosts matching a
+// glob pattern such as *.corp.example.com.
+type clientCertificate struct {
+        pattern     string
+        certificate tls.Certificate
+}
+
+// tlsSettings is the TLS configuration shared by every crawl: extra trusted
+// CAs and client certificates for mutual TLS.
+type tlsSettings struct {
+        rootCAs            *x509.CertPool
+        clientCertificates []clientCertificate
+}
+
+var (
+        defaultTLSSettings     *tlsSettings
+        defaultTLSSettingsOnce sync.Once
+)
+
+// DefaultTLSSettings returns the settings configured through the
+// environment. TLS_CA_BUNDLE names a PEM file of CA certificates trusted in
+// addition to the system roots. TLS_CLIENT_CERTS lists client certificates
+// as PATTERN=CERT_FILE:KEY_FILE entries separated by semicolons. Invalid
+// entries are logged and skipped.
+func DefaultTLSSettings() *tlsSettings {
+        defaultTLSSettingsOnce.Do(func() {
+                defaultTLSSettings = loadTLSSettings(os.Getenv("TLS_CA_BUNDLE"), os.Getenv("TLS_CLIENT_CERTS"))
+        })
+        return defaultTLSSettings
+}
+
+func loadTLSSettings(caBundle, clientCerts string) *tlsSettings {
+        settings := &tlsSettings{}
+
+        if caBundle != "" {
+                pool, err := loadCABundle(caBundle)
+                if err != nil {
+                        log.Printf("Ignoring TLS_CA_BUNDLE: %v", err)
+                } else {
+                        settings.rootCAs = pool
+                }
+        }
+
+        for _, entry := range strings.Split(clientCerts, ";") {
+                entry = strings.TrimSpace(entry)
+                if entry == "" {
+                        continue
+                }
+
+                cert, err := parseClientCertificate(entry)
+                if err != nil {
+                        log.Printf("Ignoring TLS_CLIENT_CERTS entry %q: %v", entry, err)
+                        continue
+                }
+                settings.clientCertificates = append(settings.clientCertificates, cert)
+        }
+
+        return settings
+}
+
+// loadCABundle returns the system roots extended with the certificates of a
+// PEM bundle.
+func loadCABundle(file string) (*x509.CertPool, error) {
+        pem, err := os.ReadFile(file)
+        if err != nil {
+                return nil, err
+        }
+
+        pool, err := x509.SystemCertPool()
+        if err != nil || pool == nil {
+                pool = x509.NewCertPool()
+        }
+        if !pool.AppendCertsFromPEM(pem) {
+                return nil, fmt.Errorf("no certificates found in %s", file)
+        }
+        return pool, nil
+}
+
+func parseClientCertificate(entry string) (clientCertificate, error) {
+        pattern, files, found := strings.Cut(entry, "=")
+        if !found {
+                return clientCertificate{}, fmt.Errorf("expected PATTERN=CERT_FILE:KEY_FILE")
+        }
+        certFile, keyFile, found := strings.Cut(files, ":")
+        if !found {
+                return clientCertificate{}, fmt.Errorf("expected PATTERN=CERT_FILE:KEY_FILE")
+        }
+
+        pattern = strings.ToLower(strings.TrimSpace(pattern))
+        if _, err := path.Match(pattern, ""); err != nil {
+                return clientCertificate{}, fmt.Errorf("invalid host pattern: %v", err)
+        }
+
+        certificate, err := tls.LoadX509KeyPair(strings.TrimSpace(certFile), strings.TrimSpace(keyFile))
+        if err != nil {
+                return clientCertificate{}, err
+        }
+
+        return clientCertificate{pattern: pattern, certificate: certificate}, nil
+}
+
+// tlsConfig returns the client TLS configuration, optionally accepting
+// invalid server certificates.
+func (s *tlsSettings) tlsConfig(insecure bool) *tls.Config {
+        return &tls.Config{
+                RootCAs:            s.rootCAs,
+                InsecureSkipVerify: insecure,
+        }
+}
+
+// newTransport builds a crawler transport from the default transport. The
+// dial function decides where connections go and insecure skips server
+// certificate verification. Hosts matching a client certificate pattern
+// get a transport of their own presenting that certificate. Proxies from
+// the environment are never used: the dialer would only check the proxy's
+// address while the proxy resolves and fetches blocked targets itself.
+func newTransport(dial func(ctx context.Context, network, address string) (net.Conn, error), insecure bool) http.RoundTripper {
+        settings := DefaultTLSSettings()
+
+        base := http.DefaultTransport.(*http.Transport).Clone()
+        base.DialContext = dial
+        base.Proxy = nil
+        base.TLSClientConfig = settings.tlsConfig(insecure)
+
+        if len(settings.clientCertificates) == 0 {
+                return base
+        }
+
+        router := &certificateRouter{base: base}
+        for _, cert := range settings.clientCertificates {
+                transport := base.Clone()
+                transport.TLSClientConfig.Certificates = []tls.Certificate{cert.certificate}
+                router.routes = append(router.routes, certificateRoute{pattern: cert.pattern, transport: transport})
+        }
+        return router
+}
+
+type certificateRoute struct {
+        pattern   string
+        transport *http.Transport
+}
+
+// certificateRouter sends each request through the transport presenting
+// the client certificate of the first pattern matching its host, or the
+// base transport when none matches. Each transport keeps its own
+// connection pool, so connections made with a certificate are never
+// reused for other hosts.
+type certificateRouter struct {
+        base   *http.Transport
+        routes []certificateRoute
+}
+
+func (r *certificateRouter) RoundTrip(req *http.Request) (*http.Response, error) {
+        host := strings.ToLower(req.URL.Hostname())
+        for _, route := range r.routes {
+                if matched, _ := path.Match(route.pattern, host); matched {
+                        return route.transport.RoundTrip(req)
+                }
+        }
+        return r.base.RoundTrip(req)
+}
+
+func (r *certificateRouter) CloseIdleConnections() {
+        r.base.CloseIdleConnections()
+        for _, route := range r.routes {
+                route.transport.CloseIdleConnections()
+        }
+}
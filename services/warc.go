@@ -0,0 +1,153 @@
+package services
+
+import (
+        "bufio"
+        "bytes"
+        "compress/gzip"
+        "crypto/sha1"
+        "encoding/base32"
+        "fmt"
+        "io"
+        "net/http"
+        "net/textproto"
+        "sort"
+        "strings"
+        "time"
+
+        "github.com/google/uuid"
+        "web-crawler/models"
+)
+
+// WARCWriter writes snapshots as WARC 1.1 records. With Gzip set every
+// record is its own gzip member, as expected for .warc.gz files.
+type WARCWriter struct {
+        w          io.Writer
+        gzip       bool
+        warcinfoID string
+}
+
+func NewWARCWriter(w io.Writer, gzip bool) *WARCWriter {
+        return &WARCWriter{w: w, gzip: gzip}
+}
+
+// WriteWarcinfo writes the warcinfo record describing the file. It must be
+// called before any response record.
+func (ww *WARCWriter) WriteWarcinfo(filename string) error {
+        ww.warcinfoID = newRecordID()
+
+        fields := "software: web-crawler\r\n" +
+                "format: WARC File Format 1.1\r\n" +
+                "conformsTo: http://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/\r\n"
+
+        headers := [][2]string{
+                {"WARC-Type", "warcinfo"},
+                {"WARC-Record-ID", ww.warcinfoID},
+                {"WARC-Date", warcDate(time.Now())},
+                {"WARC-Filename", filename},
+                {"Content-Type", "application/warc-fields"},
+        }
+        return ww.writeRecord(headers, []byte(fields))
+}
+
+// WriteResponse writes a snapshot as a response record. Bodies are archived
+// decoded, so the stored Content-Encoding is dropped and Content-Length
+// rewritten to keep the HTTP message consistent.
+func (ww *WARCWriter) WriteResponse(snapshot models.Snapshot, body []byte) error {
+        var block bytes.Buffer
+        fmt.Fprintf(&block, "%s %s\r\n", snapshot.Protocol, snapshot.Status)
+
+        header := parseSnapshotHeaders(snapshot.Headers)
+        header.Del("Content-Encoding")
+        header.Del("Transfer-Encoding")
+        header.Set("Content-Length", fmt.Sprint(len(body)))
+        writeSortedHeaders(&block, header)
+        block.WriteString("\r\n")
+        block.Write(body)
+
+        headers := [][2]string{
+                {"WARC-Type", "response"},
+                {"WARC-Record-ID", newRecordID()},
+                {"WARC-Date", warcDate(snapshot.FetchedAt)},
+                {"WARC-Target-URI", snapshot.TargetURL},
+                {"WARC-Payload-Digest", warcDigest(body)},
+                {"WARC-Block-Digest", warcDigest(block.Bytes())},
+                {"Content-Type", "application/http;msgtype=response"},
+        }
+        if ww.warcinfoID != "" {
+                headers = append(headers, [2]string{"WARC-Warcinfo-ID", ww.warcinfoID})
+        }
+        return ww.writeRecord(headers, block.Bytes())
+}
+
+func (ww *WARCWriter) writeRecord(headers [][2]string, block []byte) error {
+        var record bytes.Buffer
+        record.WriteString("WARC/1.1\r\n")
+        for _, h := range headers {
+                fmt.Fprintf(&record, "%s: %s\r\n", h[0], h[1])
+        }
+        fmt.Fprintf(&record, "Content-Length: %d\r\n\r\n", len(block))
+        record.Write(block)
+        record.WriteString("\r\n\r\n")
+
+        if !ww.gzip {
+                _, err := ww.w.Write(record.Bytes())
+                return err
+        }
+
+        gz := gzip.NewWriter(ww.w)
+        if _, err := gz.Write(record.Bytes()); err != nil {
+                return err
+        }
+        return gz.Close()
+}
+
+// formatSnapshotHeaders serialises response headers the way they appear on
+// the wire, sorted by name since http.Header does not keep the order.
+func formatSnapshotHeaders(header http.Header) string {
+        var b bytes.Buffer
+        writeSortedHeaders(&b, header)
+        return b.String()
+}
+
+// SnapshotContentType returns the Content-Type the snapshot was served with.
+func SnapshotContentType(snapshot models.Snapshot) string {
+        if contentType := parseSnapshotHeaders(snapshot.Headers).Get("Content-Type"); contentType != "" {
+                return contentType
+        }
+        return "application/octet-stream"
+}
+
+func parseSnapshotHeaders(raw string) http.Header {
+        reader := textproto.NewReader(bufio.NewReader(strings.NewReader(raw + "\r\n")))
+        header, err := reader.ReadMIMEHeader()
+        if err != nil && len(header) == 0 {
+                return http.Header{}
+        }
+        return http.Header(header)
+}
+
+func writeSortedHeaders(w io.Writer, header http.Header) {
+        names := make([]string, 0, len(header))
+        for name := range header {
+                names = append(names, name)
+        }
+        sort.Strings(names)
+        for _, name := range names {
+                for _, value := range header[name] {
+                        fmt.Fprintf(w, "%s: %s\r\n", name, strings.ReplaceAll(value, "\n", " "))
+                }
+        }
+}
+
+func newRecordID() string {
+        return "<urn:uuid:" + uuid.New().String() + ">"
+}
+
+func warcDate(t time.Time) string {
+        return t.UTC().Format("2006-01-02T15:04:05Z")
+}
+
+func warcDigest(data []byte) string {
+        sum := sha1.Sum(data)
+        return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
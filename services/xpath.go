@@ -0,0 +1,718 @@
+package services
+
+import (
+        "fmt"
+        "sort"
+        "strconv"
+        "strings"
+        "unicode"
+
+        "golang.org/x/net/html"
+)
+
+// xpathExpression is a compiled location path from the XPath subset used by
+// extraction rules. It supports:
+//
+//   - absolute and relative paths with "/" and "//" steps
+//   - element names, "*", ".", "..", "@attr", "@*", "text()" and "node()"
+//   - predicates with positions, last(), position(), "=", "!=", "and", "or",
+//     not(), contains(), starts-with(), normalize-space() and text()
+//   - predicates on a parenthesized path, as in "(//li)[1]"
+//
+// A path may also be wrapped in string() or normalize-space().
+type xpathExpression struct {
+        absolute   bool
+        steps      []xpathStep
+        predicates []xpathExpr
+        normalize  bool
+}
+
+type xpathStep struct {
+        descendant bool
+        axis       string // "child", "attribute", "self" or "parent"
+        test       string // element or attribute name, "*", "text()" or "node()"
+        predicates []xpathExpr
+}
+
+// xpathItem is a node selected by a path, or one of its attributes.
+type xpathItem struct {
+        node *html.Node
+        attr *html.Attribute
+}
+
+func (i xpathItem) stringValue() string {
+        if i.attr != nil {
+                return i.attr.Val
+        }
+        if i.node.Type == html.TextNode {
+                return i.node.Data
+        }
+        var b strings.Builder
+        var walk func(*html.Node)
+        walk = func(n *html.Node) {
+                if n.Type == html.TextNode {
+                        b.WriteString(n.Data)
+                }
+                for c := n.FirstChild; c != nil; c = c.NextSibling {
+                        walk(c)
+                }
+        }
+        walk(i.node)
+        return b.String()
+}
+
+// compileXPath parses an XPath expression.
+func compileXPath(expr string) (*xpathExpression, error) {
+        p := &xpathParser{input: strings.TrimSpace(expr)}
+
+        result := &xpathExpression{}
+        for _, fn := range []string{"string", "normalize-space"} {
+                if strings.HasPrefix(p.input, fn+"(") && strings.HasSuffix(p.input, ")") {
+                        p.input = strings.TrimSpace(p.input[len(fn)+1 : len(p.input)-1])
+                        result.normalize = fn == "normalize-space"
+                        break
+                }
+        }
+
+        grouped := p.consume("(")
+        path, err := p.parsePath()
+        if err != nil {
+                return nil, err
+        }
+        if grouped {
+                if !p.consume(")") {
+                        return nil, fmt.Errorf("expected ) at position %d", p.pos)
+                }
+                if result.predicates, err = p.parsePredicates(); err != nil {
+                        return nil, err
+                }
+        }
+        p.skipSpace()
+        if p.pos < len(p.input) {
+                return nil, fmt.Errorf("unexpected %q at position %d", p.input[p.pos:], p.pos)
+        }
+        result.absolute = path.absolute
+        result.steps = path.steps
+        return result, nil
+}
+
+// evaluate returns the string value of every item the path selects from
+// root, in document order.
+func (x *xpathExpression) evaluate(root *html.Node) []string {
+        order := newXPathOrder(root)
+        items := selectPath(order, root, x.absolute, x.steps)
+        items = filterItems(order, items, x.predicates)
+
+        values := make([]string, 0, len(items))
+        for _, item := range items {
+                value := item.stringValue()
+                if x.normalize {
+                        value = collapseWhitespace(value)
+                }
+                values = append(values, value)
+        }
+        return values
+}
+
+// xpathOrder numbers the nodes of a document in document order. It is
+// built on first use and shared by every path evaluated against the
+// document, predicates included.
+type xpathOrder struct {
+        root  *html.Node
+        spans map[*html.Node][2]int // first and past-the-last index of the subtree
+}
+
+func newXPathOrder(node *html.Node) *xpathOrder {
+        for node.Parent != nil {
+                node = node.Parent
+        }
+        return &xpathOrder{root: node}
+}
+
+func (o *xpathOrder) span(node *html.Node) [2]int {
+        if o.spans == nil {
+                o.spans = make(map[*html.Node][2]int)
+                next := 0
+                var walk func(*html.Node)
+                walk = func(n *html.Node) {
+                        start := next
+                        next++
+                        for c := n.FirstChild; c != nil; c = c.NextSibling {
+                                walk(c)
+                        }
+                        o.spans[n] = [2]int{start, next}
+                }
+                walk(o.root)
+        }
+        return o.spans[node]
+}
+
+// sort puts items in document order: an element, then its attributes in
+// the order they appear, then its descendants.
+func (o *xpathOrder) sort(items []xpathItem) {
+        key := func(item xpathItem) (int, int) {
+                attr := 0
+                if item.attr != nil {
+                        for i := range item.node.Attr {
+                                if &item.node.Attr[i] == item.attr {
+                                        attr = i + 1
+                                        break
+                                }
+                        }
+                }
+                return o.span(item.node)[0], attr
+        }
+        sort.SliceStable(items, func(i, j int) bool {
+                ni, ai := key(items[i])
+                nj, aj := key(items[j])
+                if ni != nj {
+                        return ni < nj
+                }
+                return ai < aj
+        })
+}
+
+func selectPath(order *xpathOrder, context *html.Node, absolute bool, steps []xpathStep) []xpathItem {
+        start := context
+        if absolute {
+                for start.Parent != nil {
+                        start = start.Parent
+                }
+        }
+
+        items := []xpathItem{{node: start}}
+        for _, step := range steps {
+                contexts := items
+                if step.descendant {
+                        contexts = outermostItems(order, items)
+                }
+
+                var next []xpathItem
+                seen := make(map[xpathItem]bool)
+                for _, item := range contexts {
+                        if item.attr != nil {
+                                continue
+                        }
+                        for _, selected := range step.apply(order, item.node) {
+                                if !seen[selected] {
+                                        seen[selected] = true
+                                        next = append(next, selected)
+                                }
+                        }
+                }
+                if len(next) > 1 && (len(contexts) > 1 || step.descendant) {
+                        order.sort(next)
+                }
+                items = next
+        }
+        return items
+}
+
+// outermostItems drops the items lying inside the subtree of an earlier
+// item. A descendant step from the outer item already visits them, so each
+// subtree is walked once. Items must be in document order.
+func outermostItems(order *xpathOrder, items []xpathItem) []xpathItem {
+        if len(items) < 2 {
+                return items
+        }
+
+        var result []xpathItem
+        end := -1
+        for _, item := range items {
+                if item.attr != nil {
+                        continue
+                }
+                span := order.span(item.node)
+                if span[0] < end {
+                        continue
+                }
+                result = append(result, item)
+                end = span[1]
+        }
+        return result
+}
+
+// apply evaluates the step from one context node. For "//" steps the
+// predicates are applied per parent, as in descendant-or-self::node()/step.
+func (s xpathStep) apply(order *xpathOrder, node *html.Node) []xpathItem {
+        if !s.descendant {
+                return s.filter(order, s.candidates(node))
+        }
+
+        var result []xpathItem
+        var walk func(*html.Node)
+        walk = func(n *html.Node) {
+                result = append(result, s.filter(order, s.candidates(n))...)
+                for c := n.FirstChild; c != nil; c = c.NextSibling {
+                        walk(c)
+                }
+        }
+        walk(node)
+        return result
+}
+
+func (s xpathStep) candidates(node *html.Node) []xpathItem {
+        var items []xpathItem
+        switch s.axis {
+        case "self":
+                items = append(items, xpathItem{node: node})
+        case "parent":
+                if node.Parent != nil {
+                        items = append(items, xpathItem{node: node.Parent})
+                }
+        case "attribute":
+                for i := range node.Attr {
+                        if s.test == "*" || node.Attr[i].Key == s.test {
+                                items = append(items, xpathItem{node: node, attr: &node.Attr[i]})
+                        }
+                }
+        default:
+                for c := node.FirstChild; c != nil; c = c.NextSibling {
+                        if s.matches(c) {
+                                items = append(items, xpathItem{node: c})
+                        }
+                }
+        }
+        return items
+}
+
+func (s xpathStep) matches(n *html.Node) bool {
+        switch s.test {
+        case "node()":
+                return n.Type == html.ElementNode || n.Type == html.TextNode
+        case "text()":
+                return n.Type == html.TextNode
+        case "*":
+                return n.Type == html.ElementNode
+        }
+        return n.Type == html.ElementNode && strings.EqualFold(n.Data, s.test)
+}
+
+func (s xpathStep) filter(order *xpathOrder, items []xpathItem) []xpathItem {
+        return filterItems(order, items, s.predicates)
+}
+
+func filterItems(order *xpathOrder, items []xpathItem, predicates []xpathExpr) []xpathItem {
+        for _, predicate := range predicates {
+                var kept []xpathItem
+                for i, item := range items {
+                        ctx := xpathContext{order: order, item: item, position: i + 1, size: len(items)}
+                        if predicateMatches(predicate, ctx) {
+                                kept = append(kept, item)
+                        }
+                }
+                items = kept
+        }
+        return items
+}
+
+// Predicate expressions
+
+type xpathContext struct {
+        order    *xpathOrder
+        item     xpathItem
+        position int
+        size     int
+}
+
+// xpathValue is the result of a predicate expression: a number, a string, a
+// boolean or a node-set reduced to its items.
+type xpathValue struct {
+        kind  string // "number", "string", "bool" or "nodes"
+        num   float64
+        str   string
+        b     bool
+        nodes []xpathItem
+}
+
+func (v xpathValue) toBool() bool {
+        switch v.kind {
+        case "number":
+                return v.num != 0
+        case "string":
+                return v.str != ""
+        case "nodes":
+                return len(v.nodes) > 0
+        }
+        return v.b
+}
+
+func (v xpathValue) toString() string {
+        switch v.kind {
+        case "number":
+                return strconv.FormatFloat(v.num, 'f', -1, 64)
+        case "nodes":
+                if len(v.nodes) == 0 {
+                        return ""
+                }
+                return v.nodes[0].stringValue()
+        case "bool":
+                return strconv.FormatBool(v.b)
+        }
+        return v.str
+}
+
+func (v xpathValue) strings() []string {
+        if v.kind != "nodes" {
+                return []string{v.toString()}
+        }
+        values := make([]string, len(v.nodes))
+        for i, item := range v.nodes {
+                values[i] = item.stringValue()
+        }
+        return values
+}
+
+type xpathExpr interface {
+        eval(ctx xpathContext) xpathValue
+}
+
+// predicateMatches applies the predicate rules: a number selects that
+// position, anything else is converted to a boolean.
+func predicateMatches(e xpathExpr, ctx xpathContext) bool {
+        v := e.eval(ctx)
+        if v.kind == "number" {
+                return float64(ctx.position) == v.num
+        }
+        return v.toBool()
+}
+
+type xpathLiteral xpathValue
+
+func (l xpathLiteral) eval(xpathContext) xpathValue { return xpathValue(l) }
+
+type xpathPathExpr struct {
+        absolute bool
+        steps    []xpathStep
+}
+
+func (p xpathPathExpr) eval(ctx xpathContext) xpathValue {
+        if ctx.item.attr != nil {
+                return xpathValue{kind: "nodes"}
+        }
+        return xpathValue{kind: "nodes", nodes: selectPath(ctx.order, ctx.item.node, p.absolute, p.steps)}
+}
+
+type xpathBinary struct {
+        op          string
+        left, right xpathExpr
+}
+
+func (b xpathBinary) eval(ctx xpathContext) xpathValue {
+        switch b.op {
+        case "and":
+                return xpathValue{kind: "bool", b: b.left.eval(ctx).toBool() && b.right.eval(ctx).toBool()}
+        case "or":
+                return xpathValue{kind: "bool", b: b.left.eval(ctx).toBool() || b.right.eval(ctx).toBool()}
+        }
+
+        // Comparisons with a node-set are true if any node compares true
+        left, right := b.left.eval(ctx), b.right.eval(ctx)
+        for _, l := range left.strings() {
+                for _, r := range right.strings() {
+                        if left.kind == "number" || right.kind == "number" {
+                                ln, lerr := strconv.ParseFloat(strings.TrimSpace(l), 64)
+                                rn, rerr := strconv.ParseFloat(strings.TrimSpace(r), 64)
+                                if lerr == nil && rerr == nil && (ln == rn) == (b.op == "=") {
+                                        return xpathValue{kind: "bool", b: true}
+                                }
+                                continue
+                        }
+                        if (l == r) == (b.op == "=") {
+                                return xpathValue{kind: "bool", b: true}
+                        }
+                }
+        }
+        return xpathValue{kind: "bool", b: false}
+}
+
+type xpathFunc struct {
+        name string
+        args []xpathExpr
+}
+
+func (f xpathFunc) eval(ctx xpathContext) xpathValue {
+        arg := func(i int) string {
+                if i < len(f.args) {
+                        return f.args[i].eval(ctx).toString()
+                }
+                return xpathItem{node: ctx.item.node, attr: ctx.item.attr}.stringValue()
+        }
+
+        switch f.name {
+        case "last":
+                return xpathValue{kind: "number", num: float64(ctx.size)}
+        case "position":
+                return xpathValue{kind: "number", num: float64(ctx.position)}
+        case "not":
+                return xpathValue{kind: "bool", b: !f.args[0].eval(ctx).toBool()}
+        case "contains":
+                return xpathValue{kind: "bool", b: strings.Contains(arg(0), arg(1))}
+        case "starts-with":
+                return xpathValue{kind: "bool", b: strings.HasPrefix(arg(0), arg(1))}
+        case "normalize-space":
+                return xpathValue{kind: "string", str: collapseWhitespace(arg(0))}
+        case "string":
+                return xpathValue{kind: "string", str: arg(0)}
+        }
+        return xpathValue{kind: "bool"}
+}
+
+// xpathFunctions lists the supported functions with their argument counts.
+var xpathFunctions = map[string][2]int{
+        "last":            {0, 0},
+        "position":        {0, 0},
+        "not":             {1, 1},
+        "contains":        {2, 2},
+        "starts-with":     {2, 2},
+        "normalize-space": {0, 1},
+        "string":          {0, 1},
+}
+
+// Parser
+
+type xpathParser struct {
+        input string
+        pos   int
+}
+
+func (p *xpathParser) skipSpace() {
+        for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+                p.pos++
+        }
+}
+
+func (p *xpathParser) peek(s string) bool {
+        p.skipSpace()
+        return strings.HasPrefix(p.input[p.pos:], s)
+}
+
+func (p *xpathParser) consume(s string) bool {
+        if p.peek(s) {
+                p.pos += len(s)
+                return true
+        }
+        return false
+}
+
+func (p *xpathParser) parsePath() (xpathPathExpr, error) {
+        var path xpathPathExpr
+        descendant := false
+
+        p.skipSpace()
+        switch {
+        case p.consume("//"):
+                path.absolute, descendant = true, true
+        case p.consume("/"):
+                path.absolute = true
+        }
+
+        for {
+                step, err := p.parseStep()
+                if err != nil {
+                        return path, err
+                }
+                step.descendant = descendant
+                path.steps = append(path.steps, step)
+
+                if p.consume("//") {
+                        descendant = true
+                } else if p.consume("/") {
+                        descendant = false
+                } else {
+                        return path, nil
+                }
+        }
+}
+
+func (p *xpathParser) parseStep() (xpathStep, error) {
+        step := xpathStep{axis: "child"}
+
+        switch {
+        case p.consume(".."):
+                step.axis, step.test = "parent", "node()"
+        case p.consume("."):
+                step.axis, step.test = "self", "node()"
+        case p.consume("@"):
+                step.axis = "attribute"
+                if p.consume("*") {
+                        step.test = "*"
+                } else if step.test = p.parseName(); step.test == "" {
+                        return step, fmt.Errorf("expected attribute name at position %d", p.pos)
+                }
+        case p.consume("*"):
+                step.test = "*"
+        default:
+                name := p.parseName()
+                if name == "" {
+                        return step, fmt.Errorf("expected step at position %d", p.pos)
+                }
+                if name == "text" || name == "node" {
+                        if !p.consume("(") || !p.consume(")") {
+                                return step, fmt.Errorf("expected %s() at position %d", name, p.pos)
+                        }
+                        name += "()"
+                }
+                step.test = name
+        }
+
+        var err error
+        step.predicates, err = p.parsePredicates()
+        return step, err
+}
+
+func (p *xpathParser) parsePredicates() ([]xpathExpr, error) {
+        var predicates []xpathExpr
+        for p.consume("[") {
+                expr, err := p.parseOr()
+                if err != nil {
+                        return nil, err
+                }
+                if !p.consume("]") {
+                        return nil, fmt.Errorf("expected ] at position %d", p.pos)
+                }
+                predicates = append(predicates, expr)
+        }
+        return predicates, nil
+}
+
+func (p *xpathParser) parseName() string {
+        p.skipSpace()
+        start := p.pos
+        for p.pos < len(p.input) {
+                r := rune(p.input[p.pos])
+                if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == ':' || (r == '.' && p.pos > start) {
+                        p.pos++
+                        continue
+                }
+                break
+        }
+        return p.input[start:p.pos]
+}
+
+func (p *xpathParser) parseOr() (xpathExpr, error) {
+        left, err := p.parseAnd()
+        if err != nil {
+                return nil, err
+        }
+        for p.consumeKeyword("or") {
+                right, err := p.parseAnd()
+                if err != nil {
+                        return nil, err
+                }
+                left = xpathBinary{op: "or", left: left, right: right}
+        }
+        return left, nil
+}
+
+func (p *xpathParser) parseAnd() (xpathExpr, error) {
+        left, err := p.parseComparison()
+        if err != nil {
+                return nil, err
+        }
+        for p.consumeKeyword("and") {
+                right, err := p.parseComparison()
+                if err != nil {
+                        return nil, err
+                }
+                left = xpathBinary{op: "and", left: left, right: right}
+        }
+        return left, nil
+}
+
+// consumeKeyword consumes an operator name only when it stands alone.
+func (p *xpathParser) consumeKeyword(word string) bool {
+        if !p.peek(word) {
+                return false
+        }
+        end := p.pos + len(word)
+        if end < len(p.input) && !unicode.IsSpace(rune(p.input[end])) && p.input[end] != '(' {
+                return false
+        }
+        p.pos = end
+        return true
+}
+
+func (p *xpathParser) parseComparison() (xpathExpr, error) {
+        left, err := p.parsePrimary()
+        if err != nil {
+                return nil, err
+        }
+        for _, op := range []string{"!=", "="} {
+                if p.consume(op) {
+                        right, err := p.parsePrimary()
+                        if err != nil {
+                                return nil, err
+                        }
+                        return xpathBinary{op: op, left: left, right: right}, nil
+                }
+        }
+        return left, nil
+}
+
+func (p *xpathParser) parsePrimary() (xpathExpr, error) {
+        p.skipSpace()
+        if p.pos >= len(p.input) {
+                return nil, fmt.Errorf("unexpected end of expression")
+        }
+
+        c := p.input[p.pos]
+        switch {
+        case c == '(':
+                p.pos++
+                expr, err := p.parseOr()
+                if err != nil {
+                        return nil, err
+                }
+                if !p.consume(")") {
+                        return nil, fmt.Errorf("expected ) at position %d", p.pos)
+                }
+                return expr, nil
+        case c == '\'' || c == '"':
+                end := strings.IndexByte(p.input[p.pos+1:], c)
+                if end < 0 {
+                        return nil, fmt.Errorf("unterminated string at position %d", p.pos)
+                }
+                value := p.input[p.pos+1 : p.pos+1+end]
+                p.pos += end + 2
+                return xpathLiteral{kind: "string", str: value}, nil
+        case c >= '0' && c <= '9':
+                start := p.pos
+                for p.pos < len(p.input) && (p.input[p.pos] >= '0' && p.input[p.pos] <= '9' || p.input[p.pos] == '.') {
+                        p.pos++
+                }
+                n, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+                if err != nil {
+                        return nil, fmt.Errorf("invalid number %q", p.input[start:p.pos])
+                }
+                return xpathLiteral{kind: "number", num: n}, nil
+        }
+
+        // A function call, unless it is the text() or node() node test
+        save := p.pos
+        name := p.parseName()
+        if arity, ok := xpathFunctions[name]; ok && p.consume("(") {
+                fn := xpathFunc{name: name}
+                for !p.consume(")") {
+                        if len(fn.args) > 0 && !p.consume(",") {
+                                return nil, fmt.Errorf("expected , or ) at position %d", p.pos)
+                        }
+                        arg, err := p.parseOr()
+                        if err != nil {
+                                return nil, err
+                        }
+                        fn.args = append(fn.args, arg)
+                }
+                if len(fn.args) < arity[0] || len(fn.args) > arity[1] {
+                        return nil, fmt.Errorf("wrong number of arguments to %s()", name)
+                }
+                return fn, nil
+        }
+        p.pos = save
+
+        path, err := p.parsePath()
+        if err != nil {
+                return nil, err
+        }
+        return path, nil
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+        "reflect"
+        "strings"
+        "testing"
+
+        "golang.org/x/net/html"
+)
+
+const xpathTestPage = `<html><head><title>Shop</title></head><body>
+<div id="main" class="content">
+  <h1>Products</h1>
+  <ul>
+    <li class="item" data-sku="a1"><a href="/a">Apple</a> <span>1.50</span></li>
+    <li class="item sale" data-sku="b2"><a href="/b">Banana</a> <span>0.25</span></li>
+    <li class="item" data-sku="c3"><a href="/c" rel="nofollow">Cherry</a> <span>  3.00  </span></li>
+  </ul>
+  <div class="outer"><div class="inner"><p>deep</p></div><p>shallow</p></div>
+</div>
+<p id="footer">Footer <b>bold</b> text</p>
+</body></html>`
+
+func TestXPathEvaluate(t *testing.T) {
+        root, err := html.Parse(strings.NewReader(xpathTestPage))
+        if err != nil {
+                t.Fatal(err)
+        }
+
+        tests := []struct {
+                expr string
+                want []string
+        }{
+                // Absolute and relative paths
+                {"/html/head/title", []string{"Shop"}},
+                {"//title", []string{"Shop"}},
+                {"html/body/p/b", []string{"bold"}},
+                {"//ul/li/a", []string{"Apple", "Banana", "Cherry"}},
+                {"//ul//a", []string{"Apple", "Banana", "Cherry"}},
+                {"//h2", []string{}},
+
+                // Node tests
+                {"//li[1]/*", []string{"Apple", "1.50"}},
+                {"//p[@id='footer']/text()", []string{"Footer ", " text"}},
+                {"//p[@id='footer']/node()", []string{"Footer ", "bold", " text"}},
+                {"//li/@data-sku", []string{"a1", "b2", "c3"}},
+                {"//li[1]/@*", []string{"item", "a1"}},
+                {"//h1/.", []string{"Products"}},
+                {"//h1/../@id", []string{"main"}},
+
+                // Positions
+                {"//li[2]/a", []string{"Banana"}},
+                {"//li[last()]/a", []string{"Cherry"}},
+                {"//li[position() = 2]/a", []string{"Banana"}},
+                {"//li[position() != 2]/a", []string{"Apple", "Cherry"}},
+                {"(//a)[1]", []string{"Apple"}},
+                {"(//a)[last()]", []string{"Cherry"}},
+                {"//li/a[1]", []string{"Apple", "Banana", "Cherry"}},
+
+                // Comparisons and functions
+                {"//li[@class='item sale']/a", []string{"Banana"}},
+                {"//li[contains(@class, 'sale')]/a", []string{"Banana"}},
+                {"//li[not(contains(@class, 'sale'))]/a", []string{"Apple", "Cherry"}},
+                {"//a[starts-with(@href, '/c')]", []string{"Cherry"}},
+                {"//li[a/@rel]/@data-sku", []string{"c3"}},
+                {"//li[span = 0.25]/a", []string{"Banana"}},
+                {"//li[normalize-space(span) = '3.00']/a", []string{"Cherry"}},
+                {"//li[a = 'Apple' or a = 'Cherry']/@data-sku", []string{"a1", "c3"}},
+                {"//li[@data-sku = 'a1' and a = 'Apple']/span", []string{"1.50"}},
+                {"//a[text() = 'Banana']/@href", []string{"/b"}},
+                {"//li[a[@href = '/b']]/span", []string{"0.25"}},
+
+                // Wrapping functions
+                {"normalize-space(//li[3]/span)", []string{"3.00"}},
+                {"string(//h1)", []string{"Products"}},
+
+                // Document order with nested and multiple contexts
+                {"//div//p", []string{"deep", "shallow"}},
+                {"//div/div//p", []string{"deep", "shallow"}},
+                {"//p/..", []string{xpathText(root, "body"), "deepshallow", "deep"}},
+                {"//a/../../li[1]/@data-sku", []string{"a1"}},
+        }
+
+        for _, tt := range tests {
+                x, err := compileXPath(tt.expr)
+                if err != nil {
+                        t.Errorf("compileXPath(%q) failed: %v", tt.expr, err)
+                        continue
+                }
+                got := x.evaluate(root)
+                for i := range got {
+                        got[i] = strings.TrimSpace(collapseWhitespace(got[i]))
+                }
+                want := make([]string, len(tt.want))
+                for i := range tt.want {
+                        want[i] = strings.TrimSpace(collapseWhitespace(tt.want[i]))
+                }
+                if !reflect.DeepEqual(got, want) {
+                        t.Errorf("%s = %q, want %q", tt.expr, got, want)
+                }
+        }
+}
+
+func TestCompileXPathErrors(t *testing.T) {
+        for _, expr := range []string{
+                "",
+                "//",
+                "//li[",
+                "//li[1",
+                "(//li[1]",
+                "//li[contains(@class)]",
+                "//li[@class = 'x]",
+                "//text(",
+                "//@",
+                "//li]",
+                "//span/../a | //x",
+        } {
+                if _, err := compileXPath(expr); err == nil {
+                        t.Errorf("compileXPath(%q) succeeded, want an error", expr)
+                }
+        }
+}
+
+// xpathText returns the text of the first element with the given name.
+func xpathText(root *html.Node, name string) string {
+        var found *html.Node
+        var walk func(*html.Node)
+        walk = func(n *html.Node) {
+                if found == nil && n.Type == html.ElementNode && n.Data == name {
+                        found = n
+                }
+                for c := n.FirstChild; c != nil && found == nil; c = c.NextSibling {
+                        walk(c)
+                }
+        }
+        walk(root)
+        return xpathItem{node: found}.stringValue()
+}
@@ -1,6 +1,10 @@
 export interface URL {
   id: string;
   url: string;
+  normalized_url: string | null;
+  dns_overrides: DNSOverride[] | null;
+  tls_insecure: boolean;
+  tags: string[];
   status: 'queued' | 'running' | 'completed' | 'error' | 'stopped';
   created_at: string;
   last_crawled?: string;
@@ -17,11 +21,127 @@ export interface URL {
   broken_links: number;
   has_login_form: boolean;
   error_message?: string;
+  structured_data_count: number;
+  structured_data_errors: number;
+  accessibility_errors: number;
+  accessibility_warnings: number;
+  heading_issues: number;
+  login_form_confidence: number;
+  login_form_type?: 'password_login' | 'signup' | 'password_reset' | 'sso' | 'multi_step_login' | '';
+  form_count: number;
+  insecure_forms: number;
+  security_score?: number;
+  mixed_content_active: number;
+  mixed_content_passive: number;
+  insecure_links: number;
+  word_count: number;
+  reading_time_seconds: number;
+  language?: string;
+  flesch_reading_ease?: number;
+  snapshot_retention?: number;
+  latest_run_id?: string;
+  significant_change?: boolean;
+  change_similarity?: number;
+  last_changed_at?: string;
+  schedule?: URLSchedule;
+  next_scheduled_at?: string;
+  last_scheduled_at?: string;
+  assertion_status?: 'passed' | 'failed';
+  assertions_failed?: number;
+  extracted?: Record<string, unknown>;
 }
 
+export interface URLSchedule {
+  cron?: string;
+  interval_seconds?: number;
+  timezone: string;
+  jitter_seconds: number;
+}
+
+export interface Link {
+  id: string;
+  source_url_id: string;
+  source_url: string;
+  target_url: string;
+  anchor_text: string;
+  rel: string[];
+  element: string;
+  internal: boolean;
+  created_at: string;
+}
+
+export interface SiteAnalysisPage {
+  url: string;
+  url_id: string | null;
+  crawled: boolean;
+  depth: number | null;
+  inlinks: number;
+  outlinks: number;
+  pagerank: number;
+  score: number;
+  in_sitemap: boolean;
+  orphan: boolean;
+  deep: boolean;
+}
+
+export interface SiteAnalysis {
+  id: string;
+  seed_url_id: string;
+  host: string;
+  page_count: number;
+  crawled_count: number;
+  max_depth: number;
+  unreachable: number;
+  deep_pages: number;
+  orphan_pages: number;
+  sitemap_urls: number;
+  created_at: string;
+  pages?: SiteAnalysisPage[];
+}
+
+export interface DuplicatePage {
+  url_id: string;
+  url: string;
+}
+
+export interface DuplicateCluster {
+  type: 'title' | 'description' | 'content';
+  value?: string;
+  exact: boolean;
+  similarity: number;
+  pages: DuplicatePage[];
+}
+
+export interface DNSOverride {
+  host: string;
+  port?: number;
+  ip: string;
+}
+
+export interface ImportResult {
+  row: number;
+  url: string;
+  status: 'created' | 'skipped' | 'invalid';
+  id?: string;
+  normalized_url?: string;
+  existing_id?: string;
+  duplicate_row?: number;
+  error?: string;
+}
+
+export interface ImportResponse {
+  format: 'csv' | 'text' | 'json';
+  summary: Record<'created' | 'skipped' | 'invalid', number>;
+  queued: number;
+  results: ImportResult[];
+}
+
+export type ExportFormat = 'csv' | 'ndjson' | 'xlsx';
+
 export interface BrokenLink {
   id: string;
   url_id: string;
+  run_id?: string;
   link_url: string;
   status_code: number;
   error_message?: string;